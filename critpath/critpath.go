@@ -0,0 +1,262 @@
+// Package critpath explains the latency of an event in a simulation trace by finding its critical path.
+//
+// The critical path of an event is the chain of messages and timers that caused it, found by following
+// the parent ids of messages and timers back to an event that was sent outside of any handler. The time
+// along the path is broken down into network latency, time spent waiting in the queue of a node, timer
+// waits and processing inside handlers.
+//
+// Queueing is measured from the arrival times recorded by a TraceLogger, so it is only non-zero when the
+// simulation runs in real time. When it is driven step by step, or the events were read back from a JSON
+// Lines event log, all time between sending and handling a message is counted as network latency.
+// The same is done for messages that were sent or handled more than once with the same id, such as
+// duplicated messages and retried calls, because their arrivals cannot be told apart.
+package critpath
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// SegmentKind is a string that identifies how the time of a segment of a critical path was spent.
+//
+// It can be either Network, Queueing, Timer or Processing.
+type SegmentKind string
+
+const (
+	// Network is the time a message spent in the network, from when it was sent until it arrived at its destination.
+	Network SegmentKind = "Network"
+	// Queueing is the time a message or timer spent in the queue of a node, from when it arrived until it was handled.
+	Queueing SegmentKind = "Queueing"
+	// Timer is the time from when a timer was set until it arrived at its node.
+	Timer SegmentKind = "Timer"
+	// Processing is the time a handler ran before it sent the next message or set the next timer on the path.
+	Processing SegmentKind = "Processing"
+)
+
+// waitKinds maps the kinds of events that end the journey of a message, timer or interrupt to the kind
+// of segment spent between it being sent and arriving.
+var waitKinds = map[ds.EventKind]SegmentKind{
+	ds.HandleMessageEvent:   Network,
+	ds.DropMessageEvent:     Network,
+	ds.HandleTimerEvent:     Timer,
+	ds.DropTimerEvent:       Timer,
+	ds.HandleInterruptEvent: Network,
+	ds.DropInterruptEvent:   Network,
+}
+
+// Segment is a period of time on a critical path.
+//
+// From and To are the nodes the time was spent between, and are the same node for every kind
+// of segment except Network. Event is the send, set or handle event the segment belongs to.
+type Segment struct {
+	Kind  SegmentKind
+	From  ds.Address
+	To    ds.Address
+	Start time.Duration
+	End   time.Duration
+	Event ds.Event
+}
+
+// Duration returns the length of the segment.
+func (s Segment) Duration() time.Duration {
+	return s.End - s.Start
+}
+
+// String returns a string representation of the segment for debugging purposes.
+func (s Segment) String() string {
+	where := string(s.From)
+	if s.From != s.To {
+		where = fmt.Sprintf("%v -> %v", s.From, s.To)
+	}
+	return fmt.Sprintf("[%v - %v] %v %v (%v) %v", s.Start, s.End, s.Kind, s.Duration(), where, describe(s.Event))
+}
+
+// Path is the critical path of an event in a trace.
+//
+// Start is the time the first message or timer on the path was sent or set, and Total is the time from
+// then until the end event. Nodes are the nodes on the path, in the order the path first reaches them.
+type Path struct {
+	End      ds.Event
+	Segments []Segment
+	Start    time.Duration
+	Total    time.Duration
+	Nodes    []ds.Address
+}
+
+// Breakdown returns the total time of the path spent in each kind of segment.
+func (p *Path) Breakdown() map[SegmentKind]time.Duration {
+	breakdown := map[SegmentKind]time.Duration{Network: 0, Queueing: 0, Timer: 0, Processing: 0}
+	for _, s := range p.Segments {
+		breakdown[s.Kind] += s.Duration()
+	}
+	return breakdown
+}
+
+// String returns a report of the path, with the segments in order followed by the breakdown of the total time.
+func (p *Path) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Critical path of %v\n", p.End)
+	for _, s := range p.Segments {
+		fmt.Fprintf(&b, "  %v\n", s)
+	}
+	fmt.Fprintf(&b, "Total: %v\n", p.Total)
+	breakdown := p.Breakdown()
+	for _, kind := range []SegmentKind{Network, Queueing, Timer, Processing} {
+		percent := 0.0
+		if p.Total > 0 {
+			percent = 100 * float64(breakdown[kind]) / float64(p.Total)
+		}
+		fmt.Fprintf(&b, "  %v: %v (%.1f%%)\n", kind, breakdown[kind], percent)
+	}
+	nodes := make([]string, len(p.Nodes))
+	for i, node := range p.Nodes {
+		nodes[i] = string(node)
+	}
+	fmt.Fprintf(&b, "Nodes: %v\n", strings.Join(nodes, ", "))
+	return b.String()
+}
+
+// WriteFile writes the report of the path to the file at the given path.
+func (p *Path) WriteFile(path string) error {
+	return os.WriteFile(path, []byte(p.String()), 0644)
+}
+
+// Analyze returns the critical path of the event at index i of a trace.
+//
+// The event is usually the handling of a message, such as a client receiving a response, but may be any
+// event of a message, timer or interrupt. An error is returned if the event is not part of a causal chain.
+func Analyze(events []ds.Event, i int) (*Path, error) {
+	if i < 0 || i >= len(events) {
+		return nil, fmt.Errorf("event index %v out of range", i)
+	}
+	end := events[i]
+	if end.Id() == "" {
+		return nil, fmt.Errorf("%v is not an event of a message, timer or interrupt", end)
+	}
+	ambiguous := ambiguousIds(events)
+	segments := make([]Segment, 0)
+	for {
+		event := events[i]
+		if kind, ok := waitKinds[event.Kind]; ok {
+			j := find(events, i, event.Id(), ds.SendMessageEvent, ds.SetTimerEvent, ds.SendInterruptEvent)
+			if j < 0 {
+				return nil, fmt.Errorf("no send event found for %v", event)
+			}
+			node := event.Node()
+			arrival := event.Arrival
+			if ambiguous[event.Id()] || arrival < events[j].Time || arrival > event.Time {
+				arrival = event.Time
+			}
+			if arrival < event.Time {
+				segments = append(segments, Segment{Kind: Queueing, From: node, To: node, Start: arrival, End: event.Time, Event: event})
+			}
+			segments = append(segments, Segment{Kind: kind, From: events[j].Node(), To: node, Start: events[j].Time, End: arrival, Event: events[j]})
+			i = j
+			continue
+		}
+		parent := event.ParentId()
+		if parent == "" {
+			break
+		}
+		j := find(events, i, parent, ds.HandleMessageEvent, ds.HandleTimerEvent, ds.HandleInterruptEvent)
+		if j < 0 {
+			return nil, fmt.Errorf("no handle event found for the parent of %v", event)
+		}
+		node := event.Node()
+		segments = append(segments, Segment{Kind: Processing, From: node, To: node, Start: events[j].Time, End: event.Time, Event: events[j]})
+		i = j
+	}
+	for l, r := 0, len(segments)-1; l < r; l, r = l+1, r-1 {
+		segments[l], segments[r] = segments[r], segments[l]
+	}
+	path := &Path{
+		End:      end,
+		Segments: segments,
+		Start:    events[i].Time,
+		Total:    end.Time - events[i].Time,
+		Nodes:    make([]ds.Address, 0),
+	}
+	seen := make(map[ds.Address]bool)
+	for _, s := range segments {
+		for _, node := range []ds.Address{s.From, s.To} {
+			if !seen[node] {
+				seen[node] = true
+				path.Nodes = append(path.Nodes, node)
+			}
+		}
+	}
+	if len(segments) == 0 {
+		path.Nodes = append(path.Nodes, end.Node())
+	}
+	return path, nil
+}
+
+// AnalyzeLast returns the critical path of the last event in a trace that matches.
+func AnalyzeLast(events []ds.Event, match func(event ds.Event) bool) (*Path, error) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if match(events[i]) {
+			return Analyze(events, i)
+		}
+	}
+	return nil, fmt.Errorf("no matching event found")
+}
+
+// ambiguousIds returns the ids of the messages, timers and interrupts of a trace that were sent or handled
+// more than once, whose arrival times may belong to a different copy.
+func ambiguousIds(events []ds.Event) map[string]bool {
+	sent := make(map[string]int)
+	handled := make(map[string]int)
+	for _, event := range events {
+		switch event.Kind {
+		case ds.SendMessageEvent, ds.SetTimerEvent, ds.SendInterruptEvent:
+			sent[event.Id()]++
+		case ds.HandleMessageEvent, ds.HandleTimerEvent, ds.HandleInterruptEvent:
+			handled[event.Id()]++
+		}
+	}
+	ambiguous := make(map[string]bool)
+	for id, count := range sent {
+		if count > 1 {
+			ambiguous[id] = true
+		}
+	}
+	for id, count := range handled {
+		if count > 1 {
+			ambiguous[id] = true
+		}
+	}
+	return ambiguous
+}
+
+// find returns the index of the last event before index i with the given id and one of the given kinds, or -1.
+func find(events []ds.Event, i int, id string, kinds ...ds.EventKind) int {
+	for j := i - 1; j >= 0; j-- {
+		if events[j].Id() != id {
+			continue
+		}
+		for _, kind := range kinds {
+			if events[j].Kind == kind {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// describe returns a short description of an event, without its time.
+func describe(event ds.Event) string {
+	switch event.Kind {
+	case ds.SendMessageEvent, ds.HandleMessageEvent, ds.DropMessageEvent:
+		return fmt.Sprintf("%v %v", event.Kind, event.Message.Type)
+	case ds.SetTimerEvent, ds.HandleTimerEvent, ds.DropTimerEvent:
+		return fmt.Sprintf("%v %v", event.Kind, event.Timer.Type)
+	case ds.SendInterruptEvent, ds.HandleInterruptEvent, ds.DropInterruptEvent:
+		return fmt.Sprintf("%v %v", event.Kind, event.Interrupt.Type)
+	default:
+		return string(event.Kind)
+	}
+}
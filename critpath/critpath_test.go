@@ -0,0 +1,145 @@
+package critpath_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/critpath"
+)
+
+const (
+	request  ds.MessageType = "Request"
+	response ds.MessageType = "Response"
+	work     ds.TimerType   = "Work"
+)
+
+// requestTrace is a trace in which c sends a request to s, which sets a timer and responds once it fires.
+//
+// The request spends 3ms in the network and 2ms in the queue of s, which sets the timer after 1ms.
+// The timer fires after 10ms, s responds 2ms later, and the response spends 3ms in the network and 1ms
+// in the queue of c.
+func requestTrace() []ds.Event {
+	ms := time.Millisecond
+	req := ds.Message{Id: "req", Type: request}
+	timer := ds.Timer{Id: "t", Type: work, ParentId: "req"}
+	resp := ds.Message{Id: "resp", Type: response, ReplyTo: "req", ParentId: "t"}
+	return []ds.Event{
+		{Kind: ds.SimulationStateEvent, SimulationState: ds.SimulationRunning},
+		{Kind: ds.SendMessageEvent, Time: 0, From: "c", To: "s", Message: req},
+		{Kind: ds.HandleMessageEvent, Time: 5 * ms, Arrival: 3 * ms, From: "c", To: "s", Message: req},
+		{Kind: ds.SetTimerEvent, Time: 6 * ms, To: "s", Timer: timer, Duration: 10 * ms},
+		{Kind: ds.HandleTimerEvent, Time: 16 * ms, Arrival: 16 * ms, To: "s", Timer: timer, Duration: 10 * ms},
+		{Kind: ds.SendMessageEvent, Time: 18 * ms, From: "s", To: "c", Message: resp},
+		{Kind: ds.HandleMessageEvent, Time: 22 * ms, Arrival: 21 * ms, From: "s", To: "c", Message: resp},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	ms := time.Millisecond
+	path, err := critpath.Analyze(requestTrace(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind       critpath.SegmentKind
+		from, to   ds.Address
+		start, end time.Duration
+	}{
+		{critpath.Network, "c", "s", 0, 3 * ms},
+		{critpath.Queueing, "s", "s", 3 * ms, 5 * ms},
+		{critpath.Processing, "s", "s", 5 * ms, 6 * ms},
+		{critpath.Timer, "s", "s", 6 * ms, 16 * ms},
+		{critpath.Processing, "s", "s", 16 * ms, 18 * ms},
+		{critpath.Network, "s", "c", 18 * ms, 21 * ms},
+		{critpath.Queueing, "c", "c", 21 * ms, 22 * ms},
+	}
+	if len(path.Segments) != len(want) {
+		t.Fatalf("path has segments\n%v\nwant %d", path, len(want))
+	}
+	for i, w := range want {
+		s := path.Segments[i]
+		if s.Kind != w.kind || s.From != w.from || s.To != w.to || s.Start != w.start || s.End != w.end {
+			t.Errorf("segment %d is %v, want %v from %v to %v between %v and %v", i, s, w.kind, w.from, w.to, w.start, w.end)
+		}
+	}
+	breakdown := map[critpath.SegmentKind]time.Duration{
+		critpath.Network:    6 * ms,
+		critpath.Queueing:   3 * ms,
+		critpath.Timer:      10 * ms,
+		critpath.Processing: 3 * ms,
+	}
+	for kind, d := range path.Breakdown() {
+		if d != breakdown[kind] {
+			t.Errorf("%v took %v, want %v", kind, d, breakdown[kind])
+		}
+	}
+	if path.Start != 0 || path.Total != 22*ms {
+		t.Errorf("path starts at %v and takes %v, want 0 and 22ms", path.Start, path.Total)
+	}
+	if len(path.Nodes) != 2 || path.Nodes[0] != "c" || path.Nodes[1] != "s" {
+		t.Errorf("path visits %v, want c and s", path.Nodes)
+	}
+	if report := path.String(); !strings.Contains(report, "Timer: 10ms (45.5%)") {
+		t.Errorf("report does not break down the timer wait:\n%v", report)
+	}
+}
+
+func TestAnalyzeDuplicatedMessage(t *testing.T) {
+	ms := time.Millisecond
+	m := ds.Message{Id: "m", Type: request}
+	// Both copies arrive at 2ms and 4ms, but the trace cannot tell which copy arrived first.
+	events := []ds.Event{
+		{Kind: ds.SendMessageEvent, Time: 0, From: "c", To: "s", Message: m},
+		{Kind: ds.HandleMessageEvent, Time: 3 * ms, Arrival: 2 * ms, From: "c", To: "s", Message: m},
+		{Kind: ds.HandleMessageEvent, Time: 5 * ms, Arrival: 4 * ms, From: "c", To: "s", Message: m},
+	}
+	path, err := critpath.Analyze(events, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path.Segments) != 1 || path.Segments[0].Kind != critpath.Network || path.Segments[0].Duration() != 5*ms {
+		t.Errorf("duplicated message has segments %v, want 5ms of network latency", path.Segments)
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	events := requestTrace()
+	tests := []struct {
+		name   string
+		events []ds.Event
+		i      int
+		err    string
+	}{
+		{"negative index", events, -1, "out of range"},
+		{"index past the end", events, len(events), "out of range"},
+		{"state event", events, 0, "is not an event of a message"},
+		{"handled but never sent", events[2:], 0, "no send event found"},
+		{"parent handler missing", []ds.Event{events[3]}, 0, "no handle event found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := critpath.Analyze(test.events, test.i)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Analyze() returned %v, want an error containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLast(t *testing.T) {
+	events := requestTrace()
+	path, err := critpath.AnalyzeLast(events, func(event ds.Event) bool {
+		return event.Kind == ds.HandleTimerEvent
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.End.Id() != "t" || path.Total != 16*time.Millisecond {
+		t.Errorf("AnalyzeLast() = %v, want the path of the timer", path)
+	}
+	if _, err := critpath.AnalyzeLast(events, func(event ds.Event) bool { return false }); err == nil {
+		t.Error("AnalyzeLast() without a matching event returned no error")
+	}
+}
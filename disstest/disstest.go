@@ -0,0 +1,230 @@
+// Package disstest provides helpers for running simulations in Go tests.
+//
+// Simulations created by this package run deterministically in virtual time,
+// record every event so that assertions can be made over the trace, and write
+// their debug log to the test log when the test fails.
+package disstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/lincheck"
+)
+
+// DefaultSeed is the default seed used to schedule the events of a simulation.
+const DefaultSeed = 1
+
+// Simulation is a LocalSimulation that is bound to a test.
+type Simulation struct {
+	*ds.LocalSimulation
+	t       testing.TB
+	options *ds.LocalSimulationOptions
+	trace   *ds.TraceLogger
+	seed    int64
+}
+
+// New creates a new simulation for the given test.
+//
+// The log paths in the options are ignored, the debug log is instead written to
+// the test log if the test fails. If the options are nil, the default latencies
+// and duration are used.
+func New(t testing.TB, options *ds.LocalSimulationOptions) *Simulation {
+	t.Helper()
+	simOptions := simulationOptions(options)
+	sim := &Simulation{
+		LocalSimulation: ds.NewLocalSimulation(simOptions),
+		t:               t,
+		options:         simOptions,
+		seed:            DefaultSeed,
+	}
+	sim.LocalSimulation.SetSeed(sim.seed)
+	sim.trace = ds.NewTraceLogger(sim.LocalSimulation)
+	sim.AddLogger(sim.trace)
+
+	debugLog := &bytes.Buffer{}
+	sim.AddLogger(ds.NewDebugLoggerWithWriter(debugLog))
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("debug log:\n%s", debugLog.String())
+		}
+	})
+	return sim
+}
+
+// simulationOptions returns a copy of the options without log paths, or the default options if they are nil.
+func simulationOptions(options *ds.LocalSimulationOptions) *ds.LocalSimulationOptions {
+	if options == nil {
+		options = &ds.LocalSimulationOptions{
+			MinLatency: ds.DefaultMinLatency,
+			MaxLatency: ds.DefaultMaxLatency,
+			Duration:   ds.DefaultDuration,
+			BufferSize: ds.DefaultBufferSize,
+		}
+	}
+	simOptions := *options
+	simOptions.DebugLogPath = ""
+	simOptions.UmlLogPath = ""
+	simOptions.SvgPath = ""
+	simOptions.JsonLogPath = ""
+	simOptions.MetricsPath = ""
+	simOptions.ViolationLogPath = ""
+	return &simOptions
+}
+
+// SetSeed sets the seed used to schedule the events of the simulation and to generate the ids in its trace.
+func (s *Simulation) SetSeed(seed int64) {
+	s.seed = seed
+	s.LocalSimulation.SetSeed(seed)
+}
+
+// AddNode adds a node to the simulation and fails the test if the node cannot be added.
+func (s *Simulation) AddNode(node ds.Node) {
+	s.t.Helper()
+	if err := s.LocalSimulation.AddNode(node); err != nil {
+		s.t.Fatalf("failed to add node: %v", err)
+	}
+}
+
+// Run runs the simulation deterministically with a RandomScheduler using the seed of the simulation.
+//
+// The test fails if an invariant or eventual property is violated, and the seed is reported with
+// the trace of the violation, so the run can be repeated with SetSeed.
+func (s *Simulation) Run() {
+	s.t.Helper()
+	if err := s.LocalSimulation.RunScheduled(ds.NewRandomScheduler(s.seed)); err != nil {
+		s.t.Fatalf("simulation with seed %d failed: %v\n%s", s.seed, err, formatTrace(err, s.trace.Events()))
+	}
+}
+
+// RunScheduled runs the simulation deterministically with the given scheduler.
+//
+// The test fails if an invariant or eventual property is violated, and the trace of the violation is reported.
+func (s *Simulation) RunScheduled(scheduler ds.Scheduler) {
+	s.t.Helper()
+	if err := s.LocalSimulation.RunScheduled(scheduler); err != nil {
+		s.t.Fatalf("simulation failed: %v\n%s", err, formatTrace(err, s.trace.Events()))
+	}
+}
+
+// formatTrace returns the trace of a violation, one event per line.
+//
+// Invariant violations are reported with their causal history, and
+// other errors with the given trace of the whole simulation.
+func formatTrace(err error, trace []ds.Event) string {
+	var invariant *ds.InvariantViolation
+	var liveness *ds.LivenessViolation
+	header := "trace:"
+	switch {
+	case errors.As(err, &invariant):
+		header, trace = "causal history:", invariant.Trace
+	case errors.As(err, &liveness):
+		trace = liveness.Trace
+	}
+	lines := make([]string, 0, len(trace)+1)
+	lines = append(lines, header)
+	for _, event := range trace {
+		lines = append(lines, fmt.Sprint(event))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Trace returns the events recorded by the simulation.
+func (s *Simulation) Trace() []ds.Event {
+	return s.trace.Events()
+}
+
+// Events returns the events recorded by the simulation that match the filter.
+func (s *Simulation) Events(filter func(ds.Event) bool) []ds.Event {
+	events := make([]ds.Event, 0)
+	for _, event := range s.trace.Events() {
+		if filter(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Received returns the messages of the given type that node handled from the given sender.
+func (s *Simulation) Received(node ds.Address, messageType ds.MessageType, from ds.Address) []ds.Message {
+	messages := make([]ds.Message, 0)
+	for _, event := range s.trace.Events() {
+		if event.Kind == ds.HandleMessageEvent && event.To == node && event.From == from && event.Message.Type == messageType {
+			messages = append(messages, event.Message)
+		}
+	}
+	return messages
+}
+
+// AssertReceived fails the test if node did not handle a message of the given type from the given sender.
+func (s *Simulation) AssertReceived(node ds.Address, messageType ds.MessageType, from ds.Address) {
+	s.t.Helper()
+	if len(s.Received(node, messageType, from)) == 0 {
+		s.t.Errorf("%v did not receive %v from %v", node, messageType, from)
+	}
+}
+
+// AssertNotReceived fails the test if node handled a message of the given type from the given sender.
+func (s *Simulation) AssertNotReceived(node ds.Address, messageType ds.MessageType, from ds.Address) {
+	s.t.Helper()
+	if received := s.Received(node, messageType, from); len(received) > 0 {
+		s.t.Errorf("%v received %v from %v %d times", node, messageType, from, len(received))
+	}
+}
+
+// AssertSent fails the test if node did not send a message of the given type to the given receiver.
+func (s *Simulation) AssertSent(node ds.Address, messageType ds.MessageType, to ds.Address) {
+	s.t.Helper()
+	sent := s.Events(func(event ds.Event) bool {
+		return event.Kind == ds.SendMessageEvent && event.From == node && event.To == to && event.Message.Type == messageType
+	})
+	if len(sent) == 0 {
+		s.t.Errorf("%v did not send %v to %v", node, messageType, to)
+	}
+}
+
+// AssertNoDrops fails the test if any message was dropped.
+func (s *Simulation) AssertNoDrops() {
+	s.t.Helper()
+	dropped := s.Events(func(event ds.Event) bool {
+		return event.Kind == ds.DropMessageEvent
+	})
+	for _, event := range dropped {
+		s.t.Errorf("message dropped: %v", event)
+	}
+}
+
+// AssertState fails the test if the last recorded state of node is not the given state.
+func (s *Simulation) AssertState(node ds.Address, state ds.NodeState) {
+	s.t.Helper()
+	states := s.Events(func(event ds.Event) bool {
+		return event.Kind == ds.NodeStateEvent && event.To == node
+	})
+	if len(states) == 0 {
+		s.t.Errorf("no state recorded for %v", node)
+		return
+	}
+	if last := states[len(states)-1].NodeState; last != state {
+		s.t.Errorf("%v is %v, want %v", node, last, state)
+	}
+}
+
+// AssertEventually fails the test if any of the eventual properties is not satisfied by the trace.
+func (s *Simulation) AssertEventually(properties ...ds.EventuallyProperty) {
+	s.t.Helper()
+	if err := ds.CheckEventually(s.trace.Events(), properties...); err != nil {
+		s.t.Error(err)
+	}
+}
+
+// AssertLinearizable fails the test if the history is not linearizable with respect to the model.
+func (s *Simulation) AssertLinearizable(model lincheck.Model, history []lincheck.Operation) {
+	s.t.Helper()
+	if err := lincheck.Check(model, history); err != nil {
+		s.t.Error(err)
+	}
+}
@@ -0,0 +1,243 @@
+package disstest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/disstest"
+)
+
+const (
+	ping ds.MessageType = "Ping"
+	pong ds.MessageType = "Pong"
+)
+
+// pingNode sends a ping to its peer when it is initialized, and replies to every ping with a pong.
+type pingNode struct {
+	*ds.LocalNode
+	peer  ds.Address
+	pongs int
+}
+
+func newPingNode(sim *ds.LocalSimulation, address, peer ds.Address) *pingNode {
+	return &pingNode{LocalNode: ds.NewLocalNode(sim, address), peer: peer}
+}
+
+func (n *pingNode) Init(ctx context.Context) {
+	if n.peer != "" {
+		n.SendMessage(ctx, ds.NewMessage(ping, nil), n.peer)
+	}
+}
+
+func (n *pingNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	switch message.Type {
+	case ping:
+		n.SendMessage(ctx, ds.NewMessage(pong, nil), from)
+		return true
+	case pong:
+		n.pongs++
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *pingNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+// fakeTB records the failures of a test instead of failing it.
+type fakeTB struct {
+	testing.TB
+	failed   bool
+	messages []string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Cleanup(func()) {}
+
+func (t *fakeTB) Failed() bool {
+	return t.failed
+}
+
+func (t *fakeTB) Logf(format string, args ...any) {}
+
+func (t *fakeTB) Errorf(format string, args ...any) {
+	t.failed = true
+	t.messages = append(t.messages, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Error(args ...any) {
+	t.Errorf("%s", fmt.Sprint(args...))
+}
+
+func (t *fakeTB) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls fn with the fake test in a new goroutine, so that Fatalf can stop it.
+func (t *fakeTB) run(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+}
+
+func TestRunPasses(t *testing.T) {
+	sim := disstest.New(t, nil)
+	sim.AddNode(newPingNode(sim.LocalSimulation, "a", "b"))
+	sim.AddNode(newPingNode(sim.LocalSimulation, "b", ""))
+	sim.Run()
+
+	sim.AssertSent("a", ping, "b")
+	sim.AssertReceived("b", ping, "a")
+	sim.AssertReceived("a", pong, "b")
+	sim.AssertNotReceived("b", pong, "a")
+	sim.AssertNoDrops()
+	if n := len(sim.Received("a", pong, "b")); n != 1 {
+		t.Errorf("a received %d pongs, want 1", n)
+	}
+}
+
+func TestRunReportsSeedAndTrace(t *testing.T) {
+	fake := &fakeTB{}
+	fake.run(func() {
+		sim := disstest.New(fake, nil)
+		sim.SetSeed(7)
+		sim.AddNode(newPingNode(sim.LocalSimulation, "a", "b"))
+		sim.AddNode(newPingNode(sim.LocalSimulation, "b", ""))
+		sim.AddInvariant("no pongs", func(nodes map[ds.Address]ds.Node) error {
+			if nodes["a"].(*pingNode).pongs > 0 {
+				return errors.New("a received a pong")
+			}
+			return nil
+		})
+		sim.Run()
+	})
+
+	if !fake.failed || len(fake.messages) != 1 {
+		t.Fatalf("got failures %q, want one failure", fake.messages)
+	}
+	message := fake.messages[0]
+	for _, want := range []string{"seed 7", `invariant "no pongs" violated`, "causal history:", "SendMessage(a -> b, Ping", "HandleMessage(b -> a, Pong"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("failure %q does not contain %q", message, want)
+		}
+	}
+}
+
+func TestSetSeedGeneratesIds(t *testing.T) {
+	ping := func(seed int64) (string, ds.MessageId) {
+		sim := disstest.New(t, nil)
+		sim.SetSeed(seed)
+		sim.AddNode(newPingNode(sim.LocalSimulation, "a", "b"))
+		sim.AddNode(newPingNode(sim.LocalSimulation, "b", ""))
+		sim.Run()
+		return fmt.Sprint(sim.Trace()), sim.Received("b", ping, "a")[0].Id
+	}
+	first, firstId := ping(7)
+	second, secondId := ping(7)
+	if first != second {
+		t.Errorf("runs with the same seed have different traces:\n%s\n%s", first, second)
+	}
+	if _, otherId := ping(8); otherId == firstId {
+		t.Errorf("runs with different seeds logged the same id %v", firstId)
+	}
+	if secondId != firstId {
+		t.Errorf("runs with the same seed logged the ids %v and %v", firstId, secondId)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	fails := func(data []byte) bool {
+		return bytes.Contains(data, []byte{42, 7})
+	}
+	data := []byte{1, 2, 3, 42, 7, 4, 5, 6, 7, 8, 9}
+	if got, want := disstest.Shrink(data, fails), []byte{42, 7}; !bytes.Equal(got, want) {
+		t.Errorf("Shrink(%v) = %v, want %v", data, got, want)
+	}
+	if data[0] != 1 {
+		t.Errorf("Shrink modified its input")
+	}
+}
+
+func TestShrinkFuzzInput(t *testing.T) {
+	scenario := func(sim *ds.LocalSimulation) error {
+		for _, node := range []*pingNode{newPingNode(sim, "a", "b"), newPingNode(sim, "b", "")} {
+			if err := sim.AddNode(node); err != nil {
+				return err
+			}
+		}
+		sim.AddInvariant("b running", func(nodes map[ds.Address]ds.Node) error {
+			if nodes["b"].GetState() == ds.Stopped {
+				return errors.New("b stopped")
+			}
+			return nil
+		})
+		return nil
+	}
+	options := &disstest.FuzzOptions{
+		Simulation: &ds.LocalSimulationOptions{
+			MinLatency: ds.DefaultMinLatency,
+			MaxLatency: ds.DefaultMaxLatency,
+			Duration:   time.Second,
+		},
+		MaxFaults:  1,
+		FaultTypes: []ds.InterruptType{ds.StopInterrupt},
+	}
+	fails := func(data []byte) bool {
+		return disstest.RunBytes(scenario, options, data) != nil
+	}
+
+	// One fault that stops b, followed by scheduling decisions.
+	data := []byte{3, 7, 5, 200, 100, 50, 9, 9, 9, 9, 9}
+	if !fails(data) {
+		t.Fatalf("input %v does not fail", data)
+	}
+	if fails(nil) {
+		t.Fatalf("empty input fails")
+	}
+	// The count of faults and the node must stay 1, and every other byte can be removed or zeroed.
+	if got, want := disstest.Shrink(data, fails), []byte{1, 1, 0, 0, 0, 0}; !bytes.Equal(got, want) {
+		t.Errorf("Shrink(%v) = %v, want %v", data, got, want)
+	}
+}
+
+func FuzzPingPong(f *testing.F) {
+	disstest.Fuzz(f, func(sim *ds.LocalSimulation) error {
+		for _, node := range []*pingNode{newPingNode(sim, "a", "b"), newPingNode(sim, "b", ""), newPingNode(sim, "c", "b")} {
+			if err := sim.AddNode(node); err != nil {
+				return err
+			}
+		}
+		sim.AddInvariant("at most one pong", func(nodes map[ds.Address]ds.Node) error {
+			for _, address := range []ds.Address{"a", "c"} {
+				if pongs := nodes[address].(*pingNode).pongs; pongs > 1 {
+					return fmt.Errorf("%v received %d pongs", address, pongs)
+				}
+			}
+			return nil
+		})
+		return nil
+	}, &disstest.FuzzOptions{
+		Simulation: &ds.LocalSimulationOptions{
+			MinLatency: ds.DefaultMinLatency,
+			MaxLatency: ds.DefaultMaxLatency,
+			Duration:   time.Second,
+		},
+		MaxFaults:  1,
+		FaultTypes: []ds.InterruptType{ds.StopInterrupt, ds.SleepInterrupt},
+		MaxSleep:   100 * time.Millisecond,
+	})
+}
@@ -0,0 +1,203 @@
+package disstest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// ByteScheduler is a Scheduler whose decisions are read from a slice of bytes,
+// so that Go native fuzzing can search for schedules that violate invariants.
+//
+// Each decision reads one byte to choose among the enabled events and one byte to choose
+// the latency of the event between its earliest time and the latest time of any pending event.
+// Once the bytes are used up, the enabled event with the earliest time is processed next.
+type ByteScheduler struct {
+	data []byte
+}
+
+// NewByteScheduler creates a new ByteScheduler that reads its decisions from data.
+func NewByteScheduler(data []byte) *ByteScheduler {
+	return &ByteScheduler{data: data}
+}
+
+// Next returns the event and time chosen by the next two bytes.
+func (s *ByteScheduler) Next(now time.Duration, pending []ds.PendingEvent, enabled []int) (int, time.Duration) {
+	if len(s.data) < 2 {
+		earliest := enabled[0]
+		for _, i := range enabled {
+			if pending[i].Earliest < pending[earliest].Earliest {
+				earliest = i
+			}
+		}
+		return earliest, pending[earliest].Earliest
+	}
+	choice, latency := s.data[0], s.data[1]
+	s.data = s.data[2:]
+	next := enabled[int(choice)%len(enabled)]
+	deadline := pending[next].Latest
+	for _, event := range pending {
+		if event.Latest < deadline {
+			deadline = event.Latest
+		}
+	}
+	earliest := pending[next].Earliest
+	if deadline <= earliest {
+		return next, earliest
+	}
+	return next, earliest + (deadline-earliest)*time.Duration(latency)/255
+}
+
+// FuzzOptions is used to set the options for fuzzing a scenario.
+type FuzzOptions struct {
+	// Simulation is used to create the simulation of every fuzzing input.
+	Simulation *ds.LocalSimulationOptions
+	// MaxFaults is the maximum number of faults injected into each run.
+	MaxFaults int
+	// FaultTypes are the types of interrupts used as faults, which are StopInterrupt and SleepInterrupt.
+	FaultTypes []ds.InterruptType
+	// MaxSleep is the maximum duration of a SleepInterrupt fault.
+	MaxSleep time.Duration
+}
+
+// FaultsFromBytes reads up to maxFaults faults for the given nodes from data and returns them with the unread bytes.
+//
+// The first byte chooses the number of faults, and every fault reads one byte to choose the node,
+// one byte to choose the fault type, two bytes to choose the time of the fault within the duration,
+// and one byte to choose the duration of a sleep within maxSleep.
+func FaultsFromBytes(data []byte, nodes []ds.Address, faultTypes []ds.InterruptType, duration, maxSleep time.Duration, maxFaults int) ([]ds.Fault, []byte) {
+	faults := make([]ds.Fault, 0)
+	if len(data) == 0 || len(nodes) == 0 || len(faultTypes) == 0 || maxFaults <= 0 {
+		return faults, data
+	}
+	count := int(data[0]) % (maxFaults + 1)
+	data = data[1:]
+	for i := 0; i < count && len(data) >= 5; i++ {
+		at := time.Duration(uint16(data[2])<<8|uint16(data[3])) * duration / (1 << 16)
+		fault := ds.Fault{
+			At:   at,
+			Node: nodes[int(data[0])%len(nodes)],
+			Type: faultTypes[int(data[1])%len(faultTypes)],
+		}
+		if fault.Type == ds.SleepInterrupt {
+			fault.Duration = maxSleep * time.Duration(data[4]) / 255
+		}
+		faults = append(faults, fault)
+		data = data[5:]
+	}
+	return faults, data
+}
+
+// Fuzz runs the scenario with Go native fuzzing, using the fuzzing input to choose the faults
+// injected into the simulation and every scheduling decision.
+//
+// The test fails if an invariant or eventual property is violated, in which case go test minimizes
+// the failing input and saves it in testdata/fuzz so that it is run again as a regression case.
+// Inputs found outside of go test can be run with RunBytes and shrunk with Shrink.
+func Fuzz(f *testing.F, scenario ds.Scenario, options *FuzzOptions) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 255, 1, 128, 2, 255})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		err := RunBytes(scenario, options, data)
+		var setup *setupError
+		if errors.As(err, &setup) {
+			t.Fatal(err)
+		}
+		if err != nil {
+			t.Fatalf("simulation failed: %v\n%s", err, formatTrace(err, nil))
+		}
+	})
+}
+
+// setupError is the error returned by RunBytes when the scenario cannot be set up.
+type setupError struct {
+	err error
+}
+
+// Error returns a string representation of the error.
+func (e *setupError) Error() string {
+	return fmt.Sprintf("failed to set up scenario: %v", e.err)
+}
+
+// Unwrap returns the error that prevented the scenario from being set up.
+func (e *setupError) Unwrap() error {
+	return e.err
+}
+
+// RunBytes runs the scenario with the faults and scheduling decisions read from data, as done by Fuzz,
+// and returns the violation it causes, if any.
+//
+// An error is also returned if the scenario cannot be set up. If the options are nil, the default options of Fuzz are used.
+func RunBytes(scenario ds.Scenario, options *FuzzOptions, data []byte) error {
+	if options == nil {
+		options = &FuzzOptions{
+			MaxFaults:  ds.DefaultMaxFaults,
+			FaultTypes: []ds.InterruptType{ds.StopInterrupt},
+			MaxSleep:   ds.DefaultMaxSleep,
+		}
+	}
+	simOptions := simulationOptions(options.Simulation)
+	sim := ds.NewLocalSimulation(simOptions)
+	if err := scenario(sim); err != nil {
+		return &setupError{err}
+	}
+	faults, data := FaultsFromBytes(data, sortedAddresses(sim), options.FaultTypes, simOptions.Duration, options.MaxSleep, options.MaxFaults)
+	for _, fault := range faults {
+		if err := sim.InjectFault(fault); err != nil {
+			return &setupError{err}
+		}
+	}
+	return sim.RunScheduled(NewByteScheduler(data))
+}
+
+// Shrink returns the smallest variant of data for which fails returns true, where data must fail.
+//
+// Chunks of bytes are removed, halving the size of the chunks down to single bytes, and the
+// remaining bytes are then set to zero, halved or decremented, until no smaller variant fails. As fewer bytes mean
+// fewer faults and decisions, the result is usually a minimal reproducer of the failure.
+func Shrink(data []byte, fails func(data []byte) bool) []byte {
+	data = append([]byte(nil), data...)
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for size := (len(data) + 1) / 2; size > 0; size /= 2 {
+			for i := 0; i+size <= len(data); {
+				candidate := append(append([]byte(nil), data[:i]...), data[i+size:]...)
+				if fails(candidate) {
+					data, shrunk = candidate, true
+					continue
+				}
+				i++
+			}
+		}
+		for i := range data {
+			for _, value := range []byte{0, data[i] / 2, data[i] - 1} {
+				if value >= data[i] {
+					continue
+				}
+				candidate := append([]byte(nil), data...)
+				candidate[i] = value
+				if fails(candidate) {
+					data, shrunk = candidate, true
+					break
+				}
+			}
+		}
+	}
+	return data
+}
+
+// sortedAddresses returns the addresses of the top level nodes of the simulation in lexicographic order.
+func sortedAddresses(sim *ds.LocalSimulation) []ds.Address {
+	addresses := make([]ds.Address, 0)
+	for address := range sim.GetNodes() {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+	return addresses
+}
@@ -0,0 +1,186 @@
+package disse
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// FsmState is a string that identifies a state of a finite state machine.
+type FsmState string
+
+// AnyState matches every state in the From field of a Transition.
+const AnyState FsmState = "*"
+
+// FsmStater is implemented by nodes that are finite state machines.
+//
+// Loggers report the state of the machine along with the state of the node.
+type FsmStater interface {
+	GetFsmState() FsmState
+}
+
+// FsmEvent is the message or timer that triggered a transition.
+//
+// Only the fields for the kind of trigger are set.
+type FsmEvent struct {
+	Message  Message
+	From     Address
+	Timer    Timer
+	Duration time.Duration
+}
+
+// FsmGuard returns true if a transition may be taken for the event.
+type FsmGuard func(ctx context.Context, node *FsmNode, event FsmEvent) bool
+
+// FsmAction is called when a transition is taken, before the machine enters the next state.
+type FsmAction func(ctx context.Context, node *FsmNode, event FsmEvent)
+
+// Transition is a transition of a finite state machine, triggered by a message type or a timer type.
+//
+// From is the state the transition leaves, or AnyState. To is the state the transition enters,
+// or empty to stay in the current state. Guard and Action are optional, and GuardName is the
+// name of the guard shown in the state diagram.
+type Transition struct {
+	From      FsmState
+	To        FsmState
+	Message   MessageType
+	Timer     TimerType
+	Guard     FsmGuard
+	GuardName string
+	Action    FsmAction
+}
+
+// FsmSpec declares the states and transitions of a finite state machine.
+//
+// Init is called when the node is initialized, before the machine enters the initial state, and
+// OnEnter is called whenever the machine enters a state. Both are optional.
+type FsmSpec struct {
+	Initial     FsmState
+	Transitions []Transition
+	Init        func(ctx context.Context, node *FsmNode)
+	OnEnter     map[FsmState]func(ctx context.Context, node *FsmNode)
+}
+
+// StateDiagram returns a PlantUML state diagram of the machine.
+//
+// Transitions from AnyState are drawn from every state, and transitions that stay
+// in the same state are listed inside the state instead of drawn as arrows.
+func (spec *FsmSpec) StateDiagram() string {
+	states := []FsmState{spec.Initial}
+	seen := map[FsmState]bool{spec.Initial: true}
+	for _, t := range spec.Transitions {
+		for _, state := range []FsmState{t.From, t.To} {
+			if state != "" && state != AnyState && !seen[state] {
+				seen[state] = true
+				states = append(states, state)
+			}
+		}
+	}
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	b.WriteString("hide empty description\n")
+	fmt.Fprintf(&b, "[*] --> %v\n", spec.Initial)
+	for _, t := range spec.Transitions {
+		trigger := string(t.Message)
+		if t.Timer != "" {
+			trigger = string(t.Timer)
+		}
+		if t.GuardName != "" {
+			trigger = fmt.Sprintf("%v [%v]", trigger, t.GuardName)
+		}
+		from := []FsmState{t.From}
+		if t.From == AnyState {
+			from = states
+		}
+		for _, state := range from {
+			if t.To == "" || t.To == state {
+				fmt.Fprintf(&b, "%v : %v\n", state, trigger)
+			} else {
+				fmt.Fprintf(&b, "%v --> %v : %v\n", state, t.To, trigger)
+			}
+		}
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// FsmNode is a node whose behaviour is declared by an FsmSpec instead of message and timer handlers.
+//
+// Every message or timer is matched against the transitions of the spec in order, and the first
+// transition that leaves the current state, is triggered by the message or timer type and whose guard
+// holds is taken. Messages and timers that match no transition are left to sub nodes or dropped.
+// The state of the machine is logged with LogNodeState whenever it changes.
+type FsmNode struct {
+	*LocalNode
+	spec    *FsmSpec
+	current FsmState
+}
+
+// NewFsmNode creates a new FsmNode with the given address that runs the machine declared by spec.
+func NewFsmNode(sim *LocalSimulation, address Address, spec *FsmSpec) *FsmNode {
+	return &FsmNode{
+		LocalNode: NewLocalNode(sim, address),
+		spec:      spec,
+	}
+}
+
+// GetFsmState returns the current state of the machine.
+func (n *FsmNode) GetFsmState() FsmState {
+	return n.current
+}
+
+// Init is called when the node is initialized by the simulation, and enters the initial state.
+func (n *FsmNode) Init(ctx context.Context) {
+	if n.spec.Init != nil {
+		n.spec.Init(ctx, n)
+	}
+	n.current = n.spec.Initial
+	if enter, ok := n.spec.OnEnter[n.current]; ok {
+		enter(ctx, n)
+	}
+}
+
+// HandleMessage is called when the node receives a message.
+func (n *FsmNode) HandleMessage(ctx context.Context, message Message, from Address) bool {
+	return n.fire(ctx, FsmEvent{Message: message, From: from}, func(t Transition) bool {
+		return t.Message != "" && t.Message == message.Type
+	})
+}
+
+// HandleTimer is called when a node receives a timer.
+func (n *FsmNode) HandleTimer(ctx context.Context, timer Timer, duration time.Duration) bool {
+	return n.fire(ctx, FsmEvent{Timer: timer, Duration: duration}, func(t Transition) bool {
+		return t.Timer != "" && t.Timer == timer.Type
+	})
+}
+
+// fire takes the first transition from the current state that is triggered by the event and whose guard holds.
+func (n *FsmNode) fire(ctx context.Context, event FsmEvent, triggered func(Transition) bool) bool {
+	for _, t := range n.spec.Transitions {
+		if t.From != n.current && t.From != AnyState {
+			continue
+		}
+		if !triggered(t) || (t.Guard != nil && !t.Guard(ctx, n, event)) {
+			continue
+		}
+		if t.Action != nil {
+			t.Action(ctx, n, event)
+		}
+		if t.To != "" && t.To != n.current {
+			n.current = t.To
+			n.sim.LogNodeState(n)
+			if enter, ok := n.spec.OnEnter[n.current]; ok {
+				enter(ctx, n)
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// WriteStateDiagram writes the PlantUML state diagram of the machine to the file at the given path.
+func (n *FsmNode) WriteStateDiagram(path string) error {
+	return os.WriteFile(path, []byte(n.spec.StateDiagram()), 0644)
+}
@@ -0,0 +1,128 @@
+package disse_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const (
+	open      ds.MessageType = "Open"
+	closeDoor ds.MessageType = "Close"
+	lock      ds.MessageType = "Lock"
+	knock     ds.MessageType = "Knock"
+	autoClose ds.TimerType   = "AutoClose"
+	next      ds.TimerType   = "Next"
+)
+
+// doorSpec returns the spec of a door that closes itself, can only be locked with a key,
+// and records the states it enters and the knocks it hears.
+func doorSpec(entered *[]ds.FsmState, knocks *int) *ds.FsmSpec {
+	record := func(ctx context.Context, node *ds.FsmNode) {
+		*entered = append(*entered, node.GetFsmState())
+	}
+	return &ds.FsmSpec{
+		Initial: "Closed",
+		Transitions: []ds.Transition{
+			{From: "Closed", To: "Opened", Message: open},
+			{From: "Opened", To: "Closed", Message: closeDoor},
+			{From: "Opened", To: "Closed", Timer: autoClose},
+			{From: "Closed", To: "Locked", Message: lock, GuardName: "key", Guard: func(ctx context.Context, node *ds.FsmNode, event ds.FsmEvent) bool {
+				return event.Message.Data == "key"
+			}},
+			{From: ds.AnyState, Message: knock, Action: func(ctx context.Context, node *ds.FsmNode, event ds.FsmEvent) {
+				*knocks++
+			}},
+		},
+		OnEnter: map[ds.FsmState]func(ctx context.Context, node *ds.FsmNode){
+			"Closed": record,
+			"Locked": record,
+			"Opened": func(ctx context.Context, node *ds.FsmNode) {
+				record(ctx, node)
+				node.SetTimer(ctx, ds.NewTimer(autoClose, nil), 5*time.Millisecond)
+			},
+		},
+	}
+}
+
+// scriptNode sends its messages to the door one at a time, 10 milliseconds apart.
+type scriptNode struct {
+	*ds.LocalNode
+	script []ds.Message
+}
+
+func (n *scriptNode) Init(ctx context.Context) {
+	n.SetTimer(ctx, ds.NewTimer(next, nil), 10*time.Millisecond)
+}
+
+func (n *scriptNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	return false
+}
+
+func (n *scriptNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	n.SendMessage(ctx, n.script[0], "door")
+	n.script = n.script[1:]
+	if len(n.script) > 0 {
+		n.SetTimer(ctx, ds.NewTimer(next, nil), 10*time.Millisecond)
+	}
+	return true
+}
+
+func TestFsmNodeTransitions(t *testing.T) {
+	entered, knocks := make([]ds.FsmState, 0), 0
+	sim := ds.NewLocalSimulation(testOptions())
+	door := ds.NewFsmNode(sim, "door", doorSpec(&entered, &knocks))
+	sim.AddNode(door)
+	sim.AddNode(&scriptNode{LocalNode: ds.NewLocalNode(sim, "script"), script: []ds.Message{
+		ds.NewMessage(knock, nil),
+		ds.NewMessage(lock, "no key"),
+		ds.NewMessage(open, nil),
+		ds.NewMessage(knock, nil),
+		ds.NewMessage(lock, "key"),
+		ds.NewMessage(open, nil),
+	}})
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ds.FsmState{"Closed", "Opened", "Closed", "Locked"}
+	if len(entered) != len(want) {
+		t.Fatalf("entered %v, want %v", entered, want)
+	}
+	for i := range want {
+		if entered[i] != want[i] {
+			t.Fatalf("entered %v, want %v", entered, want)
+		}
+	}
+	if door.GetFsmState() != "Locked" {
+		t.Errorf("door is %v, want Locked", door.GetFsmState())
+	}
+	if knocks != 2 {
+		t.Errorf("door heard %d knocks, want 2", knocks)
+	}
+	if door.HandleMessage(context.Background(), ds.NewMessage(open, nil), "script") {
+		t.Errorf("a locked door handled Open")
+	}
+}
+
+func TestStateDiagram(t *testing.T) {
+	entered, knocks := make([]ds.FsmState, 0), 0
+	got := doorSpec(&entered, &knocks).StateDiagram()
+	want := `@startuml
+hide empty description
+[*] --> Closed
+Closed --> Opened : Open
+Opened --> Closed : Close
+Opened --> Closed : AutoClose
+Closed --> Locked : Lock [key]
+Closed : Knock
+Opened : Knock
+Locked : Knock
+@enduml
+`
+	if got != want {
+		t.Errorf("StateDiagram() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+package disse
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// idGenerator replaces the ids of messages, timers and interrupts in the logs of a simulation
+// with ids drawn from its own seeded source, so the logs of a run are reproducible.
+//
+// Nodes keep the ids they were created with, as they only compare them with each other. Every id is
+// replaced by the same id every time it is logged, including when it is the parent of another event
+// or the request of a reply.
+type idGenerator struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+	ids  map[string]string
+}
+
+// newIdGenerator creates a new idGenerator with the given seed.
+func newIdGenerator(seed int64) *idGenerator {
+	return &idGenerator{
+		rand: rand.New(rand.NewSource(seed)),
+		ids:  make(map[string]string),
+	}
+}
+
+// id returns the generated id that replaces the given id, generating it if needed.
+//
+// Empty ids are not replaced.
+func (g *idGenerator) id(id string) string {
+	if g == nil || id == "" {
+		return id
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	generated, ok := g.ids[id]
+	if !ok {
+		generated = uuid.Must(uuid.NewRandomFromReader(g.rand)).String()
+		g.ids[id] = generated
+	}
+	return generated
+}
+
+// message returns the message with its ids replaced.
+func (g *idGenerator) message(message Message) Message {
+	if g == nil {
+		return message
+	}
+	message.Id = MessageId(g.id(string(message.Id)))
+	message.ReplyTo = MessageId(g.id(string(message.ReplyTo)))
+	message.ParentId = g.id(message.ParentId)
+	return message
+}
+
+// timer returns the timer with its ids replaced.
+func (g *idGenerator) timer(timer Timer) Timer {
+	if g == nil {
+		return timer
+	}
+	timer.Id = TimerId(g.id(string(timer.Id)))
+	timer.ParentId = g.id(timer.ParentId)
+	return timer
+}
+
+// interrupt returns the interrupt with its ids replaced.
+func (g *idGenerator) interrupt(interrupt Interrupt) Interrupt {
+	if g == nil {
+		return interrupt
+	}
+	interrupt.Id = InterruptId(g.id(string(interrupt.Id)))
+	interrupt.ParentId = g.id(interrupt.ParentId)
+	return interrupt
+}
@@ -0,0 +1,130 @@
+package disse
+
+import (
+	"context"
+	"time"
+)
+
+// InterceptPhase is a string that identifies when an interceptor is called.
+//
+// It can be either SendPhase or DeliverPhase.
+type InterceptPhase string
+
+const (
+	// SendPhase is when a message is sent, before latency is added to it.
+	SendPhase InterceptPhase = "Send"
+	// DeliverPhase is when a message arrives at its destination, before it is handled.
+	DeliverPhase InterceptPhase = "Deliver"
+)
+
+// Interception is a message returned by an interceptor, with an extra delay added before it is sent or handled.
+type Interception struct {
+	MessageTriplet
+	Delay time.Duration
+}
+
+// Interceptor inspects and modifies every message sent and delivered in a simulation.
+//
+// Intercept returns the messages that take the place of the given message: none to drop it,
+// a single possibly modified message to let it through, or several to duplicate it.
+// Each message may be delayed. Interceptors are called one at a time, in the order they were added,
+// and each interceptor is called with every message returned by the previous one.
+//
+// Messages may be redirected to another node when they are sent, but not once they are delivered.
+// Messages redirected when they are delivered, or to an address that is not a node of the simulation, are dropped.
+type Interceptor interface {
+	Intercept(phase InterceptPhase, mt MessageTriplet) []Interception
+}
+
+// InterceptorFunc is a function that implements Interceptor.
+type InterceptorFunc func(phase InterceptPhase, mt MessageTriplet) []Interception
+
+// Intercept calls f(phase, mt).
+func (f InterceptorFunc) Intercept(phase InterceptPhase, mt MessageTriplet) []Interception {
+	return f(phase, mt)
+}
+
+// Pass returns an interception that lets the message through unchanged.
+func Pass(mt MessageTriplet) []Interception {
+	return []Interception{{MessageTriplet: mt}}
+}
+
+// DropNth returns an interceptor that drops the nth message that matches when it is sent, counting from 1.
+func DropNth(match func(mt MessageTriplet) bool, n int) Interceptor {
+	count := 0
+	return InterceptorFunc(func(phase InterceptPhase, mt MessageTriplet) []Interception {
+		if phase != SendPhase || !match(mt) {
+			return Pass(mt)
+		}
+		count++
+		if count == n {
+			return nil
+		}
+		return Pass(mt)
+	})
+}
+
+// AddInterceptor adds an interceptor to the simulation.
+func (s *LocalSimulation) AddInterceptor(interceptor Interceptor) {
+	s.interceptors = append(s.interceptors, interceptor)
+}
+
+// intercept passes a message through every interceptor of the simulation and returns the messages to queue
+// or handle in its place.
+//
+// The message is dropped if the interceptors return no messages, and so are the returned messages that
+// are redirected when they are delivered or sent to an address that is not a node of the simulation.
+func (s *LocalSimulation) intercept(phase InterceptPhase, mt MessageTriplet) []Interception {
+	interceptions := s.runInterceptors(phase, mt)
+	if len(interceptions) == 0 {
+		s.LogDropMessage(mt.From, mt.To, mt.Message)
+		return nil
+	}
+	valid := make([]Interception, 0, len(interceptions))
+	for _, i := range interceptions {
+		if _, ok := s.nodes[i.To]; !ok || (phase == DeliverPhase && i.To != mt.To) {
+			s.LogDropMessage(i.From, i.To, i.Message)
+			continue
+		}
+		valid = append(valid, i)
+	}
+	return valid
+}
+
+// runInterceptors passes a message through every interceptor of the simulation.
+func (s *LocalSimulation) runInterceptors(phase InterceptPhase, mt MessageTriplet) []Interception {
+	s.interceptMu.Lock()
+	defer s.interceptMu.Unlock()
+	interceptions := Pass(mt)
+	for _, interceptor := range s.interceptors {
+		next := make([]Interception, 0, len(interceptions))
+		for _, i := range interceptions {
+			for _, result := range interceptor.Intercept(phase, i.MessageTriplet) {
+				result.Delay += i.Delay
+				next = append(next, result)
+			}
+		}
+		interceptions = next
+	}
+	return interceptions
+}
+
+// deliverMessage handles a message that arrived at its destination, or drops it if no handler is found.
+//
+// If intercept is true, the message is first passed through the interceptors, and delayed
+// messages are queued again to be handled after their delay without being intercepted again.
+func (s *LocalSimulation) deliverMessage(ctx context.Context, mt MessageTriplet, intercept bool) {
+	if !intercept || len(s.interceptors) == 0 {
+		if handled := s.handleMessage(ctx, mt); !handled {
+			s.dropMessage(ctx, mt)
+		}
+		return
+	}
+	for _, i := range s.intercept(DeliverPhase, mt) {
+		if i.Delay <= 0 {
+			s.deliverMessage(ctx, i.MessageTriplet, false)
+			continue
+		}
+		s.queueMessage(i.MessageTriplet, i.Delay, true)
+	}
+}
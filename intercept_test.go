@@ -0,0 +1,154 @@
+package disse_test
+
+import (
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// ofMessageType returns a function that matches messages of the given type.
+func ofMessageType(messageType ds.MessageType) func(mt ds.MessageTriplet) bool {
+	return func(mt ds.MessageTriplet) bool {
+		return mt.Message.Type == messageType
+	}
+}
+
+// interceptPing returns an interceptor that replaces pings in the given phase with the result of f.
+func interceptPing(phase ds.InterceptPhase, f func(mt ds.MessageTriplet) []ds.Interception) ds.Interceptor {
+	return ds.InterceptorFunc(func(p ds.InterceptPhase, mt ds.MessageTriplet) []ds.Interception {
+		if p != phase || mt.Message.Type != ping {
+			return ds.Pass(mt)
+		}
+		return f(mt)
+	})
+}
+
+// interceptedEvents returns the events of the given kind and message type in a trace.
+func interceptedEvents(trace *ds.TraceLogger, kind ds.EventKind, messageType ds.MessageType) []ds.Event {
+	events := make([]ds.Event, 0)
+	for _, event := range trace.Events() {
+		if event.Kind == kind && event.Message.Type == messageType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestInterceptors(t *testing.T) {
+	redirect := func(to ds.Address) func(mt ds.MessageTriplet) []ds.Interception {
+		return func(mt ds.MessageTriplet) []ds.Interception {
+			mt.To = to
+			return ds.Pass(mt)
+		}
+	}
+	tests := []struct {
+		name        string
+		interceptor ds.Interceptor
+		handled     map[ds.Address]int
+		drops       int
+	}{
+		{
+			name:        "drop nth",
+			interceptor: ds.DropNth(ofMessageType(ping), 1),
+			handled:     map[ds.Address]int{"b": 0, "c": 0},
+			drops:       1,
+		},
+		{
+			name: "duplicate",
+			interceptor: interceptPing(ds.SendPhase, func(mt ds.MessageTriplet) []ds.Interception {
+				return append(ds.Pass(mt), ds.Pass(mt)...)
+			}),
+			handled: map[ds.Address]int{"b": 2, "c": 0},
+		},
+		{
+			name:        "redirect when sent",
+			interceptor: interceptPing(ds.SendPhase, redirect("c")),
+			handled:     map[ds.Address]int{"b": 0, "c": 1},
+		},
+		{
+			name:        "redirect when delivered",
+			interceptor: interceptPing(ds.DeliverPhase, redirect("c")),
+			handled:     map[ds.Address]int{"b": 0, "c": 0},
+			drops:       1,
+		},
+		{
+			name:        "redirect to an unknown node",
+			interceptor: interceptPing(ds.SendPhase, redirect("z")),
+			handled:     map[ds.Address]int{"b": 0, "c": 0},
+			drops:       1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sim, a, _ := newPingSimulation(testOptions())
+			sim.AddNode(newPingNode(sim, "c"))
+			sim.AddInterceptor(test.interceptor)
+			trace := ds.NewTraceLogger(sim)
+			sim.AddLogger(trace)
+			if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			handled := map[ds.Address]int{"b": 0, "c": 0}
+			for _, event := range interceptedEvents(trace, ds.HandleMessageEvent, ping) {
+				handled[event.To]++
+			}
+			for address, want := range test.handled {
+				if handled[address] != want {
+					t.Errorf("%v handled %d pings, want %d", address, handled[address], want)
+				}
+			}
+			if drops := len(interceptedEvents(trace, ds.DropMessageEvent, ping)); drops != test.drops {
+				t.Errorf("%d pings dropped, want %d", drops, test.drops)
+			}
+			if want := test.handled["b"] + test.handled["c"]; a.pongs != want {
+				t.Errorf("a received %d pongs, want %d", a.pongs, want)
+			}
+		})
+	}
+}
+
+func TestInterceptorDelay(t *testing.T) {
+	for _, phase := range []ds.InterceptPhase{ds.SendPhase, ds.DeliverPhase} {
+		sim, _, _ := newPingSimulation(testOptions())
+		delay := 20 * time.Millisecond
+		sim.AddInterceptor(interceptPing(phase, func(mt ds.MessageTriplet) []ds.Interception {
+			return []ds.Interception{{MessageTriplet: mt, Delay: delay}}
+		}))
+		trace := ds.NewTraceLogger(sim)
+		sim.AddLogger(trace)
+		if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+			t.Fatal(err)
+		}
+
+		handled := interceptedEvents(trace, ds.HandleMessageEvent, ping)
+		if len(handled) != 1 {
+			t.Fatalf("%v: ping handled %d times, want once", phase, len(handled))
+		}
+		sent := interceptedEvents(trace, ds.SendMessageEvent, ping)[0]
+		options := testOptions()
+		if elapsed := handled[0].Time - sent.Time; elapsed < delay+options.MinLatency || elapsed > delay+options.MaxLatency {
+			t.Errorf("%v: ping handled %v after it was sent, want a delay of %v and latency", phase, elapsed, delay)
+		}
+	}
+}
+
+func TestInterceptorRedirectToUnknownNodeInRealTime(t *testing.T) {
+	sim, a, _ := newPingSimulation(testOptions())
+	sim.AddInterceptor(interceptPing(ds.SendPhase, func(mt ds.MessageTriplet) []ds.Interception {
+		mt.To = "z"
+		return ds.Pass(mt)
+	}))
+	trace := ds.NewTraceLogger(sim)
+	sim.AddLogger(trace)
+	sim.Run()
+
+	drops := interceptedEvents(trace, ds.DropMessageEvent, ping)
+	if len(drops) != 1 || drops[0].To != "z" {
+		t.Errorf("dropped pings %v, want the ping redirected to z", drops)
+	}
+	if a.pongs != 0 {
+		t.Errorf("a received %d pongs, want 0", a.pongs)
+	}
+}
@@ -26,10 +26,13 @@ type InterruptData any
 // Interrupt is a message that is sent to a node to interrupt its execution in some way.
 //
 // It is used to stop a node, to make it sleep for a while or to make it start again.
+// ParentId is the id of the message, timer or interrupt that the sender was handling when it sent
+// the interrupt, taken from the context given to the handler, and is empty for interrupts sent with any other context.
 type Interrupt struct {
-	Id   InterruptId
-	Type InterruptType
-	Data InterruptData
+	Id       InterruptId
+	Type     InterruptType
+	Data     InterruptData
+	ParentId string
 }
 
 // String returns a string representation of the interrupt for debugging purposes.
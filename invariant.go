@@ -0,0 +1,100 @@
+package disse
+
+import (
+	"fmt"
+	"os"
+)
+
+// Invariant is a safety property over the nodes of a simulation.
+//
+// It returns a non-nil error describing the violation if the property does not hold.
+// Invariants are called with the top level nodes of the simulation, keyed by address.
+type Invariant func(nodes map[Address]Node) error
+
+// namedInvariant is an invariant registered with a simulation.
+type namedInvariant struct {
+	name      string
+	invariant Invariant
+}
+
+// InvariantViolation is the error returned when an invariant does not hold.
+//
+// It contains the event after which the invariant was found to be violated, and its causal history as
+// returned by CausalHistory, which is the events that happened before it followed by the event itself.
+// If no event was recorded before the violation, the history is the whole trace.
+type InvariantViolation struct {
+	Invariant string
+	Err       error
+	Event     Event
+	Trace     []Event
+}
+
+// Error returns a string representation of the violation.
+func (v *InvariantViolation) Error() string {
+	return fmt.Sprintf("invariant %q violated after %v: %v", v.Invariant, v.Event, v.Err)
+}
+
+// Unwrap returns the error returned by the invariant.
+func (v *InvariantViolation) Unwrap() error {
+	return v.Err
+}
+
+// WriteFile writes the violation and its causal history to the file at the given path.
+func (v *InvariantViolation) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "Invariant: %v\n", v.Invariant)
+	fmt.Fprintf(file, "Violation: %v\n", v.Err)
+	fmt.Fprintf(file, "Event: %v\n", v.Event)
+	fmt.Fprintf(file, "Causal history:\n")
+	for _, event := range v.Trace {
+		fmt.Fprintf(file, "%v\n", event)
+	}
+	return nil
+}
+
+// AddInvariant registers an invariant that is checked after every handled message, timer and interrupt.
+//
+// The first violation stops the simulation and is returned by Run.
+func (s *LocalSimulation) AddInvariant(name string, invariant Invariant) {
+	s.invariants = append(s.invariants, namedInvariant{name, invariant})
+	s.recorder()
+}
+
+// recorder returns the trace logger of the simulation, creating it if needed.
+func (s *LocalSimulation) recorder() *TraceLogger {
+	if s.trace == nil {
+		s.trace = NewTraceLogger(s)
+		s.AddLogger(s.trace)
+	}
+	return s.trace
+}
+
+// checkInvariants checks all invariants in the order they were added.
+//
+// The event at index mark of the trace is reported as the event that caused the violation.
+// It returns the first violation found, or nil if all invariants hold.
+func (s *LocalSimulation) checkInvariants(mark int) *InvariantViolation {
+	if len(s.invariants) == 0 {
+		return nil
+	}
+	for _, ni := range s.invariants {
+		if err := ni.invariant(s.nodes); err != nil {
+			trace := s.trace.Events()
+			violation := &InvariantViolation{
+				Invariant: ni.name,
+				Err:       err,
+				Trace:     trace,
+			}
+			if mark < len(trace) {
+				violation.Event = trace[mark]
+				violation.Trace = CausalHistory(trace, mark)
+			}
+			return violation
+		}
+	}
+	return nil
+}
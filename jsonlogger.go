@@ -0,0 +1,204 @@
+package disse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JsonEvent is a single event written by a JsonLogger, as one line of a JSON Lines file.
+//
+// Time is the time of the simulation in nanoseconds and Wall is the wall clock time at which the event was logged.
+// Id, Type, Data, ReplyTo and ParentId belong to the message, timer or interrupt of the event, depending on its kind.
+// Data is the JSON encoding of the data, or a JSON string of its debug representation if it cannot be encoded.
+// Lamport and Vector are the logical clocks of the node at which the event occurred, and MessageLamport and
+// MessageVector are the clocks the message was stamped with, if the simulation tracks logical clocks.
+type JsonEvent struct {
+	Kind            EventKind       `json:"kind"`
+	Time            time.Duration   `json:"time"`
+	Wall            time.Time       `json:"wall"`
+	From            Address         `json:"from,omitempty"`
+	To              Address         `json:"to,omitempty"`
+	Id              string          `json:"id,omitempty"`
+	Type            string          `json:"type,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	ReplyTo         MessageId       `json:"replyTo,omitempty"`
+	ParentId        string          `json:"parentId,omitempty"`
+	Duration        time.Duration   `json:"duration,omitempty"`
+	NodeState       NodeState       `json:"nodeState,omitempty"`
+	FsmState        FsmState        `json:"fsmState,omitempty"`
+	SimulationState SimulationState `json:"simulationState,omitempty"`
+	Lamport         uint64          `json:"lamport,omitempty"`
+	Vector          VectorClock     `json:"vector,omitempty"`
+	MessageLamport  uint64          `json:"messageLamport,omitempty"`
+	MessageVector   VectorClock     `json:"messageVector,omitempty"`
+}
+
+// encodeData returns the JSON encoding of the data of a message, timer or interrupt.
+func encodeData(data any) json.RawMessage {
+	if data == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(data))
+	}
+	return encoded
+}
+
+// JsonLogger is a Logger implementation that writes every event as a JSON object on its own line.
+//
+// The file can be read back with the tracefile package. It is safe to use from multiple goroutines.
+// Events that cannot be written are skipped, and the first error is returned by Err.
+type JsonLogger struct {
+	clock   Clock
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewJsonLogger creates a new JsonLogger that logs to the given file and timestamps events using the given clock.
+func NewJsonLogger(logPath string, clock Clock) (*JsonLogger, error) {
+	logfile, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewJsonLoggerWithWriter(logfile, clock), nil
+}
+
+// NewJsonLoggerWithWriter creates a new JsonLogger that logs to the given writer and timestamps events using the given clock.
+func NewJsonLoggerWithWriter(w io.Writer, clock Clock) *JsonLogger {
+	return &JsonLogger{
+		clock:   clock,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// write timestamps an event and writes it as a line of JSON.
+func (l *JsonLogger) write(event JsonEvent) {
+	event.Time = l.clock.Now()
+	event.Wall = time.Now()
+	if lc, ok := l.clock.(LogicalClocker); ok && event.Kind != SimulationStateEvent {
+		node := event.To
+		if event.Kind == SendMessageEvent || event.Kind == SendInterruptEvent {
+			node = event.From
+		}
+		event.Lamport, event.Vector = lc.LogicalClock(node)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.encoder.Encode(event); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+// Err returns the first error that occurred while writing an event, or nil if every event was written.
+func (l *JsonLogger) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// messageEvent returns the event of the given kind for a message.
+func messageEvent(kind EventKind, from, to Address, message Message) JsonEvent {
+	return JsonEvent{
+		Kind:           kind,
+		From:           from,
+		To:             to,
+		Id:             string(message.Id),
+		Type:           string(message.Type),
+		Data:           encodeData(message.Data),
+		ReplyTo:        message.ReplyTo,
+		ParentId:       message.ParentId,
+		MessageLamport: message.Lamport,
+		MessageVector:  message.Vector,
+	}
+}
+
+// timerEvent returns the event of the given kind for a timer.
+func timerEvent(kind EventKind, to Address, timer Timer, duration time.Duration) JsonEvent {
+	return JsonEvent{
+		Kind:     kind,
+		To:       to,
+		Id:       string(timer.Id),
+		Type:     string(timer.Type),
+		Data:     encodeData(timer.Data),
+		ParentId: timer.ParentId,
+		Duration: duration,
+	}
+}
+
+// interruptEvent returns the event of the given kind for an interrupt.
+func interruptEvent(kind EventKind, from, to Address, interrupt Interrupt) JsonEvent {
+	return JsonEvent{
+		Kind:     kind,
+		From:     from,
+		To:       to,
+		Id:       string(interrupt.Id),
+		Type:     string(interrupt.Type),
+		Data:     encodeData(interrupt.Data),
+		ParentId: interrupt.ParentId,
+	}
+}
+
+// LogSimulationState is called when the simulation state changes.
+func (l *JsonLogger) LogSimulationState(sim Simulation) {
+	l.write(JsonEvent{Kind: SimulationStateEvent, SimulationState: sim.GetState()})
+}
+
+// LogNodeState is called when the state of a node changes.
+func (l *JsonLogger) LogNodeState(node Node) {
+	event := JsonEvent{Kind: NodeStateEvent, To: node.GetAddress(), NodeState: node.GetState()}
+	if fsm, ok := node.(FsmStater); ok {
+		event.FsmState = fsm.GetFsmState()
+	}
+	l.write(event)
+}
+
+// LogSendMessage is called when a message is sent.
+func (l *JsonLogger) LogSendMessage(from, to Address, message Message) {
+	l.write(messageEvent(SendMessageEvent, from, to, message))
+}
+
+// LogHandleMessage is called when a message is handled.
+func (l *JsonLogger) LogHandleMessage(from, to Address, message Message) {
+	l.write(messageEvent(HandleMessageEvent, from, to, message))
+}
+
+// LogDropMessage is called when a message is dropped.
+func (l *JsonLogger) LogDropMessage(from, to Address, message Message) {
+	l.write(messageEvent(DropMessageEvent, from, to, message))
+}
+
+// LogSetTimer is called when a timer is set.
+func (l *JsonLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {
+	l.write(timerEvent(SetTimerEvent, to, timer, duration))
+}
+
+// LogHandleTimer is called when a timer is handled.
+func (l *JsonLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	l.write(timerEvent(HandleTimerEvent, to, timer, duration))
+}
+
+// LogDropTimer is called when a timer is dropped.
+func (l *JsonLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {
+	l.write(timerEvent(DropTimerEvent, to, timer, duration))
+}
+
+// LogSendInterrupt is called when an interrupt is sent.
+func (l *JsonLogger) LogSendInterrupt(from, to Address, interrupt Interrupt) {
+	l.write(interruptEvent(SendInterruptEvent, from, to, interrupt))
+}
+
+// LogHandleInterrupt is called when an interrupt is handled.
+func (l *JsonLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	l.write(interruptEvent(HandleInterruptEvent, from, to, interrupt))
+}
+
+// LogDropInterrupt is called when an interrupt is dropped.
+func (l *JsonLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {
+	l.write(interruptEvent(DropInterruptEvent, from, to, interrupt))
+}
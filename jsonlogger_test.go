@@ -0,0 +1,37 @@
+package disse_test
+
+import (
+	"errors"
+	"testing"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// failingWriter fails every write after the first n writes.
+type failingWriter struct {
+	n int
+}
+
+var errWrite = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errWrite
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestJsonLoggerErr(t *testing.T) {
+	sim := ds.NewLocalSimulation(testOptions())
+	logger := ds.NewJsonLoggerWithWriter(&failingWriter{n: 1}, sim)
+	logger.LogSendMessage("a", "b", ds.NewMessage(ping, nil))
+	if err := logger.Err(); err != nil {
+		t.Fatalf("Err() = %v after a successful write, want nil", err)
+	}
+	logger.LogSendMessage("a", "b", ds.NewMessage(ping, nil))
+	logger.LogSendMessage("a", "b", ds.NewMessage(ping, nil))
+	if err := logger.Err(); !errors.Is(err, errWrite) {
+		t.Errorf("Err() = %v, want %v", err, errWrite)
+	}
+}
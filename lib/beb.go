@@ -60,3 +60,13 @@ func (n *BebNode) HandleTimer(ctx context.Context, timer ds.Timer, length time.D
 		return false
 	}
 }
+
+// Snapshot returns a copy of the state of the node.
+//
+// BebNode has no state that changes during the simulation.
+func (n *BebNode) Snapshot() any {
+	return nil
+}
+
+// Restore restores the node to a state returned by Snapshot.
+func (n *BebNode) Restore(snapshot any) {}
@@ -84,3 +84,65 @@ func (n *LeNode) HandleTimer(ctx context.Context, timer ds.Timer, length time.Du
 		return false
 	}
 }
+
+// LeEventualDetection returns a property that checks that a correct node in nodes is elected
+// as the leader within the given duration of the simulation starting and of the leader crashing.
+//
+// Elections are observed through the LeLeader messages sent in the simulation.
+func LeEventualDetection(nodes []ds.Address, within time.Duration) ds.EventuallyProperty {
+	return ds.EventuallyProperty{
+		Name: "LeEventualDetection",
+		Trigger: func(trace []ds.Event) bool {
+			if len(trace) == 1 {
+				return true
+			}
+			leader, ok := leLeader(trace)
+			return ok && ds.OnCrash(trace) && trace[len(trace)-1].To == leader
+		},
+		Satisfied: func(trigger ds.Event, trace []ds.Event) bool {
+			crashed := ds.Crashed(trace)
+			correct := false
+			for _, node := range nodes {
+				correct = correct || !crashed[node]
+			}
+			if !correct {
+				return true
+			}
+			leader, ok := leLeader(trace)
+			return ok && !crashed[leader]
+		},
+		Within: within,
+	}
+}
+
+// leLeader returns the leader announced by the last LeLeader message sent in the trace.
+func leLeader(trace []ds.Event) (ds.Address, bool) {
+	for i := len(trace) - 1; i >= 0; i-- {
+		e := trace[i]
+		if e.Kind == ds.SendMessageEvent && e.Message.Type == LeLeader {
+			return e.Message.Data.(LeLeaderData).Node, true
+		}
+	}
+	return "", false
+}
+
+// leSnapshot is a snapshot of the state of a LeNode.
+type leSnapshot struct {
+	Leader  ds.Address
+	Crashed map[ds.Address]bool
+}
+
+// Snapshot returns a copy of the state of the node.
+func (n *LeNode) Snapshot() any {
+	return leSnapshot{
+		Leader:  n.leader,
+		Crashed: copyAddressSet(n.crashed),
+	}
+}
+
+// Restore restores the node to a state returned by Snapshot.
+func (n *LeNode) Restore(snapshot any) {
+	data := snapshot.(leSnapshot)
+	n.leader = data.Leader
+	n.crashed = copyAddressSet(data.Crashed)
+}
@@ -91,3 +91,58 @@ func (n *PfdNode) HandleTimer(ctx context.Context, timer ds.Timer, length time.D
 		return false
 	}
 }
+
+// PfdStrongCompleteness returns a property that checks that every crash of a node in nodes
+// is detected by every correct node in nodes within the given duration.
+//
+// A node detects a crash when it receives a PfdCrash message for the crashed node.
+func PfdStrongCompleteness(nodes []ds.Address, within time.Duration) ds.EventuallyProperty {
+	monitored := make(map[ds.Address]bool)
+	for _, node := range nodes {
+		monitored[node] = true
+	}
+	return ds.EventuallyProperty{
+		Name: "PfdStrongCompleteness",
+		Trigger: func(trace []ds.Event) bool {
+			return ds.OnCrash(trace) && monitored[trace[len(trace)-1].To]
+		},
+		Satisfied: func(trigger ds.Event, trace []ds.Event) bool {
+			detected := ds.HandledByAll(nodes, func(message ds.Message) bool {
+				data, ok := message.Data.(PfdCrashData)
+				return message.Type == PfdCrash && ok && data.Node == trigger.To
+			})
+			return detected(trigger, trace)
+		},
+		Within: within,
+	}
+}
+
+// pfdSnapshot is a snapshot of the state of a PfdNode.
+type pfdSnapshot struct {
+	Alive   map[ds.Address]bool
+	Crashed map[ds.Address]bool
+}
+
+// Snapshot returns a copy of the state of the node.
+func (n *PfdNode) Snapshot() any {
+	return pfdSnapshot{
+		Alive:   copyAddressSet(n.alive),
+		Crashed: copyAddressSet(n.crashed),
+	}
+}
+
+// Restore restores the node to a state returned by Snapshot.
+func (n *PfdNode) Restore(snapshot any) {
+	data := snapshot.(pfdSnapshot)
+	n.alive = copyAddressSet(data.Alive)
+	n.crashed = copyAddressSet(data.Crashed)
+}
+
+// copyAddressSet returns a copy of a set of addresses.
+func copyAddressSet(set map[ds.Address]bool) map[ds.Address]bool {
+	copied := make(map[ds.Address]bool, len(set))
+	for address, value := range set {
+		copied[address] = value
+	}
+	return copied
+}
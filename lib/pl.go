@@ -2,6 +2,7 @@ package lib
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	ds "github.com/samuel-adekunle/disse"
@@ -31,12 +32,12 @@ type PlDeliverData struct {
 // This implementation uses the "Eliminate Duplicates" algorithm.
 type PlNode struct {
 	*ds.LocalNode
-	deliveredMessages map[ds.MessageId]bool
+	deliveredMessages map[ds.MessageId]ds.Address
 }
 
 // Init is called when the node is initialized by the simulation.
 func (n *PlNode) Init(ctx context.Context) {
-	n.deliveredMessages = make(map[ds.MessageId]bool)
+	n.deliveredMessages = make(map[ds.MessageId]ds.Address)
 }
 
 // HandleMessage is called when the node receives a message.
@@ -52,7 +53,7 @@ func (n *PlNode) HandleMessage(ctx context.Context, message ds.Message, from ds.
 			Message: data.Message,
 		})
 		n.SendMessage(ctx, deliverMessage, from)
-		n.deliveredMessages[message.Id] = true
+		n.deliveredMessages[message.Id] = from
 		return true
 	default:
 		return false
@@ -66,3 +67,39 @@ func (n *PlNode) HandleTimer(ctx context.Context, timer ds.Timer, length time.Du
 		return false
 	}
 }
+
+// plSnapshot is a snapshot of the state of a PlNode.
+//
+// It maps the id of every delivered message to the node it was delivered from.
+type plSnapshot map[ds.MessageId]ds.Address
+
+// GoString returns the number of messages delivered from each node in address order.
+//
+// Message ids are random, so they are left out to hash states that only differ in the ids of
+// the delivered messages equally.
+func (s plSnapshot) GoString() string {
+	counts := make(map[ds.Address]int)
+	for _, from := range s {
+		counts[from]++
+	}
+	return fmt.Sprint(counts)
+}
+
+// Snapshot returns a copy of the state of the node.
+func (n *PlNode) Snapshot() any {
+	return copyDelivered(n.deliveredMessages)
+}
+
+// Restore restores the node to a state returned by Snapshot.
+func (n *PlNode) Restore(snapshot any) {
+	n.deliveredMessages = copyDelivered(snapshot.(plSnapshot))
+}
+
+// copyDelivered returns a copy of a set of delivered messages.
+func copyDelivered(delivered map[ds.MessageId]ds.Address) plSnapshot {
+	copied := make(plSnapshot, len(delivered))
+	for id, from := range delivered {
+		copied[id] = from
+	}
+	return copied
+}
@@ -0,0 +1,336 @@
+// Package lincheck checks that the operations recorded from client nodes during a simulation
+// are linearizable with respect to a sequential specification.
+//
+// Operations are recorded with a Recorder, which timestamps every invocation and response with the
+// time of the simulation. A history is linearizable if every operation can be ordered at some point
+// between its invocation and its response so that the order is allowed by the Model.
+//
+// Histories are checked with the algorithm of Wing and Gong, with the improvements of Lowe and
+// the partitioning used by Porcupine.
+package lincheck
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// DefaultVisualizationPath is the default path of the visualization written for a history that is not linearizable.
+const DefaultVisualizationPath = "linearizability.html"
+
+// Unknown is the output of an operation that was invoked but did not return before the simulation ended.
+//
+// Such an operation may or may not have taken effect, so models must accept any output for it.
+type Unknown struct{}
+
+// Operation is an operation invoked by a client, with its input, output and the times of its invocation and response.
+//
+// The times are measured from the start of the simulation.
+type Operation struct {
+	Client ds.Address
+	Input  any
+	Output any
+	Call   time.Duration
+	Return time.Duration
+}
+
+// Pending returns true if the operation did not return.
+func (o Operation) Pending() bool {
+	_, ok := o.Output.(Unknown)
+	return ok
+}
+
+// Model is the sequential specification that a history is checked against.
+//
+// Only Name, Init and Step must be set.
+type Model struct {
+	// Name is the name of the model used in reports.
+	Name string
+	// Partition splits a history into independent histories that are checked separately, for example by key.
+	Partition func(history []Operation) [][]Operation
+	// Init returns the initial state.
+	Init func() any
+	// Step returns true and the new state if the operation with the given input and output is allowed in the given state.
+	//
+	// Step must not modify the state it is given, and must accept any output if the output is Unknown.
+	Step func(state, input, output any) (bool, any)
+	// Equal returns true if two states are equal, and defaults to reflect.DeepEqual.
+	Equal func(a, b any) bool
+	// DescribeOperation returns a description of an operation, and defaults to "input -> output".
+	DescribeOperation func(input, output any) string
+	// DescribeState returns a description of a state, and defaults to its default format.
+	DescribeState func(state any) string
+}
+
+// Violation is the error returned when a history is not linearizable.
+//
+// It contains the history of the partition that could not be linearized, the longest valid order of
+// its operations that was found, the states after each of those operations, and the window of
+// operations that were concurrent at that point, none of which could be ordered next.
+type Violation struct {
+	Model         Model
+	History       []Operation
+	Linearization []Operation
+	States        []any
+	Window        []Operation
+	order         map[int]int
+	window        map[int]bool
+}
+
+// Error returns a string representation of the violation.
+func (v *Violation) Error() string {
+	return fmt.Sprintf("history is not linearizable with respect to the %v model: %d of %d operations linearized, none of %d concurrent operations can be linearized next",
+		v.Model.Name, len(v.Linearization), len(v.History), len(v.Window))
+}
+
+// WriteFile writes a visualization of the violation as an HTML page to the file at the given path.
+func (v *Violation) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return v.WriteHTML(file)
+}
+
+// Check returns a Violation if the history is not linearizable with respect to the model, or nil otherwise.
+//
+// Operations that did not return may be linearized at any point after their invocation, or not at all.
+func Check(model Model, history []Operation) error {
+	partitions := [][]Operation{history}
+	if model.Partition != nil {
+		partitions = model.Partition(history)
+	}
+	for _, partition := range partitions {
+		if violation := checkPartition(model, partition); violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
+
+// entry is the invocation or response of an operation in the doubly linked list searched by the checker.
+type entry struct {
+	id         int
+	call       bool
+	time       time.Duration
+	match      *entry
+	prev, next *entry
+}
+
+// lift removes the invocation and the matching response from the list.
+func (e *entry) lift() {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	match := e.match
+	match.prev.next = match.next
+	if match.next != nil {
+		match.next.prev = match.prev
+	}
+}
+
+// unlift restores the invocation and the matching response removed by lift.
+func (e *entry) unlift() {
+	match := e.match
+	match.prev.next = match
+	if match.next != nil {
+		match.next.prev = match
+	}
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// makeEntries returns the head of a list of the invocations and responses of the history ordered by time.
+//
+// Invocations are ordered before responses at the same time, so that such operations are concurrent.
+func makeEntries(history []Operation) *entry {
+	entries := make([]*entry, 0, 2*len(history))
+	for id, operation := range history {
+		ret := operation.Return
+		if operation.Pending() {
+			ret = math.MaxInt64
+		}
+		call := &entry{id: id, call: true, time: operation.Call}
+		response := &entry{id: id, time: ret, match: call}
+		call.match = response
+		entries = append(entries, call, response)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].time != entries[j].time {
+			return entries[i].time < entries[j].time
+		}
+		return entries[i].call && !entries[j].call
+	})
+	head := &entry{id: -1}
+	prev := head
+	for _, e := range entries {
+		prev.next, e.prev = e, prev
+		prev = e
+	}
+	return head
+}
+
+// bitset is the set of operations that have been linearized.
+type bitset []uint64
+
+func (b bitset) clone() bitset {
+	return append(bitset(nil), b...)
+}
+
+func (b bitset) set(i int) bitset {
+	b[i/64] |= 1 << (i % 64)
+	return b
+}
+
+func (b bitset) clear(i int) bitset {
+	b[i/64] &^= 1 << (i % 64)
+	return b
+}
+
+func (b bitset) equal(other bitset) bool {
+	for i := range b {
+		if b[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bitset) hash() uint64 {
+	h := fnv.New64a()
+	for _, word := range b {
+		for i := 0; i < 8; i++ {
+			h.Write([]byte{byte(word >> (8 * i))})
+		}
+	}
+	return h.Sum64()
+}
+
+// cacheEntry is a set of linearized operations and the state they lead to.
+type cacheEntry struct {
+	linearized bitset
+	state      any
+}
+
+// frame is an operation linearized by the search and the state before it.
+type frame struct {
+	entry *entry
+	state any
+}
+
+// checkPartition searches for a linearization of the history and returns a Violation if there is none.
+func checkPartition(model Model, history []Operation) *Violation {
+	equal := model.Equal
+	if equal == nil {
+		equal = reflect.DeepEqual
+	}
+	head := makeEntries(history)
+	linearized := make(bitset, (len(history)+63)/64)
+	cache := make(map[uint64][]cacheEntry)
+	stack := make([]frame, 0, len(history))
+	best, window := make([]frame, 0), make([]int, 0)
+	remaining := 0
+	for _, operation := range history {
+		if !operation.Pending() {
+			remaining++
+		}
+	}
+	state := model.Init()
+	e := head.next
+	for remaining > 0 {
+		if e.call {
+			operation := history[e.id]
+			if ok, next := model.Step(state, operation.Input, operation.Output); ok {
+				candidate := linearized.clone().set(e.id)
+				if addCache(cache, candidate, next, equal) {
+					stack = append(stack, frame{e, state})
+					state, linearized = next, candidate
+					if !operation.Pending() {
+						remaining--
+					}
+					e.lift()
+					if len(stack) > len(best) {
+						best = append(best[:0], stack...)
+						window = concurrent(head)
+					}
+					e = head.next
+					continue
+				}
+			}
+			e = e.next
+			continue
+		}
+		if len(stack) == 0 {
+			if len(best) == 0 {
+				window = concurrent(head)
+			}
+			return newViolation(model, history, best, window)
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		linearized = linearized.clone().clear(top.entry.id)
+		state = top.state
+		if !history[top.entry.id].Pending() {
+			remaining++
+		}
+		top.entry.unlift()
+		e = top.entry.next
+	}
+	return nil
+}
+
+// addCache adds the linearized operations and state to the cache, and returns false if they were already in it.
+func addCache(cache map[uint64][]cacheEntry, linearized bitset, state any, equal func(a, b any) bool) bool {
+	h := linearized.hash()
+	for _, c := range cache[h] {
+		if c.linearized.equal(linearized) && equal(c.state, state) {
+			return false
+		}
+	}
+	cache[h] = append(cache[h], cacheEntry{linearized, state})
+	return true
+}
+
+// concurrent returns the operations in the list that are invoked before the first response in the list.
+func concurrent(head *entry) []int {
+	ids := make([]int, 0)
+	for e := head.next; e != nil && e.call; e = e.next {
+		ids = append(ids, e.id)
+	}
+	return ids
+}
+
+// newViolation creates a Violation from the longest linearization found by the search.
+func newViolation(model Model, history []Operation, best []frame, window []int) *Violation {
+	violation := &Violation{
+		Model:         model,
+		History:       history,
+		Linearization: make([]Operation, 0, len(best)),
+		States:        make([]any, 0, len(best)),
+		Window:        make([]Operation, 0, len(window)),
+		order:         make(map[int]int),
+		window:        make(map[int]bool),
+	}
+	for i, f := range best {
+		operation := history[f.entry.id]
+		violation.order[f.entry.id] = i + 1
+		violation.Linearization = append(violation.Linearization, operation)
+		if i+1 < len(best) {
+			violation.States = append(violation.States, best[i+1].state)
+		} else {
+			_, state := model.Step(f.state, operation.Input, operation.Output)
+			violation.States = append(violation.States, state)
+		}
+	}
+	for _, id := range window {
+		violation.Window = append(violation.Window, history[id])
+		violation.window[id] = true
+	}
+	return violation
+}
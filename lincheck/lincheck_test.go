@@ -0,0 +1,315 @@
+package lincheck_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/lincheck"
+)
+
+// op returns an operation of a client that is invoked and returns at the given times in milliseconds.
+func op(client string, input, output any, call, ret int) lincheck.Operation {
+	return lincheck.Operation{
+		Client: ds.Address("client" + client),
+		Input:  input,
+		Output: output,
+		Call:   time.Duration(call) * time.Millisecond,
+		Return: time.Duration(ret) * time.Millisecond,
+	}
+}
+
+// pending returns an operation of a client that is invoked at the given time in milliseconds and never returns.
+func pending(client string, input any, call int) lincheck.Operation {
+	return lincheck.Operation{
+		Client: ds.Address("client" + client),
+		Input:  input,
+		Output: lincheck.Unknown{},
+		Call:   time.Duration(call) * time.Millisecond,
+		Return: math.MaxInt64,
+	}
+}
+
+func write(value any) lincheck.RegisterInput {
+	return lincheck.RegisterInput{Type: lincheck.Write, Value: value}
+}
+
+func read() lincheck.RegisterInput {
+	return lincheck.RegisterInput{Type: lincheck.Read}
+}
+
+func cas(expected, value any) lincheck.RegisterInput {
+	return lincheck.RegisterInput{Type: lincheck.Cas, Expected: expected, Value: value}
+}
+
+func enqueue(value any) lincheck.QueueInput {
+	return lincheck.QueueInput{Type: lincheck.Enqueue, Value: value}
+}
+
+func dequeue() lincheck.QueueInput {
+	return lincheck.QueueInput{Type: lincheck.Dequeue}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        lincheck.Model
+		history      []lincheck.Operation
+		linearizable bool
+	}{
+		{
+			name:  "register sequential",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("a", read(), nil, 0, 10),
+				op("a", write(1), nil, 20, 30),
+				op("b", read(), 1, 40, 50),
+				op("b", cas(1, 2), true, 60, 70),
+				op("a", cas(1, 3), false, 80, 90),
+				op("a", read(), 2, 100, 110),
+			},
+			linearizable: true,
+		},
+		{
+			name:  "register stale read",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("a", write(1), nil, 0, 10),
+				op("a", write(2), nil, 20, 30),
+				op("b", read(), 1, 40, 50),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "register failed cas",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("a", write(1), nil, 0, 10),
+				op("b", cas(1, 2), false, 20, 30),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "queue sequential",
+			model: lincheck.Queue(),
+			history: []lincheck.Operation{
+				op("a", enqueue(1), nil, 0, 10),
+				op("a", enqueue(2), nil, 20, 30),
+				op("b", dequeue(), 1, 40, 50),
+				op("b", dequeue(), 2, 60, 70),
+				op("b", dequeue(), nil, 80, 90),
+			},
+			linearizable: true,
+		},
+		{
+			name:  "queue out of order",
+			model: lincheck.Queue(),
+			history: []lincheck.Operation{
+				op("a", enqueue(1), nil, 0, 10),
+				op("a", enqueue(2), nil, 20, 30),
+				op("b", dequeue(), 2, 40, 50),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "queue duplicate dequeue",
+			model: lincheck.Queue(),
+			history: []lincheck.Operation{
+				op("a", enqueue(1), nil, 0, 10),
+				op("b", dequeue(), 1, 20, 30),
+				op("c", dequeue(), 1, 20, 30),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "concurrent writes read in either order",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("a", write(1), nil, 0, 100),
+				op("b", write(2), nil, 0, 100),
+				op("c", read(), 2, 50, 60),
+				op("c", read(), 1, 70, 80),
+			},
+			linearizable: true,
+		},
+		{
+			name:  "concurrent writes read in both orders after they return",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("a", write(1), nil, 0, 100),
+				op("b", write(2), nil, 0, 100),
+				op("c", read(), 1, 110, 120),
+				op("c", read(), 2, 130, 140),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "concurrent enqueues dequeued in either order",
+			model: lincheck.Queue(),
+			history: []lincheck.Operation{
+				op("a", enqueue(1), nil, 0, 50),
+				op("b", enqueue(2), nil, 10, 40),
+				op("c", dequeue(), 2, 20, 60),
+				op("c", dequeue(), 1, 70, 80),
+			},
+			linearizable: true,
+		},
+		{
+			name:  "pending write may take effect",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				pending("a", write(1), 10),
+				op("b", read(), nil, 20, 30),
+				op("b", read(), 1, 40, 50),
+			},
+			linearizable: true,
+		},
+		{
+			name:  "pending write may not take effect",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("b", write(2), nil, 0, 5),
+				pending("a", write(1), 10),
+				op("b", read(), 2, 20, 30),
+			},
+			linearizable: true,
+		},
+		{
+			name:  "pending write takes effect once",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				pending("a", write(1), 10),
+				op("b", read(), 1, 20, 30),
+				op("b", read(), nil, 40, 50),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "pending write cannot take effect before it is invoked",
+			model: lincheck.Register(),
+			history: []lincheck.Operation{
+				op("b", read(), 1, 0, 5),
+				pending("a", write(1), 10),
+			},
+			linearizable: false,
+		},
+		{
+			name:  "pending dequeue",
+			model: lincheck.Queue(),
+			history: []lincheck.Operation{
+				op("a", enqueue(1), nil, 0, 10),
+				pending("b", dequeue(), 20),
+				op("c", dequeue(), nil, 30, 40),
+			},
+			linearizable: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := lincheck.Check(test.model, test.history)
+			if test.linearizable && err != nil {
+				t.Errorf("Check() = %v, want nil", err)
+			}
+			if !test.linearizable {
+				var violation *lincheck.Violation
+				if !errors.As(err, &violation) {
+					t.Fatalf("Check() = %v, want a violation", err)
+				}
+				if len(violation.Window) == 0 {
+					t.Errorf("violation has an empty window")
+				}
+				if len(violation.States) != len(violation.Linearization) {
+					t.Errorf("violation has %d states for %d operations", len(violation.States), len(violation.Linearization))
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPartitionsKeyValue(t *testing.T) {
+	kv := func(op lincheck.OpType, key string, value any) lincheck.KeyValueInput {
+		return lincheck.KeyValueInput{Type: op, Key: key, Value: value}
+	}
+	history := []lincheck.Operation{
+		op("a", kv(lincheck.Write, "x", 1), nil, 0, 10),
+		op("b", kv(lincheck.Write, "y", 2), nil, 0, 10),
+		op("a", kv(lincheck.Read, "x", nil), 1, 20, 30),
+		op("b", kv(lincheck.Read, "y", nil), 1, 20, 30),
+	}
+	var violation *lincheck.Violation
+	if err := lincheck.Check(lincheck.KeyValue(), history); !errors.As(err, &violation) {
+		t.Fatalf("Check() = %v, want a violation", err)
+	}
+	if len(violation.History) != 2 {
+		t.Errorf("violation has %d operations, want the 2 operations on y", len(violation.History))
+	}
+	for _, operation := range violation.History {
+		if key := operation.Input.(lincheck.KeyValueInput).Key; key != "y" {
+			t.Errorf("violation has an operation on %v", key)
+		}
+	}
+}
+
+func TestViolationWriteHTML(t *testing.T) {
+	history := []lincheck.Operation{
+		op("a", write(1), nil, 0, 10),
+		op("a", write(2), nil, 20, 30),
+		op("b", read(), 1, 40, 50),
+	}
+	var violation *lincheck.Violation
+	if err := lincheck.Check(lincheck.Register(), history); !errors.As(err, &violation) {
+		t.Fatalf("Check() = %v, want a violation", err)
+	}
+	var buffer bytes.Buffer
+	if err := violation.WriteHTML(&buffer); err != nil {
+		t.Fatalf("WriteHTML() = %v", err)
+	}
+	for _, want := range []string{"clienta", "clientb", "write(2)", "read() -&gt; 1"} {
+		if !strings.Contains(buffer.String(), want) {
+			t.Errorf("visualization does not contain %q", want)
+		}
+	}
+}
+
+// clock is a clock whose time is set by the test.
+type clock struct {
+	now time.Duration
+}
+
+func (c *clock) Now() time.Duration {
+	return c.now
+}
+
+func TestRecorder(t *testing.T) {
+	c := &clock{}
+	recorder := lincheck.NewRecorder(c)
+	w := recorder.Invoke("a", write(1))
+	c.now = 10 * time.Millisecond
+	r := recorder.Invoke("b", read())
+	c.now = 20 * time.Millisecond
+	recorder.Return(w, nil)
+	recorder.Invoke("c", write(2))
+	c.now = 30 * time.Millisecond
+	recorder.Return(r, 1)
+
+	history := recorder.History()
+	if len(history) != 3 {
+		t.Fatalf("recorded %d operations, want 3", len(history))
+	}
+	if history[0].Call != 0 || history[0].Return != 20*time.Millisecond {
+		t.Errorf("write recorded from %v to %v", history[0].Call, history[0].Return)
+	}
+	if history[1].Output != 1 || history[1].Return != 30*time.Millisecond {
+		t.Errorf("read recorded with output %v at %v", history[1].Output, history[1].Return)
+	}
+	if !history[2].Pending() {
+		t.Errorf("write that did not return is not pending")
+	}
+	if err := recorder.Check(lincheck.Register()); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+}
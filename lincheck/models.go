@@ -0,0 +1,227 @@
+package lincheck
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OpType is a string that identifies the type of an operation of a model.
+type OpType string
+
+const (
+	// Read reads the value of a register, key or counter.
+	Read OpType = "Read"
+	// Write writes a value to a register or key.
+	Write OpType = "Write"
+	// Cas writes a value to a register or key if its current value is the expected value.
+	Cas OpType = "Cas"
+	// Enqueue adds a value to the back of a queue.
+	Enqueue OpType = "Enqueue"
+	// Dequeue removes a value from the front of a queue.
+	Dequeue OpType = "Dequeue"
+	// Increment adds a value to a counter.
+	Increment OpType = "Increment"
+)
+
+// RegisterInput is the input of an operation on a register.
+//
+// The output of a Read is the value read, and the output of a Cas is true if the value was written.
+// The output of a Write is ignored.
+type RegisterInput struct {
+	Type     OpType
+	Value    any
+	Expected any
+}
+
+// KeyValueInput is the input of an operation on a key-value map.
+//
+// The outputs are the same as the outputs of a RegisterInput, and missing keys have a nil value.
+type KeyValueInput struct {
+	Type     OpType
+	Key      string
+	Value    any
+	Expected any
+}
+
+// QueueInput is the input of an operation on a FIFO queue.
+//
+// The output of a Dequeue is the value removed, or nil if the queue was empty.
+// The output of an Enqueue is ignored.
+type QueueInput struct {
+	Type  OpType
+	Value any
+}
+
+// CounterInput is the input of an operation on a counter.
+//
+// The output of a Read is the value of the counter as an int.
+// The output of an Increment is ignored.
+type CounterInput struct {
+	Type  OpType
+	Delta int
+}
+
+// isUnknown returns true if the output is Unknown.
+func isUnknown(output any) bool {
+	_, ok := output.(Unknown)
+	return ok
+}
+
+// stepRegister applies an operation to a register with the given value.
+func stepRegister(value any, op OpType, input, expected, output any) (bool, any) {
+	switch op {
+	case Read:
+		return isUnknown(output) || reflect.DeepEqual(output, value), value
+	case Write:
+		return true, input
+	case Cas:
+		if reflect.DeepEqual(value, expected) {
+			return isUnknown(output) || output == true, input
+		}
+		return isUnknown(output) || output == false, value
+	default:
+		return false, value
+	}
+}
+
+// describeRegister returns a description of an operation on a register.
+func describeRegister(op OpType, value, expected, output any) string {
+	switch op {
+	case Read:
+		return fmt.Sprintf("read() -> %v", describeOutput(output))
+	case Write:
+		return fmt.Sprintf("write(%v)", value)
+	default:
+		return fmt.Sprintf("cas(%v, %v) -> %v", expected, value, describeOutput(output))
+	}
+}
+
+// describeOutput returns a description of an output, which is "?" if the output is Unknown.
+func describeOutput(output any) string {
+	if isUnknown(output) {
+		return "?"
+	}
+	return fmt.Sprint(output)
+}
+
+// Register returns a model of a single register with an initial value of nil.
+//
+// Operations use a RegisterInput.
+func Register() Model {
+	return Model{
+		Name: "register",
+		Init: func() any { return nil },
+		Step: func(state, input, output any) (bool, any) {
+			in := input.(RegisterInput)
+			return stepRegister(state, in.Type, in.Value, in.Expected, output)
+		},
+		DescribeOperation: func(input, output any) string {
+			in := input.(RegisterInput)
+			return describeRegister(in.Type, in.Value, in.Expected, output)
+		},
+	}
+}
+
+// KeyValue returns a model of a key-value map in which every key is an independent register.
+//
+// Operations use a KeyValueInput, and the history is partitioned by key.
+func KeyValue() Model {
+	return Model{
+		Name: "key-value",
+		Partition: func(history []Operation) [][]Operation {
+			keys := make([]string, 0)
+			partitions := make(map[string][]Operation)
+			for _, operation := range history {
+				key := operation.Input.(KeyValueInput).Key
+				if _, ok := partitions[key]; !ok {
+					keys = append(keys, key)
+				}
+				partitions[key] = append(partitions[key], operation)
+			}
+			result := make([][]Operation, 0, len(keys))
+			for _, key := range keys {
+				result = append(result, partitions[key])
+			}
+			return result
+		},
+		Init: func() any { return nil },
+		Step: func(state, input, output any) (bool, any) {
+			in := input.(KeyValueInput)
+			return stepRegister(state, in.Type, in.Value, in.Expected, output)
+		},
+		DescribeOperation: func(input, output any) string {
+			in := input.(KeyValueInput)
+			switch in.Type {
+			case Read:
+				return fmt.Sprintf("read(%v) -> %v", in.Key, describeOutput(output))
+			case Write:
+				return fmt.Sprintf("write(%v, %v)", in.Key, in.Value)
+			default:
+				return fmt.Sprintf("cas(%v, %v, %v) -> %v", in.Key, in.Expected, in.Value, describeOutput(output))
+			}
+		},
+	}
+}
+
+// Queue returns a model of an initially empty FIFO queue.
+//
+// Operations use a QueueInput.
+func Queue() Model {
+	return Model{
+		Name: "queue",
+		Init: func() any { return []any{} },
+		Step: func(state, input, output any) (bool, any) {
+			queue := state.([]any)
+			in := input.(QueueInput)
+			switch in.Type {
+			case Enqueue:
+				next := make([]any, len(queue), len(queue)+1)
+				copy(next, queue)
+				return true, append(next, in.Value)
+			case Dequeue:
+				if len(queue) == 0 {
+					return isUnknown(output) || output == nil, queue
+				}
+				return isUnknown(output) || reflect.DeepEqual(output, queue[0]), queue[1:]
+			default:
+				return false, queue
+			}
+		},
+		DescribeOperation: func(input, output any) string {
+			in := input.(QueueInput)
+			if in.Type == Enqueue {
+				return fmt.Sprintf("enqueue(%v)", in.Value)
+			}
+			return fmt.Sprintf("dequeue() -> %v", describeOutput(output))
+		},
+	}
+}
+
+// Counter returns a model of a counter with an initial value of 0.
+//
+// Operations use a CounterInput.
+func Counter() Model {
+	return Model{
+		Name: "counter",
+		Init: func() any { return 0 },
+		Step: func(state, input, output any) (bool, any) {
+			value := state.(int)
+			in := input.(CounterInput)
+			switch in.Type {
+			case Increment:
+				return true, value + in.Delta
+			case Read:
+				return isUnknown(output) || output == value, value
+			default:
+				return false, value
+			}
+		},
+		DescribeOperation: func(input, output any) string {
+			in := input.(CounterInput)
+			if in.Type == Increment {
+				return fmt.Sprintf("increment(%v)", in.Delta)
+			}
+			return fmt.Sprintf("read() -> %v", describeOutput(output))
+		},
+	}
+}
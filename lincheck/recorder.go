@@ -0,0 +1,62 @@
+package lincheck
+
+import (
+	"math"
+	"sync"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// Recorder records the operations invoked by client nodes during a simulation.
+//
+// The invocation and response of every operation are timestamped with the time of the simulation,
+// so that histories recorded in virtual time are checked against the order the simulation chose.
+type Recorder struct {
+	mu         sync.Mutex
+	clock      ds.Clock
+	operations []Operation
+}
+
+// NewRecorder creates a new Recorder that timestamps operations with the given clock, usually the simulation.
+func NewRecorder(clock ds.Clock) *Recorder {
+	return &Recorder{
+		clock:      clock,
+		operations: make([]Operation, 0),
+	}
+}
+
+// Invoke records the invocation of an operation by a client and returns its id.
+func (r *Recorder) Invoke(client ds.Address, input any) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, Operation{
+		Client: client,
+		Input:  input,
+		Output: Unknown{},
+		Call:   r.clock.Now(),
+		Return: math.MaxInt64,
+	})
+	return len(r.operations) - 1
+}
+
+// Return records the response of the operation with the given id.
+func (r *Recorder) Return(id int, output any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations[id].Output = output
+	r.operations[id].Return = r.clock.Now()
+}
+
+// History returns the operations recorded so far, in the order they were invoked.
+//
+// Operations that have not returned have an Unknown output.
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.operations...)
+}
+
+// Check checks the recorded history against the model, as done by Check.
+func (r *Recorder) Check(model Model) error {
+	return Check(model, r.History())
+}
@@ -0,0 +1,128 @@
+package lincheck
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const (
+	// laneHeight is the height of the lane of a client in the visualization.
+	laneHeight = 40
+	// labelWidth is the width of the client labels in the visualization.
+	labelWidth = 120
+	// timelineWidth is the width of the timeline in the visualization.
+	timelineWidth = 1000
+)
+
+// describeOperation returns a description of an operation using the model.
+func (m Model) describeOperation(operation Operation) string {
+	if m.DescribeOperation != nil {
+		return m.DescribeOperation(operation.Input, operation.Output)
+	}
+	return fmt.Sprintf("%v -> %v", operation.Input, describeOutput(operation.Output))
+}
+
+// describeState returns a description of a state using the model.
+func (m Model) describeState(state any) string {
+	if m.DescribeState != nil {
+		return m.DescribeState(state)
+	}
+	return fmt.Sprint(state)
+}
+
+// WriteHTML writes a visualization of the violation as an HTML page.
+//
+// Every client has a lane in which its operations are drawn from invocation to response.
+// Operations in the longest linearization are green and numbered in that order, operations
+// in the window that could not be linearized are red, and all other operations are grey.
+func (v *Violation) WriteHTML(w io.Writer) error {
+	clients := make([]ds.Address, 0)
+	lanes := make(map[ds.Address]int)
+	start, end := time.Duration(-1), time.Duration(0)
+	for _, operation := range v.History {
+		if _, ok := lanes[operation.Client]; !ok {
+			lanes[operation.Client] = 0
+			clients = append(clients, operation.Client)
+		}
+		if start < 0 || operation.Call < start {
+			start = operation.Call
+		}
+		if operation.Call > end {
+			end = operation.Call
+		}
+		if !operation.Pending() && operation.Return > end {
+			end = operation.Return
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i] < clients[j]
+	})
+	for i, client := range clients {
+		lanes[client] = i
+	}
+	if end <= start {
+		end = start + 1
+	}
+	x := func(t time.Duration) float64 {
+		return labelWidth + float64(t-start)/float64(end-start)*timelineWidth
+	}
+
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Linearizability violation</title>\n")
+	fmt.Fprintf(w, "<style>body { font-family: sans-serif; } td, th { padding: 2px 8px; text-align: left; } text { font-size: 12px; }</style>\n")
+	fmt.Fprintf(w, "</head>\n<body>\n<h1>Linearizability violation</h1>\n<p>%v</p>\n", html.EscapeString(v.Error()))
+	fmt.Fprintf(w, "<p>Time from %v to %v.</p>\n", start, end)
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", labelWidth+timelineWidth+20, laneHeight*len(clients)+10)
+	for i, client := range clients {
+		fmt.Fprintf(w, "<text x=\"4\" y=\"%d\">%v</text>\n", i*laneHeight+25, html.EscapeString(string(client)))
+	}
+	for id, operation := range v.History {
+		fill, label := "#cccccc", ""
+		if n, ok := v.order[id]; ok {
+			fill, label = "#99dd99", fmt.Sprintf("#%d ", n)
+		} else if v.window[id] {
+			fill = "#ee8888"
+		}
+		left, right := x(operation.Call), float64(labelWidth+timelineWidth)
+		dash := " stroke-dasharray=\"4\""
+		if !operation.Pending() {
+			right, dash = x(operation.Return), ""
+		}
+		if right-left < 2 {
+			right = left + 2
+		}
+		y := lanes[operation.Client]*laneHeight + 8
+		description := html.EscapeString(v.Model.describeOperation(operation))
+		fmt.Fprintf(w, "<g><title>%v [%v, %v]</title>", description, operation.Call, describeReturn(operation))
+		fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%v\" stroke=\"#333333\"%v/>", left, y, right-left, laneHeight-12, fill, dash)
+		fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%d\">%v%v</text></g>\n", left+3, y+18, label, description)
+	}
+	fmt.Fprintf(w, "</svg>\n")
+
+	fmt.Fprintf(w, "<h2>Longest linearization</h2>\n<table>\n<tr><th>#</th><th>Client</th><th>Operation</th><th>Call</th><th>Return</th><th>State</th></tr>\n")
+	for i, operation := range v.Linearization {
+		fmt.Fprintf(w, "<tr><td>%d</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td></tr>\n", i+1,
+			html.EscapeString(string(operation.Client)), html.EscapeString(v.Model.describeOperation(operation)),
+			operation.Call, describeReturn(operation), html.EscapeString(v.Model.describeState(v.States[i])))
+	}
+	fmt.Fprintf(w, "</table>\n<h2>Operations that cannot be linearized next</h2>\n<table>\n<tr><th>Client</th><th>Operation</th><th>Call</th><th>Return</th></tr>\n")
+	for _, operation := range v.Window {
+		fmt.Fprintf(w, "<tr><td>%v</td><td>%v</td><td>%v</td><td>%v</td></tr>\n",
+			html.EscapeString(string(operation.Client)), html.EscapeString(v.Model.describeOperation(operation)),
+			operation.Call, describeReturn(operation))
+	}
+	_, err := fmt.Fprintf(w, "</table>\n</body>\n</html>\n")
+	return err
+}
+
+// describeReturn returns the time of the response of an operation, or "pending" if it did not return.
+func describeReturn(operation Operation) string {
+	if operation.Pending() {
+		return "pending"
+	}
+	return operation.Return.String()
+}
@@ -0,0 +1,239 @@
+package disse
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// EventuallyProperty is a liveness property with a deadline that is checked against a simulation trace.
+//
+// Every event selected by Trigger starts a deadline, and Satisfied must hold
+// for some prefix of the trace that ends within Within of the triggering event.
+//
+// Deadlines that extend beyond the end of the trace are inconclusive and are not reported as violations.
+type EventuallyProperty struct {
+	// Name identifies the property in violation reports.
+	Name string
+	// Trigger reports whether the last event of the given trace prefix starts a deadline.
+	//
+	// If nil, a single deadline is started by the first event of the trace.
+	Trigger func(trace []Event) bool
+	// OnlyLast restricts the check to the deadline started by the last triggering event.
+	OnlyLast bool
+	// Satisfied reports whether the property holds for the given trace prefix,
+	// where trigger is the event that started the deadline.
+	Satisfied func(trigger Event, trace []Event) bool
+	// Within is the deadline for the property to be satisfied, measured from the triggering event.
+	Within time.Duration
+}
+
+// LivenessViolation is the error returned when an eventual property is not satisfied before its deadline.
+type LivenessViolation struct {
+	Property string
+	Trigger  Event
+	Deadline time.Duration
+	Trace    []Event
+}
+
+// Error returns a string representation of the violation.
+func (v *LivenessViolation) Error() string {
+	return fmt.Sprintf("property %q not satisfied by %v after %v", v.Property, v.Deadline, v.Trigger)
+}
+
+// WriteFile writes the violation and its trace to the file at the given path.
+func (v *LivenessViolation) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "Property: %v\n", v.Property)
+	fmt.Fprintf(file, "Trigger: %v\n", v.Trigger)
+	fmt.Fprintf(file, "Deadline: %v\n", v.Deadline)
+	fmt.Fprintf(file, "Trace:\n")
+	for _, event := range v.Trace {
+		fmt.Fprintf(file, "%v\n", event)
+	}
+	return nil
+}
+
+// CheckEventually checks the given properties against a trace.
+//
+// The trace is walked forward once. Every property keeps a queue of the deadlines started by its triggers that
+// are not yet satisfied, and Satisfied is only called for those deadlines, with each prefix of the trace up to
+// their end. Deadlines expire in the order they were started, as soon as the trace passes them.
+// Trigger and Satisfied are given the whole prefix ending at the current event, so callbacks that scan the
+// prefix, such as Crashed, make the check quadratic in the length of the trace.
+//
+// It returns a *LivenessViolation for the property whose deadline expired first, or nil if all properties hold.
+func CheckEventually(trace []Event, properties ...EventuallyProperty) error {
+	if len(trace) == 0 {
+		return nil
+	}
+	checks := make([]*eventuallyCheck, len(properties))
+	for i, property := range properties {
+		checks[i] = &eventuallyCheck{property: property, pending: make([]int, 0)}
+	}
+	var first *LivenessViolation
+	for i := range trace {
+		for _, check := range checks {
+			if v := check.step(trace, i); v != nil && (first == nil || v.Deadline < first.Deadline) {
+				first = v
+			}
+		}
+		if first != nil && first.Deadline < trace[i].Time {
+			return first
+		}
+	}
+	for _, check := range checks {
+		if v := check.finish(trace); v != nil && (first == nil || v.Deadline < first.Deadline) {
+			first = v
+		}
+	}
+	if first != nil {
+		return first
+	}
+	return nil
+}
+
+// eventuallyCheck is the state of the check of a property during the forward pass over a trace.
+//
+// Pending holds the indices of the triggers whose deadlines are not yet satisfied, oldest first. If the property
+// only checks its last trigger, violation holds the violation of the last trigger if its deadline already expired.
+type eventuallyCheck struct {
+	property  EventuallyProperty
+	pending   []int
+	violation *LivenessViolation
+}
+
+// step checks the event at index i of the trace, and returns a violation if a deadline expired before it.
+func (c *eventuallyCheck) step(trace []Event, i int) *LivenessViolation {
+	var expired *LivenessViolation
+	for len(c.pending) > 0 && c.deadline(trace, c.pending[0]) < trace[i].Time {
+		v := c.expire(trace, c.pending[0])
+		c.pending = c.pending[1:]
+		if expired == nil {
+			expired = v
+		}
+	}
+	if c.property.Trigger == nil && i == 0 || c.property.Trigger != nil && c.property.Trigger(trace[:i+1]) {
+		if c.property.OnlyLast {
+			c.pending = c.pending[:0]
+			c.violation = nil
+			expired = nil
+		}
+		c.pending = append(c.pending, i)
+	}
+	satisfied := c.pending[:0]
+	for _, trigger := range c.pending {
+		if !c.property.Satisfied(trace[trigger], trace[:i+1]) {
+			satisfied = append(satisfied, trigger)
+		}
+	}
+	c.pending = satisfied
+	if c.property.OnlyLast {
+		if expired != nil {
+			c.violation = expired
+		}
+		return nil
+	}
+	return expired
+}
+
+// finish returns the violation of the property at the end of the trace, if any.
+//
+// Deadlines that end exactly at the end of the trace expire, and later ones are inconclusive.
+func (c *eventuallyCheck) finish(trace []Event) *LivenessViolation {
+	end := trace[len(trace)-1].Time
+	if c.violation != nil {
+		return c.violation
+	}
+	if len(c.pending) > 0 && c.deadline(trace, c.pending[0]) <= end {
+		return c.expire(trace, c.pending[0])
+	}
+	return nil
+}
+
+// deadline returns the deadline started by the trigger at the given index.
+func (c *eventuallyCheck) deadline(trace []Event, trigger int) time.Duration {
+	return trace[trigger].Time + c.property.Within
+}
+
+// expire returns the violation of the deadline started by the trigger at the given index.
+func (c *eventuallyCheck) expire(trace []Event, trigger int) *LivenessViolation {
+	return &LivenessViolation{
+		Property: c.property.Name,
+		Trigger:  trace[trigger],
+		Deadline: c.deadline(trace, trigger),
+		Trace:    trace,
+	}
+}
+
+// AddEventually registers an eventual property that is checked against the trace when the simulation finishes.
+//
+// If the property is not satisfied, Run returns a *LivenessViolation.
+func (s *LocalSimulation) AddEventually(property EventuallyProperty) {
+	s.properties = append(s.properties, property)
+	s.recorder()
+}
+
+// checkEventually checks the eventual properties of the simulation against its trace.
+func (s *LocalSimulation) checkEventually() violation {
+	if len(s.properties) == 0 {
+		return nil
+	}
+	if err := CheckEventually(s.trace.Events(), s.properties...); err != nil {
+		return err.(*LivenessViolation)
+	}
+	return nil
+}
+
+// OnCrash is a trigger that selects events where a node stops.
+func OnCrash(trace []Event) bool {
+	e := trace[len(trace)-1]
+	return e.Kind == NodeStateEvent && e.NodeState == Stopped
+}
+
+// OnFault is a trigger that selects events where a node stops or goes to sleep.
+func OnFault(trace []Event) bool {
+	e := trace[len(trace)-1]
+	return e.Kind == NodeStateEvent && (e.NodeState == Stopped || e.NodeState == Sleeping)
+}
+
+// Crashed returns the set of nodes that have stopped in the given trace.
+func Crashed(trace []Event) map[Address]bool {
+	crashed := make(map[Address]bool)
+	for _, e := range trace {
+		if e.Kind == NodeStateEvent && e.NodeState == Stopped {
+			crashed[e.To] = true
+		}
+	}
+	return crashed
+}
+
+// HandledByAll returns a Satisfied function that holds once every node in nodes that
+// has not crashed has handled a message matching match since the triggering event.
+func HandledByAll(nodes []Address, match func(Message) bool) func(Event, []Event) bool {
+	return func(trigger Event, trace []Event) bool {
+		handled := make(map[Address]bool)
+		for i := len(trace) - 1; i >= 0 && trace[i].Time >= trigger.Time; i-- {
+			if e := trace[i]; e.Kind == HandleMessageEvent && match(e.Message) {
+				handled[e.To] = true
+			}
+		}
+		var crashed map[Address]bool
+		for _, node := range nodes {
+			if handled[node] {
+				continue
+			}
+			if crashed == nil {
+				crashed = Crashed(trace)
+			}
+			if !crashed[node] {
+				return false
+			}
+		}
+		return true
+	}
+}
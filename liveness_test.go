@@ -0,0 +1,146 @@
+package disse_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const ack ds.MessageType = "Ack"
+
+// crash returns the event of node a stopping at the given time in milliseconds.
+func crash(at int) ds.Event {
+	return ds.Event{Kind: ds.NodeStateEvent, To: "a", NodeState: ds.Stopped, Time: time.Duration(at) * time.Millisecond}
+}
+
+// handle returns the event of node b handling a message of the given type at the given time in milliseconds.
+func handle(messageType ds.MessageType, at int) ds.Event {
+	return ds.Event{Kind: ds.HandleMessageEvent, From: "a", To: "b", Message: ds.Message{Type: messageType}, Time: time.Duration(at) * time.Millisecond}
+}
+
+// acked returns a property that holds once b handles an ack within 10ms of every crash.
+func acked(onlyLast bool) ds.EventuallyProperty {
+	return ds.EventuallyProperty{
+		Name:     "acked",
+		Trigger:  ds.OnCrash,
+		OnlyLast: onlyLast,
+		Satisfied: func(trigger ds.Event, trace []ds.Event) bool {
+			for i := len(trace) - 1; i >= 0 && trace[i].Time >= trigger.Time; i-- {
+				if trace[i].Kind == ds.HandleMessageEvent && trace[i].Message.Type == ack {
+					return true
+				}
+			}
+			return false
+		},
+		Within: 10 * time.Millisecond,
+	}
+}
+
+func TestCheckEventually(t *testing.T) {
+	tests := []struct {
+		name     string
+		property ds.EventuallyProperty
+		trace    []ds.Event
+		trigger  int
+		deadline time.Duration
+	}{
+		{
+			name:     "satisfied within the deadline",
+			property: acked(false),
+			trace:    []ds.Event{crash(0), handle(ack, 5), handle(ping, 20)},
+			trigger:  -1,
+		},
+		{
+			name:     "expired within",
+			property: acked(false),
+			trace:    []ds.Event{crash(0), handle(ping, 5), handle(ping, 20), handle(ack, 25)},
+			trigger:  0,
+			deadline: 10 * time.Millisecond,
+		},
+		{
+			name:     "satisfied after the deadline",
+			property: acked(false),
+			trace:    []ds.Event{crash(0), handle(ack, 11)},
+			trigger:  0,
+			deadline: 10 * time.Millisecond,
+		},
+		{
+			name:     "pending at the end of the trace",
+			property: acked(false),
+			trace:    []ds.Event{handle(ping, 0), crash(5), handle(ping, 14)},
+			trigger:  -1,
+		},
+		{
+			name:     "deadline at the end of the trace",
+			property: acked(false),
+			trace:    []ds.Event{handle(ping, 0), crash(5), handle(ping, 15)},
+			trigger:  1,
+			deadline: 15 * time.Millisecond,
+		},
+		{
+			name:     "every trigger is checked",
+			property: acked(false),
+			trace:    []ds.Event{crash(0), crash(15), handle(ack, 20), handle(ping, 30)},
+			trigger:  0,
+			deadline: 10 * time.Millisecond,
+		},
+		{
+			name:     "only the last trigger is checked",
+			property: acked(true),
+			trace:    []ds.Event{crash(0), crash(15), handle(ack, 20), handle(ping, 30)},
+			trigger:  -1,
+		},
+		{
+			name:     "the last trigger expires",
+			property: acked(true),
+			trace:    []ds.Event{crash(0), handle(ack, 5), crash(15), handle(ping, 30)},
+			trigger:  2,
+			deadline: 25 * time.Millisecond,
+		},
+		{
+			name: "no trigger starts at the first event",
+			property: ds.EventuallyProperty{
+				Name: "acked",
+				Satisfied: func(trigger ds.Event, trace []ds.Event) bool {
+					return trace[len(trace)-1].Message.Type == ack
+				},
+				Within: 10 * time.Millisecond,
+			},
+			trace:    []ds.Event{handle(ping, 3), handle(ping, 20)},
+			trigger:  0,
+			deadline: 13 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ds.CheckEventually(test.trace, test.property)
+			if test.trigger < 0 {
+				if err != nil {
+					t.Errorf("CheckEventually() = %v, want nil", err)
+				}
+				return
+			}
+			var violation *ds.LivenessViolation
+			if !errors.As(err, &violation) {
+				t.Fatalf("CheckEventually() = %v, want a violation", err)
+			}
+			if violation.Trigger.Time != test.trace[test.trigger].Time || violation.Deadline != test.deadline {
+				t.Errorf("violation triggered at %v with deadline %v, want %v and %v",
+					violation.Trigger.Time, violation.Deadline, test.trace[test.trigger].Time, test.deadline)
+			}
+		})
+	}
+}
+
+func TestHandledByAll(t *testing.T) {
+	satisfied := ds.HandledByAll([]ds.Address{"a", "b"}, func(message ds.Message) bool { return message.Type == ack })
+	trigger := handle(ping, 0)
+	if satisfied(trigger, []ds.Event{trigger, handle(ack, 5)}) {
+		t.Errorf("satisfied before a handled the ack")
+	}
+	if !satisfied(trigger, []ds.Event{trigger, handle(ack, 5), crash(6)}) {
+		t.Errorf("not satisfied after a crashed")
+	}
+}
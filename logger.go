@@ -1,9 +1,14 @@
 package disse
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 // Logger is an interface that is used to log events in the network.
@@ -30,6 +35,24 @@ type Logger interface {
 	LogDropInterrupt(from, to Address, interrupt Interrupt)
 }
 
+// HandlerLogger is implemented by loggers that need to know when a node has finished handling an event.
+//
+// LogHandlerDone is called after every call to LogHandleMessage, LogHandleTimer and LogHandleInterrupt,
+// once the handlers of the node have returned. Events logged in between are caused by the handler.
+type HandlerLogger interface {
+	LogHandlerDone(to Address, handled bool)
+}
+
+// ArrivalLogger is implemented by loggers that need to know when messages and timers arrive at a node.
+//
+// A message arrives when it is added to the queue of its destination node after latency, and a timer
+// arrives when its duration has passed. The time between arrival and handling is spent in the queue.
+// When the simulation is driven step by step, events arrive at the time they are processed.
+type ArrivalLogger interface {
+	LogArriveMessage(from, to Address, message Message)
+	LogArriveTimer(to Address, timer Timer, duration time.Duration)
+}
+
 // DebugLogger is a Log implementation that logs debug messages to a file.
 type DebugLogger struct {
 	logger *log.Logger
@@ -50,6 +73,15 @@ func NewDebugLogger(logPath string) (*DebugLogger, error) {
 	}, nil
 }
 
+// NewDebugLoggerWithWriter creates a new DebugLogger that logs to the given writer.
+//
+// Lines are not prefixed with the date and time, since the writer is usually not a file.
+func NewDebugLoggerWithWriter(w io.Writer) *DebugLogger {
+	return &DebugLogger{
+		logger: log.New(w, "", 0),
+	}
+}
+
 // LogSimulationState is called when the simulation state changes.
 func (l *DebugLogger) LogSimulationState(sim Simulation) {
 	l.logger.Printf("SimulationState(%v)\n", sim.GetState())
@@ -57,6 +89,10 @@ func (l *DebugLogger) LogSimulationState(sim Simulation) {
 
 // LogNodeState is called when the state of a node changes.
 func (l *DebugLogger) LogNodeState(node Node) {
+	if fsm, ok := node.(FsmStater); ok {
+		l.logger.Printf("NodeState(%v, %v, %v)\n", node.GetAddress(), node.GetState(), fsm.GetFsmState())
+		return
+	}
 	l.logger.Printf("NodeState(%v, %v)\n", node.GetAddress(), node.GetState())
 }
 
@@ -105,9 +141,68 @@ func (l *DebugLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {
 	l.logger.Printf("DropInterrupt(%v -> %v, %v)\n", from, to, interrupt)
 }
 
+const (
+	// umlRowHeight is the approximate height of a row of a PlantUML sequence diagram, in pixels.
+	umlRowHeight = 30
+	// umlMaxSlant is the maximum slant of an arrow in a PlantUML sequence diagram, in pixels.
+	umlMaxSlant = 300
+)
+
+// umlRowKind is the kind of a row of a PlantUML sequence diagram.
+type umlRowKind int
+
+const (
+	// umlArrow is a row with the arrow of a message, timer or interrupt.
+	umlArrow umlRowKind = iota
+	// umlActivate is a row that starts the activation bar of a handler.
+	umlActivate
+	// umlDeactivate is a row that ends the activation bar of a handler.
+	umlDeactivate
+	// umlNote is a row with a note over a node.
+	umlNote
+)
+
+// umlRow is a row of a PlantUML sequence diagram, for a single event.
+//
+// The receipt of an arrow is the index of the row where its message, timer or interrupt was handled, or -1.
+// A row is done once it can be written, which for an arrow is once its message, timer or interrupt was dropped
+// or its handler returned.
+type umlRow struct {
+	kind    umlRowKind
+	from    Address
+	to      Address
+	label   string
+	dashed  bool
+	dropped bool
+	receipt int
+	done    bool
+}
+
 // UmlLogger is a Log implementation that logs messages in the PlantUML format.
+//
+// Rows are written as soon as every row before them is done, so that every arrow is drawn knowing what happened
+// to its message, timer or interrupt. Rows that are not done yet hold back the rows after them, at most until
+// the simulation finishes. The diagram uses the teoz layout:
+//   - The arrow of a message or interrupt slants down to the row where it was handled, so it ends level with its
+//     receipt, and ends with a cross ("->x") if it was dropped. Replies are dashed. Slants are measured in rows,
+//     so they show the order of sends and receipts rather than their latency.
+//   - Timers are drawn as self arrows when they fire, ending with a cross if they were dropped.
+//   - Handlers are drawn as activation bars, from when the event is handled until the handler returns.
+//     An event handled by a node without a handler for it is drawn as dropped.
+//   - Sub nodes have their own lifelines, grouped in a box with their parent.
+//   - States of finite state machines and nodes that sleep or stop are drawn as notes.
+//
+// It is safe to use from multiple goroutines.
 type UmlLogger struct {
-	logger *log.Logger
+	logger    *log.Logger
+	mu        sync.Mutex
+	declared  map[Address]bool
+	states    map[Address]NodeState
+	rows      []umlRow
+	base      int
+	pending   map[string][]int
+	unhandled map[string]int
+	handling  map[Address]string
 }
 
 // NewUmlLogger creates a new UmlLog that logs to the given file.
@@ -122,62 +217,325 @@ func NewUmlLogger(logPath string) (*UmlLogger, error) {
 	}
 	umlLog := log.New(logfile, prefix, flag)
 	return &UmlLogger{
-		logger: umlLog,
+		logger:    umlLog,
+		declared:  make(map[Address]bool),
+		states:    make(map[Address]NodeState),
+		rows:      make([]umlRow, 0),
+		pending:   make(map[string][]int),
+		unhandled: make(map[string]int),
+		handling:  make(map[Address]string),
 	}, nil
 }
 
+// umlId returns the identifier of the participant of a node in a PlantUML diagram.
+//
+// Letters and digits are kept, underscores are doubled and every other character is replaced by its code point
+// in hexadecimal between underscores, so different addresses always have different identifiers.
+func umlId(address Address) string {
+	var b strings.Builder
+	for _, r := range string(address) {
+		switch {
+		case r == '_':
+			b.WriteString("__")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			fmt.Fprintf(&b, "_%x_", r)
+		}
+	}
+	return b.String()
+}
+
+// row returns the row with the given index.
+func (l *UmlLogger) row(i int) *umlRow {
+	return &l.rows[i-l.base]
+}
+
+// add appends a row to the diagram and returns its index.
+func (l *UmlLogger) add(row umlRow) int {
+	row.done = row.kind != umlArrow
+	l.rows = append(l.rows, row)
+	return l.base + len(l.rows) - 1
+}
+
+// send adds the arrow of a message, timer or interrupt with the given id, which is completed when it is received.
+func (l *UmlLogger) send(id string, row umlRow) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending[id] = append(l.pending[id], l.sendArrow(row))
+}
+
+// sendArrow adds the arrow of a message, timer or interrupt that has not been received yet, and returns its index.
+func (l *UmlLogger) sendArrow(row umlRow) int {
+	row.kind = umlArrow
+	row.receipt = -1
+	return l.add(row)
+}
+
+// receive completes the arrow of the message, timer or interrupt with the given id, and starts the activation
+// bar of its handler unless it was dropped.
+//
+// A message or timer that is handled by a node without a handler for it is handled and then dropped, which
+// marks its arrow as dropped without adding another row.
+func (l *UmlLogger) receive(id string, to Address, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.flush()
+	l.complete(id, to, dropped)
+}
+
+// fire adds the arrow of a timer with the given id that fired, and completes it.
+func (l *UmlLogger) fire(id string, row umlRow, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.flush()
+	if _, ok := l.unhandled[id]; !ok || !dropped {
+		l.pending[id] = append(l.pending[id], l.sendArrow(row))
+	}
+	l.complete(id, row.to, dropped)
+}
+
+// complete completes the arrow of the message, timer or interrupt with the given id.
+func (l *UmlLogger) complete(id string, to Address, dropped bool) {
+	if send, ok := l.unhandled[id]; ok && dropped {
+		delete(l.unhandled, id)
+		l.row(send).dropped = true
+		l.row(send).done = true
+		return
+	}
+	row := -1
+	if !dropped {
+		row = l.add(umlRow{kind: umlActivate, to: to})
+		l.handling[to] = id
+	}
+	sends := l.pending[id]
+	if len(sends) == 0 {
+		return
+	}
+	if len(sends) == 1 {
+		delete(l.pending, id)
+	} else {
+		l.pending[id] = sends[1:]
+	}
+	l.row(sends[0]).receipt = row
+	l.row(sends[0]).dropped = dropped
+	l.row(sends[0]).done = dropped
+	if !dropped {
+		l.unhandled[id] = sends[0]
+	}
+}
+
+// LogHandlerDone is called when a node has finished handling a message, timer or interrupt.
+//
+// It ends the activation bar of the handler.
+func (l *UmlLogger) LogHandlerDone(to Address, handled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.flush()
+	l.add(umlRow{kind: umlDeactivate, to: to})
+	if send, ok := l.unhandled[l.handling[to]]; ok && handled {
+		l.row(send).done = true
+		delete(l.unhandled, l.handling[to])
+	}
+	delete(l.handling, to)
+}
+
+// slant returns the slant of the arrow in the given row, which is the height of the rows between it and its receipt.
+func (l *UmlLogger) slant(i int) int {
+	receipt := l.row(i).receipt
+	slant := 0
+	for j := i + 1; j < receipt && slant < umlMaxSlant; j++ {
+		if kind := l.row(j).kind; kind == umlArrow || kind == umlNote {
+			slant += umlRowHeight
+		}
+	}
+	if slant > umlMaxSlant {
+		slant = umlMaxSlant
+	}
+	return slant
+}
+
+// flush writes the rows that are done and have no row before them that is not done.
+func (l *UmlLogger) flush() {
+	n := 0
+	for n < len(l.rows) && l.rows[n].done {
+		l.write(l.base + n)
+		n++
+	}
+	l.rows = l.rows[n:]
+	l.base += n
+}
+
+// write writes the row with the given index, declaring any participant it involves that was not declared yet.
+func (l *UmlLogger) write(i int) {
+	row := l.row(i)
+	for _, address := range []Address{row.from, row.to} {
+		if address != "" && !l.declared[address] {
+			l.declared[address] = true
+			l.logger.Printf("participant \"%v\" as %v\n", address, umlId(address))
+		}
+	}
+	switch row.kind {
+	case umlArrow:
+		arrow := "->"
+		if row.dashed {
+			arrow = "-->"
+		}
+		if row.dropped {
+			arrow += "x"
+		} else if slant := l.slant(i); slant > 0 && row.from != row.to {
+			arrow = fmt.Sprintf("%v(%v)", arrow, slant)
+		}
+		l.logger.Printf("%v %v %v : %v\n", umlId(row.from), arrow, umlId(row.to), row.label)
+	case umlActivate:
+		l.logger.Printf("activate %v\n", umlId(row.to))
+	case umlDeactivate:
+		l.logger.Printf("deactivate %v\n", umlId(row.to))
+	case umlNote:
+		l.logger.Printf("hnote over %v : %v\n", umlId(row.to), row.label)
+	}
+}
+
+// start writes the header of the diagram and declares the participants of the simulation, in the order of their
+// addresses, if the simulation has a GetNodes method.
+//
+// Nodes with sub nodes are grouped in a box with their sub nodes.
+func (l *UmlLogger) start(sim Simulation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger.Println("@startuml")
+	l.logger.Println("!pragma teoz true")
+	l.logger.Println("!theme reddress-lightred")
+	l.logger.Println("skinparam shadowing false")
+	l.logger.Println("skinparam sequenceArrowThickness 1")
+	l.logger.Println("skinparam responseMessageBelowArrow true")
+	l.logger.Println("skinparam sequenceMessageAlign right")
+	s, ok := sim.(interface{ GetNodes() map[Address]Node })
+	if !ok {
+		return
+	}
+	nodes := s.GetNodes()
+	for _, address := range sortedAddresses(nodes) {
+		subNodes := make([]Address, 0)
+		var collect func(node Node)
+		collect = func(node Node) {
+			children := node.GetSubNodes()
+			for _, child := range sortedAddresses(children) {
+				subNodes = append(subNodes, child)
+				collect(children[child])
+			}
+		}
+		collect(nodes[address])
+		if len(subNodes) == 0 {
+			l.declare(address)
+			continue
+		}
+		l.logger.Printf("box \"%v\"\n", address)
+		l.declare(address)
+		for _, subNode := range subNodes {
+			l.declare(subNode)
+		}
+		l.logger.Println("end box")
+	}
+}
+
+// declare declares a node as a participant of the diagram.
+func (l *UmlLogger) declare(address Address) {
+	l.declared[address] = true
+	l.logger.Printf("participant \"%v\" as %v\n", address, umlId(address))
+}
+
+// finish writes the rows that are left and ends the diagram.
+//
+// Arrows of messages and interrupts that were never received are drawn without a slant.
+func (l *UmlLogger) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.rows {
+		l.rows[i].done = true
+	}
+	l.flush()
+	l.logger.Println("@enduml")
+}
+
 // LogSimulationState is called when the simulation state changes.
+//
+// The header of the diagram is written when the simulation starts, and the diagram is ended when it finishes.
 func (l *UmlLogger) LogSimulationState(sim Simulation) {
 	switch sim.GetState() {
 	case SimulationNotStarted:
-		l.logger.Println("@startuml")
-		l.logger.Println("!theme reddress-lightred")
-		l.logger.Println("skinparam shadowing false")
-		l.logger.Println("skinparam sequenceArrowThickness 1")
-		l.logger.Println("skinparam responseMessageBelowArrow true")
-		l.logger.Println("skinparam sequenceMessageAlign right")
-	case SimulationRunning:
-		// do nothing
+		l.start(sim)
 	case SimulationFinished:
-		l.logger.Println("@enduml")
+		l.finish()
 	}
 }
 
 // LogNodeState is called when the state of a node changes.
-func (l *UmlLogger) LogNodeState(node Node) {}
+//
+// The state of nodes that are finite state machines is drawn as a note over the node. Nodes that sleep or
+// stop, and nodes that run again after sleeping or stopping, are also drawn with a note.
+func (l *UmlLogger) LogNodeState(node Node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.flush()
+	address, state := node.GetAddress(), node.GetState()
+	previous, ok := l.states[address]
+	l.states[address] = state
+	switch {
+	case state == Stopped || state == Sleeping || (ok && previous != Running && state == Running):
+		l.add(umlRow{kind: umlNote, to: address, label: string(state)})
+	}
+	if fsm, ok := node.(FsmStater); ok {
+		l.add(umlRow{kind: umlNote, to: address, label: string(fsm.GetFsmState())})
+	}
+}
 
 // LogSendMessage is called when a message is sent.
+//
+// Replies to a call are drawn with a dashed arrow.
 func (l *UmlLogger) LogSendMessage(from, to Address, message Message) {
-	l.logger.Printf("%v -> %v : %v\n", from, to, message.Type)
+	l.send(string(message.Id), umlRow{from: from, to: to, label: string(message.Type), dashed: message.ReplyTo != ""})
 }
 
 // LogHandleMessage is called when a message is handled.
-func (l *UmlLogger) LogHandleMessage(from, to Address, message Message) {}
+func (l *UmlLogger) LogHandleMessage(from, to Address, message Message) {
+	l.receive(string(message.Id), to, false)
+}
 
 // LogDropMessage is called when a message is dropped.
-func (l *UmlLogger) LogDropMessage(from, to Address, message Message) {}
+func (l *UmlLogger) LogDropMessage(from, to Address, message Message) {
+	l.receive(string(message.Id), to, true)
+}
 
 // LogSetTimer is called when a timer is set.
-func (l *UmlLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {
-	l.logger.Printf("%v -> %v : %v\n", to, to, timer.Type)
-}
+//
+// Timers are drawn when they fire, so that they do not hold back the rows after them.
+func (l *UmlLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {}
 
 // LogHandleTimer is called when a timer is handled.
-func (l *UmlLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {}
+func (l *UmlLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	l.fire(string(timer.Id), umlRow{from: to, to: to, label: string(timer.Type)}, false)
+}
 
 // LogDropTimer is called when a timer is dropped.
-func (l *UmlLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {}
+func (l *UmlLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {
+	l.fire(string(timer.Id), umlRow{from: to, to: to, label: string(timer.Type)}, true)
+}
 
 // LogSendInterrupt is called when an interrupt is sent.
 func (l *UmlLogger) LogSendInterrupt(from, to Address, interrupt Interrupt) {
-	l.logger.Printf("%v -> %v : %v\n", from, to, interrupt.Type)
+	l.send(string(interrupt.Id), umlRow{from: from, to: to, label: string(interrupt.Type)})
 }
 
 // LogHandleInterrupt is called when an interrupt is handled.
-func (l *UmlLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {}
+func (l *UmlLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	l.receive(string(interrupt.Id), to, false)
+}
 
 // LogDropInterrupt is called when an interrupt is dropped.
-func (l *UmlLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {}
+func (l *UmlLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {
+	l.receive(string(interrupt.Id), to, true)
+}
 
 // LogSimulationState is called when the simulation state changes.
 //
@@ -201,6 +559,7 @@ func (s *LocalSimulation) LogNodeState(node Node) {
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogSendMessage(from, to Address, message Message) {
+	message = s.ids.message(message)
 	for _, log := range s.loggers {
 		log.LogSendMessage(from, to, message)
 	}
@@ -210,6 +569,7 @@ func (s *LocalSimulation) LogSendMessage(from, to Address, message Message) {
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogHandleMessage(from, to Address, message Message) {
+	message = s.ids.message(message)
 	for _, log := range s.loggers {
 		log.LogHandleMessage(from, to, message)
 	}
@@ -219,6 +579,7 @@ func (s *LocalSimulation) LogHandleMessage(from, to Address, message Message) {
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogDropMessage(from, to Address, message Message) {
+	message = s.ids.message(message)
 	for _, log := range s.loggers {
 		log.LogDropMessage(from, to, message)
 	}
@@ -228,6 +589,7 @@ func (s *LocalSimulation) LogDropMessage(from, to Address, message Message) {
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogSetTimer(to Address, timer Timer, duration time.Duration) {
+	timer = s.ids.timer(timer)
 	for _, log := range s.loggers {
 		log.LogSetTimer(to, timer, duration)
 	}
@@ -237,6 +599,7 @@ func (s *LocalSimulation) LogSetTimer(to Address, timer Timer, duration time.Dur
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	timer = s.ids.timer(timer)
 	for _, log := range s.loggers {
 		log.LogHandleTimer(to, timer, duration)
 	}
@@ -246,6 +609,7 @@ func (s *LocalSimulation) LogHandleTimer(to Address, timer Timer, duration time.
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogDropTimer(to Address, timer Timer, duration time.Duration) {
+	timer = s.ids.timer(timer)
 	for _, log := range s.loggers {
 		log.LogDropTimer(to, timer, duration)
 	}
@@ -255,6 +619,7 @@ func (s *LocalSimulation) LogDropTimer(to Address, timer Timer, duration time.Du
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogSendInterrupt(from, to Address, interrupt Interrupt) {
+	interrupt = s.ids.interrupt(interrupt)
 	for _, log := range s.loggers {
 		log.LogSendInterrupt(from, to, interrupt)
 	}
@@ -264,6 +629,7 @@ func (s *LocalSimulation) LogSendInterrupt(from, to Address, interrupt Interrupt
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	interrupt = s.ids.interrupt(interrupt)
 	for _, log := range s.loggers {
 		log.LogHandleInterrupt(from, to, interrupt)
 	}
@@ -273,7 +639,43 @@ func (s *LocalSimulation) LogHandleInterrupt(from, to Address, interrupt Interru
 //
 // This method is called for all logs in the simulation.
 func (s *LocalSimulation) LogDropInterrupt(from, to Address, interrupt Interrupt) {
+	interrupt = s.ids.interrupt(interrupt)
 	for _, log := range s.loggers {
 		log.LogDropInterrupt(from, to, interrupt)
 	}
 }
+
+// LogHandlerDone is called when a node has finished handling a message, timer or interrupt.
+//
+// This method is called for all logs in the simulation that implement HandlerLogger.
+func (s *LocalSimulation) LogHandlerDone(to Address, handled bool) {
+	for _, log := range s.loggers {
+		if h, ok := log.(HandlerLogger); ok {
+			h.LogHandlerDone(to, handled)
+		}
+	}
+}
+
+// LogArriveMessage is called when a message arrives at its destination node.
+//
+// This method is called for all logs in the simulation that implement ArrivalLogger.
+func (s *LocalSimulation) LogArriveMessage(from, to Address, message Message) {
+	message = s.ids.message(message)
+	for _, log := range s.loggers {
+		if a, ok := log.(ArrivalLogger); ok {
+			a.LogArriveMessage(from, to, message)
+		}
+	}
+}
+
+// LogArriveTimer is called when a timer arrives at its node.
+//
+// This method is called for all logs in the simulation that implement ArrivalLogger.
+func (s *LocalSimulation) LogArriveTimer(to Address, timer Timer, duration time.Duration) {
+	timer = s.ids.timer(timer)
+	for _, log := range s.loggers {
+		if a, ok := log.(ArrivalLogger); ok {
+			a.LogArriveTimer(to, timer, duration)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package disse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VectorClock maps the address of each top level node to the number of events that occurred at it.
+type VectorClock map[Address]uint64
+
+// Copy returns a copy of the vector clock.
+func (v VectorClock) Copy() VectorClock {
+	c := make(VectorClock, len(v))
+	for address, count := range v {
+		c[address] = count
+	}
+	return c
+}
+
+// Merge sets every entry of the vector clock to the maximum of its value and the value in other.
+func (v VectorClock) Merge(other VectorClock) {
+	for address, count := range other {
+		if count > v[address] {
+			v[address] = count
+		}
+	}
+}
+
+// HappensBefore returns true if the event with this vector clock happened before the event with the other vector clock.
+func (v VectorClock) HappensBefore(other VectorClock) bool {
+	for address, count := range v {
+		if count > other[address] {
+			return false
+		}
+	}
+	for address, count := range other {
+		if count > v[address] {
+			return true
+		}
+	}
+	return false
+}
+
+// Concurrent returns true if neither of the events with the vector clocks happened before the other.
+func (v VectorClock) Concurrent(other VectorClock) bool {
+	return !v.HappensBefore(other) && !other.HappensBefore(v)
+}
+
+// String returns a string representation of the vector clock for debugging purposes, with the addresses in lexicographic order.
+func (v VectorClock) String() string {
+	addresses := make([]Address, 0, len(v))
+	for address := range v {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	entries := make([]string, len(addresses))
+	for i, address := range addresses {
+		entries[i] = fmt.Sprintf("%v:%v", address, v[address])
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// LogicalClocker is implemented by clocks that also keep the logical clocks of nodes.
+//
+// LocalSimulation implements LogicalClocker. Loggers created with it as their clock can use
+// LogicalClock to find the clocks of the node at which an event occurred, after the event.
+type LogicalClocker interface {
+	LogicalClock(address Address) (lamport uint64, vector VectorClock)
+}
+
+// LogicalClock returns a copy of the Lamport and vector clocks of the top level node with the given address.
+//
+// The clocks are zero if the simulation does not track logical clocks.
+func (s *LocalSimulation) LogicalClock(address Address) (uint64, VectorClock) {
+	if !s.options.LogicalClocks {
+		return 0, nil
+	}
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	root := address.GetRoot()
+	return s.lamport[root], s.vector[root].Copy()
+}
+
+// tick advances the logical clocks of a node for a local event, such as sending a message or handling a timer.
+func (s *LocalSimulation) tick(address Address) {
+	root := address.GetRoot()
+	s.lamport[root]++
+	if s.vector[root] == nil {
+		s.vector[root] = make(VectorClock)
+	}
+	s.vector[root][root]++
+}
+
+// stampMessage advances the logical clocks of the sender of a message and returns the message stamped with them.
+func (s *LocalSimulation) stampMessage(from Address, message Message) Message {
+	if !s.options.LogicalClocks {
+		return message
+	}
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	s.tick(from)
+	root := from.GetRoot()
+	message.Lamport = s.lamport[root]
+	message.Vector = s.vector[root].Copy()
+	return message
+}
+
+// receiveClocks merges the logical clocks of a message into the clocks of the node that handles it.
+func (s *LocalSimulation) receiveClocks(to Address, message Message) {
+	if !s.options.LogicalClocks {
+		return
+	}
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	root := to.GetRoot()
+	if message.Lamport > s.lamport[root] {
+		s.lamport[root] = message.Lamport
+	}
+	s.tick(root)
+	s.vector[root].Merge(message.Vector)
+}
+
+// tickClocks advances the logical clocks of a node that handles a timer or interrupt.
+func (s *LocalSimulation) tickClocks(to Address) {
+	if !s.options.LogicalClocks {
+		return
+	}
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	s.tick(to)
+}
+
+// saveClocks returns a copy of the logical clocks of all nodes.
+//
+// It is used by the model checker to save the state of the simulation.
+func (s *LocalSimulation) saveClocks() (map[Address]uint64, map[Address]VectorClock) {
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	lamport := make(map[Address]uint64, len(s.lamport))
+	vector := make(map[Address]VectorClock, len(s.vector))
+	for address, clock := range s.lamport {
+		lamport[address] = clock
+	}
+	for address, clock := range s.vector {
+		vector[address] = clock.Copy()
+	}
+	return lamport, vector
+}
+
+// restoreClocks restores the logical clocks previously returned by saveClocks.
+func (s *LocalSimulation) restoreClocks(lamport map[Address]uint64, vector map[Address]VectorClock) {
+	s.clocksMu.Lock()
+	defer s.clocksMu.Unlock()
+	s.lamport = make(map[Address]uint64, len(lamport))
+	s.vector = make(map[Address]VectorClock, len(vector))
+	for address, clock := range lamport {
+		s.lamport[address] = clock
+	}
+	for address, clock := range vector {
+		s.vector[address] = clock.Copy()
+	}
+}
@@ -0,0 +1,143 @@
+package disse_test
+
+import (
+	"testing"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+func TestVectorClockHappensBefore(t *testing.T) {
+	tests := []struct {
+		name       string
+		v, other   ds.VectorClock
+		before     bool
+		after      bool
+		concurrent bool
+	}{
+		{name: "same node", v: ds.VectorClock{"a": 1}, other: ds.VectorClock{"a": 2}, before: true},
+		{name: "after a message", v: ds.VectorClock{"a": 1}, other: ds.VectorClock{"a": 1, "b": 1}, before: true},
+		{name: "later", v: ds.VectorClock{"a": 2, "b": 3}, other: ds.VectorClock{"a": 1, "b": 3}, after: true},
+		{name: "different nodes", v: ds.VectorClock{"a": 1}, other: ds.VectorClock{"b": 1}, concurrent: true},
+		{name: "crossed", v: ds.VectorClock{"a": 2, "b": 1}, other: ds.VectorClock{"a": 1, "b": 2}, concurrent: true},
+		{name: "equal", v: ds.VectorClock{"a": 1, "b": 1}, other: ds.VectorClock{"a": 1, "b": 1}, concurrent: true},
+		{name: "empty", v: ds.VectorClock{}, other: ds.VectorClock{"a": 1}, before: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.v.HappensBefore(test.other); got != test.before {
+				t.Errorf("%v.HappensBefore(%v) = %v, want %v", test.v, test.other, got, test.before)
+			}
+			if got := test.other.HappensBefore(test.v); got != test.after {
+				t.Errorf("%v.HappensBefore(%v) = %v, want %v", test.other, test.v, got, test.after)
+			}
+			if got := test.v.Concurrent(test.other); got != test.concurrent {
+				t.Errorf("%v.Concurrent(%v) = %v, want %v", test.v, test.other, got, test.concurrent)
+			}
+		})
+	}
+}
+
+func TestVectorClockMerge(t *testing.T) {
+	v := ds.VectorClock{"a": 3, "b": 1}
+	c := v.Copy()
+	v.Merge(ds.VectorClock{"b": 2, "c": 1})
+	if got, want := v.String(), "{a:3, b:2, c:1}"; got != want {
+		t.Errorf("merged clock %v, want %v", got, want)
+	}
+	if got, want := c.String(), "{a:3, b:1}"; got != want {
+		t.Errorf("copy changed to %v, want %v", got, want)
+	}
+}
+
+// findEvent returns the first event of the given kind for a message of the given type between the given nodes.
+func findEvent(t *testing.T, events []ds.Event, kind ds.EventKind, messageType ds.MessageType, from, to ds.Address) ds.Event {
+	t.Helper()
+	for _, event := range events {
+		if event.Kind == kind && event.Message.Type == messageType && event.From == from && event.To == to {
+			return event
+		}
+	}
+	t.Fatalf("no %v of %v from %v to %v", kind, messageType, from, to)
+	return ds.Event{}
+}
+
+func TestLogicalClocks(t *testing.T) {
+	options := testOptions()
+	options.LogicalClocks = true
+	sim := ds.NewLocalSimulation(options)
+	sim.AddNode(newPingNode(sim, "a", "b", "c"))
+	sim.AddNode(newPingNode(sim, "b"))
+	sim.AddNode(newPingNode(sim, "c"))
+	trace := ds.NewTraceLogger(sim)
+	sim.AddLogger(trace)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	events := trace.Events()
+
+	sendPingB := findEvent(t, events, ds.SendMessageEvent, ping, "a", "b")
+	handlePingB := findEvent(t, events, ds.HandleMessageEvent, ping, "a", "b")
+	sendPongB := findEvent(t, events, ds.SendMessageEvent, pong, "b", "a")
+	handlePingC := findEvent(t, events, ds.HandleMessageEvent, ping, "a", "c")
+	sendPongC := findEvent(t, events, ds.SendMessageEvent, pong, "c", "a")
+
+	// a sends two pings, so b receives the first with Lamport clock 1.
+	clocks := []struct {
+		event   ds.Event
+		lamport uint64
+		vector  string
+	}{
+		{sendPingB, 1, "{a:1}"},
+		{handlePingB, 2, "{a:1, b:1}"},
+		{sendPongB, 3, "{a:1, b:2}"},
+		{handlePingC, 3, "{a:2, c:1}"},
+		{sendPongC, 4, "{a:2, c:2}"},
+	}
+	for _, c := range clocks {
+		if c.event.Lamport != c.lamport || c.event.Vector.String() != c.vector {
+			t.Errorf("%v has clocks %d %v, want %d %v", c.event, c.event.Lamport, c.event.Vector, c.lamport, c.vector)
+		}
+	}
+	if sendPongB.Message.Lamport != 3 || sendPongB.Message.Vector.String() != "{a:1, b:2}" {
+		t.Errorf("pong of b stamped with %d %v, want the clocks of b when it was sent", sendPongB.Message.Lamport, sendPongB.Message.Vector)
+	}
+
+	pairs := []struct {
+		name         string
+		first, later ds.Event
+		before       bool
+	}{
+		{"send and handle", sendPingB, handlePingB, true},
+		{"request and reply", sendPingB, sendPongB, true},
+		{"ping to c before ping to b is handled", sendPingB, handlePingC, true},
+		{"pings handled by different nodes", handlePingB, handlePingC, false},
+		{"pong of b and ping of c", sendPongB, handlePingC, false},
+	}
+	for _, pair := range pairs {
+		if got := pair.first.HappensBefore(pair.later); got != pair.before {
+			t.Errorf("%v: HappensBefore() = %v, want %v", pair.name, got, pair.before)
+		}
+		if pair.later.HappensBefore(pair.first) {
+			t.Errorf("%v: later event happens before the first", pair.name)
+		}
+	}
+}
+
+func TestLogicalClocksDisabled(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	trace := ds.NewTraceLogger(sim)
+	sim.AddLogger(trace)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	events := trace.Events()
+	for _, event := range events {
+		if event.Lamport != 0 || event.Vector != nil || event.Message.Vector != nil {
+			t.Errorf("%v has clocks %d %v without logical clocks", event, event.Lamport, event.Vector)
+		}
+	}
+	send, handle := findEvent(t, events, ds.SendMessageEvent, ping, "a", "b"), findEvent(t, events, ds.HandleMessageEvent, ping, "a", "b")
+	if send.HappensBefore(handle) {
+		t.Errorf("HappensBefore() = true without logical clocks")
+	}
+}
@@ -18,10 +18,20 @@ type MessageType string
 type MessageData any
 
 // Message is a message that is sent to a node.
+//
+// ReplyTo is the id of the request a message replies to, and is empty for messages that are not replies.
+// Lamport and Vector are the logical clocks of the sender when the message was sent, and are only set
+// if the simulation tracks logical clocks. ParentId is the id of the message, timer or interrupt that the
+// sender was handling when it sent the message, taken from the context given to the handler. It is empty for
+// messages sent with any other context, such as the one given to Init.
 type Message struct {
-	Id   MessageId
-	Type MessageType
-	Data MessageData
+	Id       MessageId
+	Type     MessageType
+	Data     MessageData
+	ReplyTo  MessageId
+	Lamport  uint64
+	Vector   VectorClock
+	ParentId string
 }
 
 // String returns a string representation of the message for debugging purposes.
@@ -38,6 +48,13 @@ func NewMessage(messageType MessageType, data MessageData) Message {
 	}
 }
 
+// NewReply creates a new message with the given messageType and data that replies to the given request.
+func NewReply(request Message, messageType MessageType, data MessageData) Message {
+	reply := NewMessage(messageType, data)
+	reply.ReplyTo = request.Id
+	return reply
+}
+
 // MessageTriplet is a triplet of a message, the address of the sender and the address of the receiver.
 type MessageTriplet struct {
 	Message Message
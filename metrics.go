@@ -0,0 +1,608 @@
+package disse
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the buckets of the latency histograms of a MetricsLogger.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// Histogram counts durations in buckets with fixed upper bounds.
+//
+// Counts[i] is the number of durations less than or equal to Bounds[i] and greater than the previous bound,
+// and the last count is the number of durations greater than every bound.
+type Histogram struct {
+	Bounds []time.Duration `json:"bounds"`
+	Counts []int           `json:"counts"`
+	Count  int             `json:"count"`
+	Sum    time.Duration   `json:"sum"`
+	Min    time.Duration   `json:"min"`
+	Max    time.Duration   `json:"max"`
+}
+
+// NewHistogram creates a new empty histogram with the given bucket bounds, which must be sorted.
+func NewHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{
+		Bounds: bounds,
+		Counts: make([]int, len(bounds)+1),
+	}
+}
+
+// Observe adds a duration to the histogram.
+func (h *Histogram) Observe(d time.Duration) {
+	h.Counts[sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Sum += d
+}
+
+// Mean returns the mean of the durations in the histogram, or 0 if it is empty.
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an estimate of the q-quantile of the durations in the histogram, which is the upper
+// bound of the bucket the quantile falls in, or the maximum if it falls in the last bucket.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank, seen := int(q*float64(h.Count-1))+1, 0
+	for i, count := range h.Counts {
+		seen += count
+		if seen >= rank && i < len(h.Bounds) && h.Bounds[i] < h.Max {
+			return h.Bounds[i]
+		}
+		if seen >= rank {
+			return h.Max
+		}
+	}
+	return h.Max
+}
+
+// copy returns a deep copy of the histogram.
+func (h *Histogram) copy() *Histogram {
+	c := *h
+	c.Counts = append([]int(nil), h.Counts...)
+	return &c
+}
+
+// EventCounts counts the messages, timers or interrupts that were sent, handled and dropped.
+//
+// For timers, Sent is the number of timers that were set.
+type EventCounts struct {
+	Sent    int `json:"sent"`
+	Handled int `json:"handled"`
+	Dropped int `json:"dropped"`
+}
+
+// NodeMetrics are the metrics of a single node.
+//
+// Messages and interrupts are counted as sent by the sender and as handled or dropped by the receiver.
+// QueueDepth is the number of messages that have arrived at the node and are waiting to be handled or dropped.
+// When the simulation is driven step by step, messages are handled as soon as they arrive, so it is at most one.
+// State is the last state of the node, StateChanges counts how many times the node entered each state,
+// and Uptime is the total time the node has been running.
+type NodeMetrics struct {
+	Messages      EventCounts       `json:"messages"`
+	Timers        EventCounts       `json:"timers"`
+	Interrupts    EventCounts       `json:"interrupts"`
+	QueueDepth    int               `json:"queueDepth"`
+	MaxQueueDepth int               `json:"maxQueueDepth"`
+	State         NodeState         `json:"state"`
+	StateChanges  map[NodeState]int `json:"stateChanges"`
+	Uptime        time.Duration     `json:"uptime"`
+}
+
+// Metrics are the statistics of a simulation collected by a MetricsLogger.
+//
+// Latency is the time from when a message is sent until it is handled, over all messages and by message type.
+type Metrics struct {
+	Duration       time.Duration                `json:"duration"`
+	Messages       EventCounts                  `json:"messages"`
+	Timers         EventCounts                  `json:"timers"`
+	Interrupts     EventCounts                  `json:"interrupts"`
+	MessagesByType map[MessageType]*EventCounts `json:"messagesByType"`
+	TimersByType   map[TimerType]*EventCounts   `json:"timersByType"`
+	Nodes          map[Address]*NodeMetrics     `json:"nodes"`
+	Latency        *Histogram                   `json:"latency"`
+	LatencyByType  map[MessageType]*Histogram   `json:"latencyByType"`
+}
+
+// WriteJSON writes the metrics as JSON to the file at the given path.
+//
+// Durations are written in nanoseconds.
+func (m *Metrics) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// WriteCSV writes the metrics as CSV to the file at the given path.
+//
+// Every row holds a single value with the columns scope, name, metric and value, where the scope is
+// "simulation", "messageType", "timerType" or "node" and the name is the type or address it applies to.
+// Durations are written in nanoseconds.
+func (m *Metrics) WriteCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	w.Write([]string{"scope", "name", "metric", "value"})
+	row := func(scope, name, metric string, value any) {
+		w.Write([]string{scope, name, metric, fmt.Sprint(value)})
+	}
+	counts := func(scope, name, prefix string, c *EventCounts) {
+		row(scope, name, prefix+"_sent", c.Sent)
+		row(scope, name, prefix+"_handled", c.Handled)
+		row(scope, name, prefix+"_dropped", c.Dropped)
+	}
+	histogram := func(scope, name string, h *Histogram) {
+		row(scope, name, "latency_count", h.Count)
+		row(scope, name, "latency_mean_ns", int64(h.Mean()))
+		row(scope, name, "latency_min_ns", int64(h.Min))
+		row(scope, name, "latency_max_ns", int64(h.Max))
+		for i, bound := range h.Bounds {
+			row(scope, name, "latency_le_"+strconv.FormatInt(int64(bound), 10)+"_ns", h.Counts[i])
+		}
+		row(scope, name, "latency_le_inf_ns", h.Counts[len(h.Bounds)])
+	}
+	row("simulation", "", "duration_ns", int64(m.Duration))
+	counts("simulation", "", "messages", &m.Messages)
+	counts("simulation", "", "timers", &m.Timers)
+	counts("simulation", "", "interrupts", &m.Interrupts)
+	histogram("simulation", "", m.Latency)
+	for _, t := range sortedKeys(m.MessagesByType) {
+		counts("messageType", string(t), "messages", m.MessagesByType[t])
+		if h, ok := m.LatencyByType[t]; ok {
+			histogram("messageType", string(t), h)
+		}
+	}
+	for _, t := range sortedKeys(m.TimersByType) {
+		counts("timerType", string(t), "timers", m.TimersByType[t])
+	}
+	for _, a := range sortedKeys(m.Nodes) {
+		n := m.Nodes[a]
+		counts("node", string(a), "messages", &n.Messages)
+		counts("node", string(a), "timers", &n.Timers)
+		counts("node", string(a), "interrupts", &n.Interrupts)
+		row("node", string(a), "queue_depth", n.QueueDepth)
+		row("node", string(a), "max_queue_depth", n.MaxQueueDepth)
+		row("node", string(a), "state", n.State)
+		for _, state := range sortedKeys(n.StateChanges) {
+			row("node", string(a), "state_changes_"+string(state), n.StateChanges[state])
+		}
+		row("node", string(a), "uptime_ns", int64(n.Uptime))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// sortedKeys returns the keys of a map in increasing order.
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// MetricsLogger is a Logger implementation that aggregates statistics about the events of a simulation.
+//
+// Latencies are measured by matching the events of messages with the same id, so messages that are
+// sent again with the same id are matched in the order they were sent.
+// An event is counted as handled once its handler has returned, and an event that is handled by a node
+// without a handler for it, and then dropped, is only counted as dropped.
+// Queue depths are measured from the arrival of messages, which is reported through ArrivalLogger.
+// It is safe to use from multiple goroutines.
+type MetricsLogger struct {
+	clock     Clock
+	mu        sync.Mutex
+	metrics   Metrics
+	buckets   []time.Duration
+	sent      map[MessageId][]time.Duration
+	queued    map[MessageId]int
+	running   map[Address]time.Duration
+	handling  map[Address]metricsHandler
+	unhandled map[string]bool
+}
+
+// metricsState is a saved state of a MetricsLogger.
+type metricsState struct {
+	metrics   *Metrics
+	sent      map[MessageId][]time.Duration
+	queued    map[MessageId]int
+	running   map[Address]time.Duration
+	unhandled map[string]bool
+}
+
+// metricsHandler is an event being handled by a node, which is counted as handled when its handler returns.
+type metricsHandler struct {
+	id      string
+	handled func()
+}
+
+// NewMetricsLogger creates a new MetricsLogger that measures time using the given clock.
+func NewMetricsLogger(clock Clock) *MetricsLogger {
+	return NewMetricsLoggerWithBuckets(clock, DefaultLatencyBuckets)
+}
+
+// NewMetricsLoggerWithBuckets creates a new MetricsLogger whose latency histograms have the given bucket bounds.
+func NewMetricsLoggerWithBuckets(clock Clock, buckets []time.Duration) *MetricsLogger {
+	return &MetricsLogger{
+		clock:   clock,
+		buckets: buckets,
+		metrics: Metrics{
+			MessagesByType: make(map[MessageType]*EventCounts),
+			TimersByType:   make(map[TimerType]*EventCounts),
+			Nodes:          make(map[Address]*NodeMetrics),
+			Latency:        NewHistogram(buckets),
+			LatencyByType:  make(map[MessageType]*Histogram),
+		},
+		sent:      make(map[MessageId][]time.Duration),
+		queued:    make(map[MessageId]int),
+		running:   make(map[Address]time.Duration),
+		handling:  make(map[Address]metricsHandler),
+		unhandled: make(map[string]bool),
+	}
+}
+
+// Metrics returns a copy of the metrics collected so far.
+//
+// The uptime of nodes that are still running includes the time until now.
+func (l *MetricsLogger) Metrics() *Metrics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	m := l.metrics.copy()
+	m.Duration = now
+	for a, n := range m.Nodes {
+		if start, ok := l.running[a]; ok {
+			n.Uptime += now - start
+		}
+	}
+	return m
+}
+
+// copy returns a deep copy of the metrics.
+func (m *Metrics) copy() *Metrics {
+	c := *m
+	c.MessagesByType = make(map[MessageType]*EventCounts, len(m.MessagesByType))
+	for t, counts := range m.MessagesByType {
+		counts := *counts
+		c.MessagesByType[t] = &counts
+	}
+	c.TimersByType = make(map[TimerType]*EventCounts, len(m.TimersByType))
+	for t, counts := range m.TimersByType {
+		counts := *counts
+		c.TimersByType[t] = &counts
+	}
+	c.Nodes = make(map[Address]*NodeMetrics, len(m.Nodes))
+	for a, n := range m.Nodes {
+		n := *n
+		n.StateChanges = make(map[NodeState]int, len(m.Nodes[a].StateChanges))
+		for state, count := range m.Nodes[a].StateChanges {
+			n.StateChanges[state] = count
+		}
+		c.Nodes[a] = &n
+	}
+	c.Latency = m.Latency.copy()
+	c.LatencyByType = make(map[MessageType]*Histogram, len(m.LatencyByType))
+	for t, h := range m.LatencyByType {
+		c.LatencyByType[t] = h.copy()
+	}
+	return &c
+}
+
+// save returns a copy of the state of the logger, which must not be handling an event.
+func (l *MetricsLogger) save() *metricsState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := &metricsState{&l.metrics, l.sent, l.queued, l.running, l.unhandled}
+	return state.copy()
+}
+
+// restore resets the logger to a state returned by save.
+func (l *MetricsLogger) restore(state *metricsState) {
+	state = state.copy()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics = *state.metrics
+	l.sent, l.queued, l.running, l.unhandled = state.sent, state.queued, state.running, state.unhandled
+	l.handling = make(map[Address]metricsHandler)
+}
+
+// copy returns a deep copy of the state.
+func (s *metricsState) copy() *metricsState {
+	c := &metricsState{
+		metrics:   s.metrics.copy(),
+		sent:      make(map[MessageId][]time.Duration, len(s.sent)),
+		queued:    make(map[MessageId]int, len(s.queued)),
+		running:   make(map[Address]time.Duration, len(s.running)),
+		unhandled: make(map[string]bool, len(s.unhandled)),
+	}
+	for id, times := range s.sent {
+		c.sent[id] = append([]time.Duration(nil), times...)
+	}
+	for id, count := range s.queued {
+		c.queued[id] = count
+	}
+	for address, start := range s.running {
+		c.running[address] = start
+	}
+	for id := range s.unhandled {
+		c.unhandled[id] = true
+	}
+	return c
+}
+
+// node returns the metrics of the node with the given address, creating them if needed.
+func (l *MetricsLogger) node(address Address) *NodeMetrics {
+	n, ok := l.metrics.Nodes[address]
+	if !ok {
+		n = &NodeMetrics{StateChanges: make(map[NodeState]int)}
+		l.metrics.Nodes[address] = n
+	}
+	return n
+}
+
+// messageType returns the counts of the given message type, creating them if needed.
+func (l *MetricsLogger) messageType(t MessageType) *EventCounts {
+	c, ok := l.metrics.MessagesByType[t]
+	if !ok {
+		c = &EventCounts{}
+		l.metrics.MessagesByType[t] = c
+	}
+	return c
+}
+
+// timerType returns the counts of the given timer type, creating them if needed.
+func (l *MetricsLogger) timerType(t TimerType) *EventCounts {
+	c, ok := l.metrics.TimersByType[t]
+	if !ok {
+		c = &EventCounts{}
+		l.metrics.TimersByType[t] = c
+	}
+	return c
+}
+
+// arrive removes a message that was handled or dropped from the queue of its receiver, if it had arrived,
+// and returns the time it was sent.
+func (l *MetricsLogger) arrive(to Address, message Message) (time.Duration, bool) {
+	if queued := l.queued[message.Id]; queued > 0 {
+		l.node(to).QueueDepth--
+		if queued == 1 {
+			delete(l.queued, message.Id)
+		} else {
+			l.queued[message.Id] = queued - 1
+		}
+	}
+	times, ok := l.sent[message.Id]
+	if !ok {
+		return 0, false
+	}
+	if len(times) == 1 {
+		delete(l.sent, message.Id)
+	} else {
+		l.sent[message.Id] = times[1:]
+	}
+	return times[0], true
+}
+
+// LogSimulationState is called when the simulation state changes.
+//
+// When the simulation finishes, the uptime of every running node is added up to the end of the simulation.
+func (l *MetricsLogger) LogSimulationState(sim Simulation) {
+	if sim.GetState() != SimulationFinished {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	for address, start := range l.running {
+		l.node(address).Uptime += now - start
+		delete(l.running, address)
+	}
+}
+
+// LogNodeState is called when the state of a node changes.
+func (l *MetricsLogger) LogNodeState(node Node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now, address := l.clock.Now(), node.GetAddress()
+	start, running := l.running[address]
+	n := l.node(address)
+	n.State = node.GetState()
+	n.StateChanges[n.State]++
+	if n.State == Running {
+		if !running {
+			l.running[address] = now
+		}
+		return
+	}
+	if running {
+		n.Uptime += now - start
+		delete(l.running, address)
+	}
+}
+
+// LogSendMessage is called when a message is sent.
+func (l *MetricsLogger) LogSendMessage(from, to Address, message Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics.Messages.Sent++
+	l.messageType(message.Type).Sent++
+	l.node(from).Messages.Sent++
+	l.sent[message.Id] = append(l.sent[message.Id], l.clock.Now())
+}
+
+// LogArriveMessage is called when a message arrives at its destination node, adding it to the queue of the node.
+func (l *MetricsLogger) LogArriveMessage(from, to Address, message Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queued[message.Id]++
+	n := l.node(to)
+	n.QueueDepth++
+	if n.QueueDepth > n.MaxQueueDepth {
+		n.MaxQueueDepth = n.QueueDepth
+	}
+}
+
+// LogArriveTimer is called when a timer arrives at its node.
+func (l *MetricsLogger) LogArriveTimer(to Address, timer Timer, duration time.Duration) {}
+
+// LogHandleMessage is called when a message is handled.
+//
+// The latency of the message is measured now, but it is only counted once the handler has returned.
+func (l *MetricsLogger) LogHandleMessage(from, to Address, message Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	sent, measured := l.arrive(to, message)
+	l.handling[to] = metricsHandler{id: string(message.Id), handled: func() {
+		l.metrics.Messages.Handled++
+		l.messageType(message.Type).Handled++
+		l.node(to).Messages.Handled++
+		if !measured {
+			return
+		}
+		latency := now - sent
+		l.metrics.Latency.Observe(latency)
+		h, ok := l.metrics.LatencyByType[message.Type]
+		if !ok {
+			h = NewHistogram(l.buckets)
+			l.metrics.LatencyByType[message.Type] = h
+		}
+		h.Observe(latency)
+	}}
+}
+
+// LogDropMessage is called when a message is dropped.
+func (l *MetricsLogger) LogDropMessage(from, to Address, message Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics.Messages.Dropped++
+	l.messageType(message.Type).Dropped++
+	l.node(to).Messages.Dropped++
+	if !l.dropUnhandled(string(message.Id)) {
+		l.arrive(to, message)
+	}
+}
+
+// LogHandlerDone is called when a node has finished handling a message, timer or interrupt.
+//
+// The event is counted as handled if a handler was found, and otherwise it is counted when it is dropped.
+func (l *MetricsLogger) LogHandlerDone(to Address, handled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.handling[to]
+	if !ok {
+		return
+	}
+	delete(l.handling, to)
+	if handled {
+		h.handled()
+	} else {
+		l.unhandled[h.id] = true
+	}
+}
+
+// dropUnhandled returns whether the event with the given id being dropped was handled by a node without
+// a handler for it, in which case it has already arrived.
+func (l *MetricsLogger) dropUnhandled(id string) bool {
+	if !l.unhandled[id] {
+		return false
+	}
+	delete(l.unhandled, id)
+	return true
+}
+
+// LogSetTimer is called when a timer is set.
+func (l *MetricsLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics.Timers.Sent++
+	l.timerType(timer.Type).Sent++
+	l.node(to).Timers.Sent++
+}
+
+// LogHandleTimer is called when a timer is handled.
+func (l *MetricsLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handling[to] = metricsHandler{id: string(timer.Id), handled: func() {
+		l.metrics.Timers.Handled++
+		l.timerType(timer.Type).Handled++
+		l.node(to).Timers.Handled++
+	}}
+}
+
+// LogDropTimer is called when a timer is dropped.
+func (l *MetricsLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics.Timers.Dropped++
+	l.timerType(timer.Type).Dropped++
+	l.node(to).Timers.Dropped++
+	l.dropUnhandled(string(timer.Id))
+}
+
+// LogSendInterrupt is called when an interrupt is sent.
+func (l *MetricsLogger) LogSendInterrupt(from, to Address, interrupt Interrupt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics.Interrupts.Sent++
+	l.node(from).Interrupts.Sent++
+}
+
+// LogHandleInterrupt is called when an interrupt is handled.
+func (l *MetricsLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handling[to] = metricsHandler{id: string(interrupt.Id), handled: func() {
+		l.metrics.Interrupts.Handled++
+		l.node(to).Interrupts.Handled++
+	}}
+}
+
+// LogDropInterrupt is called when an interrupt is dropped.
+func (l *MetricsLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.metrics.Interrupts.Dropped++
+	l.node(to).Interrupts.Dropped++
+	l.dropUnhandled(string(interrupt.Id))
+}
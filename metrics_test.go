@@ -0,0 +1,125 @@
+package disse_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// slowNode handles every message after sleeping for the given duration.
+type slowNode struct {
+	*ds.LocalNode
+	sleep time.Duration
+}
+
+func (n *slowNode) Init(ctx context.Context) {}
+
+func (n *slowNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	time.Sleep(n.sleep)
+	return true
+}
+
+func (n *slowNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+func TestMetrics(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	metrics := sim.Metrics()
+	options := testOptions()
+
+	if metrics.Messages != (ds.EventCounts{Sent: 2, Handled: 2}) {
+		t.Errorf("messages counted as %+v, want 2 sent and handled", metrics.Messages)
+	}
+	for _, messageType := range []ds.MessageType{ping, pong} {
+		if counts := metrics.MessagesByType[messageType]; counts == nil || *counts != (ds.EventCounts{Sent: 1, Handled: 1}) {
+			t.Errorf("%v messages counted as %+v, want 1 sent and handled", messageType, counts)
+		}
+		if h := metrics.LatencyByType[messageType]; h == nil || h.Count != 1 || h.Min < options.MinLatency || h.Max > options.MaxLatency {
+			t.Errorf("%v latency recorded as %+v, want one latency between %v and %v", messageType, h, options.MinLatency, options.MaxLatency)
+		}
+	}
+	if metrics.Latency.Count != 2 {
+		t.Errorf("%d latencies recorded, want 2", metrics.Latency.Count)
+	}
+	for _, address := range []ds.Address{"a", "b"} {
+		n := metrics.Nodes[address]
+		if n.Messages != (ds.EventCounts{Sent: 1, Handled: 1}) {
+			t.Errorf("%v messages counted as %+v, want 1 sent and handled", address, n.Messages)
+		}
+		if n.QueueDepth != 0 || n.MaxQueueDepth != 1 {
+			t.Errorf("%v queue depth %d and maximum %d, want 0 and 1", address, n.QueueDepth, n.MaxQueueDepth)
+		}
+		if n.State != ds.Running || n.Uptime != options.Duration {
+			t.Errorf("%v is %v with uptime %v, want Running for %v", address, n.State, n.Uptime, options.Duration)
+		}
+	}
+	if metrics.Duration != options.Duration {
+		t.Errorf("duration %v, want %v", metrics.Duration, options.Duration)
+	}
+}
+
+func TestMetricsQueueDepthCountsArrivedMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(sim *ds.LocalSimulation) error
+		minDepth int
+		maxDepth int
+	}{
+		{
+			// Messages are handled as soon as they arrive, so messages in flight are not queued.
+			name:     "step by step",
+			run:      func(sim *ds.LocalSimulation) error { return sim.RunScheduled(ds.NewRandomScheduler(1)) },
+			minDepth: 1,
+			maxDepth: 1,
+		},
+		{
+			// The other pings arrive while b is handling the first.
+			name:     "real time",
+			run:      func(sim *ds.LocalSimulation) error { sim.Run(); return nil },
+			minDepth: 2,
+			maxDepth: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sim := ds.NewLocalSimulation(testOptions())
+			sim.AddNode(newPingNode(sim, "a", "b", "b", "b"))
+			sim.AddNode(&slowNode{LocalNode: ds.NewLocalNode(sim, "b"), sleep: 20 * time.Millisecond})
+			if err := test.run(sim); err != nil {
+				t.Fatal(err)
+			}
+			b := sim.Metrics().Nodes["b"]
+			if b.Messages.Handled != 3 {
+				t.Fatalf("b handled %d messages, want 3", b.Messages.Handled)
+			}
+			if b.QueueDepth != 0 || b.MaxQueueDepth < test.minDepth || b.MaxQueueDepth > test.maxDepth {
+				t.Errorf("b queue depth %d and maximum %d, want 0 and between %d and %d", b.QueueDepth, b.MaxQueueDepth, test.minDepth, test.maxDepth)
+			}
+		})
+	}
+}
+
+func TestModelCheckRestoresMetrics(t *testing.T) {
+	sim := newRegisterSimulation()
+	result, err := sim.ModelCheck(&ds.ModelCheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Transitions == 0 {
+		t.Fatal("no transitions explored")
+	}
+	// The search backtracks to the initial state, in which the three messages have been sent.
+	metrics := sim.Metrics()
+	if metrics.Messages != (ds.EventCounts{Sent: 3}) {
+		t.Errorf("messages counted as %+v, want 3 sent", metrics.Messages)
+	}
+	if metrics.Latency.Count != 0 {
+		t.Errorf("%d latencies recorded, want none", metrics.Latency.Count)
+	}
+}
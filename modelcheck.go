@@ -0,0 +1,439 @@
+package disse
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"time"
+)
+
+// ModelCheckOptions is used to set the options for model checking.
+//
+// A zero MaxDepth or MaxStates is replaced by its default value.
+type ModelCheckOptions struct {
+	// MaxDepth is the maximum number of events explored along a single path.
+	MaxDepth int
+	// MaxStates is the maximum number of distinct states explored.
+	MaxStates int
+	// MaxCrashes is the maximum number of nodes crashed by the model checker along a single path.
+	MaxCrashes int
+	// CounterexamplePath is the path the shortest counterexample is written to.
+	CounterexamplePath string
+}
+
+const (
+	// DefaultMaxDepth is the default maximum number of events explored along a single path.
+	DefaultMaxDepth = 50
+	// DefaultMaxStates is the default maximum number of distinct states explored.
+	DefaultMaxStates = 100000
+	// DefaultMaxCrashes is the default maximum number of nodes crashed along a single path.
+	DefaultMaxCrashes = 0
+	// DefaultCounterexamplePath is the default path to the counterexample log.
+	DefaultCounterexamplePath = "counterexample.log"
+)
+
+// ModelCheckResult is the result of model checking a simulation.
+type ModelCheckResult struct {
+	// States is the number of distinct states explored.
+	States int
+	// Transitions is the number of events executed during the search.
+	Transitions int
+	// Pruned is the number of transitions skipped by state hashing and partial-order reduction.
+	Pruned int
+	// Complete is true if every state within MaxDepth was explored without reaching MaxStates.
+	Complete bool
+	// Violation is the shortest invariant violation found, or nil if no invariant was violated.
+	Violation *InvariantViolation
+}
+
+// ModelCheck systematically explores every order in which the pending messages, timers and
+// interrupts of the simulation can be processed, as well as every point at which nodes can crash,
+// checking the invariants of the simulation in every state.
+//
+// Only orders allowed by the latency bounds of the simulation are explored: an event may be processed
+// before another if it can arrive before the latest time the other must be processed. Every event is
+// processed at the earliest time it can arrive, so messages are always delivered after the minimum
+// latency, and timers fire exactly when they are due.
+//
+// Repeated states are pruned by hashing node snapshots and pending events, and events for different
+// nodes are treated as independent to prune equivalent orders using sleep sets.
+// Every node and sub node must implement Snapshotter.
+//
+// The search continues after a violation is found, looking for shorter counterexamples,
+// so the violation in the result is the shortest found.
+//
+// Loggers observe every explored event, including events that are later backtracked.
+// The metrics of the simulation are restored when the search backtracks, so they only count
+// the events that led to the current state.
+func (s *LocalSimulation) ModelCheck(options *ModelCheckOptions) (*ModelCheckResult, error) {
+	if options == nil {
+		options = &ModelCheckOptions{
+			MaxDepth:           DefaultMaxDepth,
+			MaxStates:          DefaultMaxStates,
+			MaxCrashes:         DefaultMaxCrashes,
+			CounterexamplePath: DefaultCounterexamplePath,
+		}
+	}
+	if options.MaxDepth == 0 || options.MaxStates == 0 {
+		defaulted := *options
+		if defaulted.MaxDepth == 0 {
+			defaulted.MaxDepth = DefaultMaxDepth
+		}
+		if defaulted.MaxStates == 0 {
+			defaulted.MaxStates = DefaultMaxStates
+		}
+		options = &defaulted
+	}
+	nodes := make(map[Address]Node)
+	for _, node := range s.nodes {
+		flattenNodes(node, nodes)
+	}
+	for address, node := range nodes {
+		if _, ok := node.(Snapshotter); !ok {
+			return nil, fmt.Errorf("node with address %v does not implement Snapshotter", address)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.cancel = func() {}
+	s.controlled = true
+	s.recorder()
+	s.startSim(ctx)
+
+	mc := &modelChecker{
+		sim:       s,
+		ctx:       ctx,
+		options:   options,
+		nodes:     nodes,
+		addresses: sortedAddresses(nodes),
+		visited:   make(map[uint64]mcVisit),
+		maxDepth:  options.MaxDepth,
+		result:    &ModelCheckResult{Complete: true},
+	}
+	s.check(0)
+	if s.violation != nil {
+		mc.result.Violation = s.violation.(*InvariantViolation)
+	} else {
+		mc.explore(0, nil)
+	}
+
+	s.state = SimulationFinished
+	s.LogSimulationState()
+	if mc.result.Violation != nil && options.CounterexamplePath != "" {
+		if err := mc.result.Violation.WriteFile(options.CounterexamplePath); err != nil {
+			log.Println("failed to write counterexample:", err)
+		}
+	}
+	return mc.result, nil
+}
+
+// flattenNodes adds a node and all its sub nodes to the given map.
+func flattenNodes(node Node, nodes map[Address]Node) {
+	nodes[node.GetAddress()] = node
+	for _, subNode := range node.GetSubNodes() {
+		flattenNodes(subNode, nodes)
+	}
+}
+
+// modelChecker holds the state of a model checking search.
+type modelChecker struct {
+	sim       *LocalSimulation
+	ctx       context.Context
+	options   *ModelCheckOptions
+	nodes     map[Address]Node
+	addresses []Address
+	visited   map[uint64]mcVisit
+	crashes   int
+	maxDepth  int
+	result    *ModelCheckResult
+}
+
+// mcChoice is a transition of the model checker.
+//
+// It either processes the pending event with the given sequence number or crashes the given node,
+// at the given time of the simulation.
+type mcChoice struct {
+	seq   uint64
+	crash bool
+	to    Address
+	at    time.Duration
+}
+
+// mcVisit is a visited state of the model checker.
+//
+// It holds the smallest depth the state was explored at, and the sleep set it was explored with,
+// as returned by sleepKeys. If the state was explored more than once at that depth, the sleep set
+// only contains the choices that were skipped every time.
+type mcVisit struct {
+	depth int
+	sleep []string
+}
+
+// mcSnapshot is a saved state of the simulation that the model checker can backtrack to.
+type mcSnapshot struct {
+	states    map[Address]NodeState
+	snapshots map[Address]any
+	calls     map[Address]map[MessageId]rpcCall
+	pending   []PendingEvent
+	clock     time.Duration
+	lamport   map[Address]uint64
+	vector    map[Address]VectorClock
+	traceLen  int
+	metrics   *metricsState
+	crashes   int
+}
+
+// explore performs a depth-first search from the current state of the simulation.
+//
+// The sleep set contains choices that do not need to be explored from this state,
+// because an equivalent order has already been explored.
+func (mc *modelChecker) explore(depth int, sleep []mcChoice) {
+	if mc.result.States >= mc.options.MaxStates {
+		mc.result.Complete = false
+		return
+	}
+	hash := mc.hash()
+	keys := mc.sleepKeys(sleep)
+	visit, ok := mc.visited[hash]
+	switch {
+	case !ok:
+		mc.result.States++
+		mc.visited[hash] = mcVisit{depth, keys}
+	case visit.depth <= depth && containsKeys(keys, visit.sleep):
+		mc.result.Pruned++
+		return
+	case visit.depth <= depth:
+		mc.visited[hash] = mcVisit{visit.depth, intersectKeys(visit.sleep, keys)}
+	default:
+		mc.visited[hash] = mcVisit{depth, keys}
+	}
+
+	choices := mc.choices()
+	if depth >= mc.maxDepth {
+		if depth >= mc.options.MaxDepth && len(choices) > 0 {
+			mc.result.Complete = false
+		}
+		return
+	}
+
+	snapshot := mc.save()
+	done := make([]mcChoice, 0, len(choices))
+	for _, choice := range choices {
+		if depth >= mc.maxDepth {
+			break
+		}
+		if containsChoice(sleep, choice) {
+			mc.result.Pruned++
+			continue
+		}
+		mc.apply(choice)
+		mc.result.Transitions++
+		if mc.sim.violation != nil {
+			mc.result.Violation = mc.sim.violation.(*InvariantViolation)
+			mc.maxDepth = depth
+		} else {
+			mc.explore(depth+1, independentChoices(choice, sleep, done))
+		}
+		mc.restore(snapshot)
+		done = append(done, choice)
+	}
+}
+
+// choices returns the transitions that are enabled in the current state.
+func (mc *modelChecker) choices() []mcChoice {
+	s := mc.sim
+	choices := make([]mcChoice, 0)
+	for _, i := range s.enabled() {
+		event := s.pending[i]
+		at := event.Earliest
+		if at < s.clock {
+			at = s.clock
+		}
+		choices = append(choices, mcChoice{seq: event.Seq, to: event.To, at: at})
+	}
+	if mc.crashes < mc.options.MaxCrashes {
+		for _, address := range sortedAddresses(s.nodes) {
+			if s.nodes[address].GetState() == Running {
+				choices = append(choices, mcChoice{crash: true, to: address, at: s.clock})
+			}
+		}
+	}
+	return choices
+}
+
+// apply executes a transition.
+func (mc *modelChecker) apply(choice mcChoice) {
+	s := mc.sim
+	if choice.crash {
+		mc.crashes++
+		stopInterrupt := NewInterrupt(StopInterrupt, nil)
+		s.processInterrupt(mc.ctx, InterruptTriplet{stopInterrupt, choice.to, choice.to})
+		return
+	}
+	for i, event := range s.pending {
+		if event.Seq == choice.seq {
+			s.execute(mc.ctx, i, event.Earliest)
+			return
+		}
+	}
+}
+
+// save saves the current state of the simulation.
+func (mc *modelChecker) save() *mcSnapshot {
+	s := mc.sim
+	snapshot := &mcSnapshot{
+		states:    make(map[Address]NodeState),
+		snapshots: make(map[Address]any),
+		calls:     make(map[Address]map[MessageId]rpcCall),
+		pending:   append([]PendingEvent(nil), s.pending...),
+		clock:     s.clock,
+		traceLen:  s.trace.Len(),
+		metrics:   s.metrics.save(),
+		crashes:   mc.crashes,
+	}
+	snapshot.lamport, snapshot.vector = s.saveClocks()
+	for address, node := range mc.nodes {
+		snapshot.states[address] = node.GetState()
+		snapshot.snapshots[address] = node.(Snapshotter).Snapshot()
+		if r, ok := node.(interface{ saveCalls() map[MessageId]rpcCall }); ok {
+			snapshot.calls[address] = r.saveCalls()
+		}
+	}
+	return snapshot
+}
+
+// restore restores the simulation to a previously saved state.
+func (mc *modelChecker) restore(snapshot *mcSnapshot) {
+	s := mc.sim
+	for address, node := range mc.nodes {
+		if setter, ok := node.(interface{ setState(NodeState) }); ok {
+			setter.setState(snapshot.states[address])
+		}
+		if r, ok := node.(interface{ restoreCalls(map[MessageId]rpcCall) }); ok {
+			r.restoreCalls(snapshot.calls[address])
+		}
+		node.(Snapshotter).Restore(snapshot.snapshots[address])
+	}
+	s.pending = append([]PendingEvent(nil), snapshot.pending...)
+	s.clock = snapshot.clock
+	s.restoreClocks(snapshot.lamport, snapshot.vector)
+	s.trace.Truncate(snapshot.traceLen)
+	s.metrics.restore(snapshot.metrics)
+	s.violation = nil
+	mc.crashes = snapshot.crashes
+}
+
+// hash returns a hash of the current state of the simulation.
+//
+// Message, timer and interrupt ids are ignored, and pending events are hashed relative
+// to the current time, so that equivalent states reached along different paths are identified.
+func (mc *modelChecker) hash() uint64 {
+	s := mc.sim
+	h := fnv.New64a()
+	for _, address := range mc.addresses {
+		node := mc.nodes[address]
+		fmt.Fprintf(h, "%v|%v|%#v\n", address, node.GetState(), node.(Snapshotter).Snapshot())
+	}
+	events := make([]string, len(s.pending))
+	for i, event := range s.pending {
+		events[i] = mc.describe(event)
+	}
+	sort.Strings(events)
+	fmt.Fprintf(h, "%v|%v", mc.crashes, events)
+	return h.Sum64()
+}
+
+// describe returns a description of a pending event that ignores its ids and is relative to the current time.
+//
+// Wake ups are described by the node they wake up and when they are due, as the function they
+// call is not comparable.
+func (mc *modelChecker) describe(event PendingEvent) string {
+	var description string
+	switch event.Kind {
+	case HandleMessageEvent:
+		mt := event.Message
+		description = fmt.Sprintf("%v|%v|%#v|%v", mt.From, mt.Message.Type, mt.Message.Data, event.delivered)
+	case HandleTimerEvent:
+		tt := event.Timer
+		description = fmt.Sprintf("%v|%#v|%v", tt.Timer.Type, tt.Timer.Data, tt.Duration)
+	case HandleInterruptEvent:
+		it := event.Interrupt
+		description = fmt.Sprintf("%v|%v|%#v", it.From, it.Interrupt.Type, it.Interrupt.Data)
+	default:
+		description = "wake"
+	}
+	return fmt.Sprintf("%v|%v|%v|%v|%v", event.Kind, event.To, description, event.Earliest-mc.sim.clock, event.Latest-mc.sim.clock)
+}
+
+// sleepKeys returns the sorted descriptions of the choices in a sleep set, so that sleep sets of
+// equivalent states reached along different paths can be compared.
+func (mc *modelChecker) sleepKeys(sleep []mcChoice) []string {
+	keys := make([]string, 0, len(sleep))
+	for _, choice := range sleep {
+		if choice.crash {
+			keys = append(keys, fmt.Sprintf("crash|%v", choice.to))
+			continue
+		}
+		for _, event := range mc.sim.pending {
+			if event.Seq == choice.seq {
+				keys = append(keys, mc.describe(event))
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsKeys reports whether every key in subset is in the sorted keys.
+func containsKeys(keys []string, subset []string) bool {
+	for _, key := range subset {
+		i := sort.SearchStrings(keys, key)
+		if i == len(keys) || keys[i] != key {
+			return false
+		}
+	}
+	return true
+}
+
+// intersectKeys returns the keys that are in both sorted sets of keys.
+func intersectKeys(a []string, b []string) []string {
+	intersection := make([]string, 0)
+	for _, key := range a {
+		if containsKeys(b, []string{key}) {
+			intersection = append(intersection, key)
+		}
+	}
+	return intersection
+}
+
+// containsChoice reports whether the choice is in the given set of choices.
+func containsChoice(choices []mcChoice, choice mcChoice) bool {
+	for _, c := range choices {
+		if c == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// independentChoices returns the choices in the sleep set and the already explored
+// choices that are independent of the given choice, which form the sleep set after it.
+//
+// Choices are independent if they affect different nodes and happen at the same time, as the
+// virtual clock of the simulation is shared by all nodes, and processing an event that is due
+// later moves it forward for every other pending event.
+func independentChoices(choice mcChoice, sleep []mcChoice, done []mcChoice) []mcChoice {
+	independent := make([]mcChoice, 0, len(sleep)+len(done))
+	for _, choices := range [][]mcChoice{sleep, done} {
+		for _, c := range choices {
+			if c.to != choice.to && c.at == choice.at {
+				independent = append(independent, c)
+			}
+		}
+	}
+	return independent
+}
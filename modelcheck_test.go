@@ -0,0 +1,152 @@
+package disse_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const (
+	write ds.MessageType = "Write"
+	noise ds.MessageType = "Noise"
+)
+
+// registerNode sends its messages when it is initialized, and stores the value of the last write it handles.
+type registerNode struct {
+	*ds.LocalNode
+	sends []ds.MessageTriplet
+	registerState
+}
+
+// registerState is the state of a registerNode.
+type registerState struct {
+	Value   int
+	Writes  int
+	Handled int
+}
+
+func newRegisterNode(sim *ds.LocalSimulation, address ds.Address, sends ...ds.MessageTriplet) *registerNode {
+	return &registerNode{LocalNode: ds.NewLocalNode(sim, address), sends: sends}
+}
+
+func (n *registerNode) Init(ctx context.Context) {
+	for _, mt := range n.sends {
+		n.SendMessage(ctx, mt.Message, mt.To)
+	}
+}
+
+func (n *registerNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	n.Handled++
+	if message.Type == write {
+		n.Value = message.Data.(int)
+		n.Writes++
+	}
+	return true
+}
+
+func (n *registerNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+func (n *registerNode) Snapshot() any {
+	return n.registerState
+}
+
+func (n *registerNode) Restore(snapshot any) {
+	n.registerState = snapshot.(registerState)
+}
+
+// newRegisterSimulation creates a simulation in which a and b write 1 and 2 to c, and a also sends a message to b.
+func newRegisterSimulation() *ds.LocalSimulation {
+	sim := ds.NewLocalSimulation(testOptions())
+	sim.AddNode(newRegisterNode(sim, "a",
+		ds.MessageTriplet{Message: ds.NewMessage(write, 1), To: "c"},
+		ds.MessageTriplet{Message: ds.NewMessage(noise, nil), To: "b"},
+	))
+	sim.AddNode(newRegisterNode(sim, "b", ds.MessageTriplet{Message: ds.NewMessage(write, 2), To: "c"}))
+	sim.AddNode(newRegisterNode(sim, "c"))
+	return sim
+}
+
+// lastWriteWins is an invariant that is violated if c handles the write of b before the write of a.
+func lastWriteWins(nodes map[ds.Address]ds.Node) error {
+	c := nodes["c"].(*registerNode)
+	if c.Writes == 2 && c.Value == 1 {
+		handled := 0
+		for _, node := range nodes {
+			handled += node.(*registerNode).Handled
+		}
+		return fmt.Errorf("c holds the write of a after %d events", handled)
+	}
+	return nil
+}
+
+func TestModelCheckFindsShortestCounterexample(t *testing.T) {
+	sim := newRegisterSimulation()
+	sim.AddInvariant("last write wins", lastWriteWins)
+	result, err := sim.ModelCheck(&ds.ModelCheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Violation == nil {
+		t.Fatalf("no violation found in %d states", result.States)
+	}
+	if got, want := result.Violation.Err.Error(), "c holds the write of a after 2 events"; got != want {
+		t.Errorf("violation %q, want %q", got, want)
+	}
+	if event := result.Violation.Event; event.Kind != ds.HandleMessageEvent || event.Message.Data != 1 {
+		t.Errorf("violation after %v, want the handling of the write of a", event)
+	}
+}
+
+func TestModelCheckComplete(t *testing.T) {
+	sim := newRegisterSimulation()
+	sim.AddInvariant("value written", func(nodes map[ds.Address]ds.Node) error {
+		if c := nodes["c"].(*registerNode); c.Writes > 0 && c.Value != 1 && c.Value != 2 {
+			return fmt.Errorf("c holds %d", c.Value)
+		}
+		return nil
+	})
+	result, err := sim.ModelCheck(&ds.ModelCheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Violation != nil {
+		t.Errorf("unexpected violation %v", result.Violation)
+	}
+	if !result.Complete {
+		t.Errorf("search not complete after %d states", result.States)
+	}
+}
+
+func TestModelCheckIsDeterministic(t *testing.T) {
+	var first *ds.ModelCheckResult
+	for i := 0; i < 5; i++ {
+		sim := newRegisterSimulation()
+		options := &ds.ModelCheckOptions{MaxCrashes: 1}
+		result, err := sim.ModelCheck(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Pruned == 0 {
+			t.Errorf("no transitions pruned")
+		}
+		if first == nil {
+			first = result
+			continue
+		}
+		if result.States != first.States || result.Transitions != first.Transitions || result.Pruned != first.Pruned {
+			t.Errorf("run %d explored %+v, want %+v", i, *result, *first)
+		}
+	}
+}
+
+func TestModelCheckRequiresSnapshotter(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	if _, err := sim.ModelCheck(nil); err == nil {
+		t.Errorf("ModelCheck() of nodes without snapshots returned no error")
+	}
+}
@@ -3,7 +3,6 @@ package disse
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"time"
 )
 
@@ -38,6 +37,18 @@ type Node interface {
 	HandleInterrupt(context.Context, Interrupt, Address) (handled bool)
 }
 
+// Snapshotter is implemented by nodes whose state can be saved and restored.
+//
+// Snapshot must return a deep copy of the state of the node that is not modified by later events,
+// and Restore must reset the node to a state previously returned by Snapshot.
+// Snapshots are also used to identify repeated states, so they should be plain values without pointers.
+//
+// The state of the embedded LocalNode is saved by the simulation and does not need to be included.
+type Snapshotter interface {
+	Snapshot() any
+	Restore(any)
+}
+
 // LocalNode implements most of the functions needed to satisfy the INode interface.
 //
 // The only functions that need to be implemented by a LocalNode are Init, HandleMessage and HandleTimer.
@@ -48,6 +59,7 @@ type LocalNode struct {
 	sim      *LocalSimulation
 	subNodes map[Address]Node
 	state    NodeState
+	calls    map[MessageId]*rpcCall
 }
 
 // NewLocalNode creates a new LocalNode with the given address.
@@ -109,13 +121,10 @@ func (n *LocalNode) SendMessage(ctx context.Context, message Message, to Address
 			return err
 		}
 		from := n.address.GetRoot()
+		message = n.sim.stampMessage(from, message)
+		message.ParentId = causeOf(ctx)
 		n.sim.LogSendMessage(from, to, message)
-		go func() {
-			if to != from {
-				time.Sleep(n.randomLatency())
-			}
-			n.sim.messageQueue[to] <- MessageTriplet{message, from, to}
-		}()
+		n.sim.sendMessage(MessageTriplet{message, from, to})
 		return nil
 	}
 }
@@ -156,12 +165,9 @@ func (n *LocalNode) SetTimer(ctx context.Context, timer Timer, duration time.Dur
 		if err := n.validateNode(to); err != nil {
 			return err
 		}
-		from := to
+		timer.ParentId = causeOf(ctx)
 		n.sim.LogSetTimer(to, timer, duration)
-		go func() {
-			time.Sleep(duration)
-			n.sim.timerQueue[to] <- TimerTriplet{timer, from, duration}
-		}()
+		n.sim.setTimer(TimerTriplet{timer, to, duration})
 		return nil
 	}
 }
@@ -180,10 +186,9 @@ func (n *LocalNode) SendInterrupt(ctx context.Context, interrupt Interrupt, to A
 			return err
 		}
 		from := n.address.GetRoot()
+		interrupt.ParentId = causeOf(ctx)
 		n.sim.LogSendInterrupt(from, to, interrupt)
-		go func() {
-			n.sim.interruptQueue[to] <- InterruptTriplet{interrupt, from, to}
-		}()
+		n.sim.sendInterrupt(InterruptTriplet{interrupt, from, to})
 		return nil
 	}
 }
@@ -197,20 +202,23 @@ func (n *LocalNode) HandleInterrupt(ctx context.Context, interrupt Interrupt, fr
 	case SleepInterrupt:
 		data := interrupt.Data.(SleepInterruptData)
 		n.state = Sleeping
-		go func() {
-			<-time.After(data.Duration)
+		n.sim.afterFunc(n.address.GetRoot(), data.Duration, func() {
 			n.state = Running
-		}()
+			if node, ok := n.sim.nodes[n.address]; ok {
+				n.sim.LogNodeState(node)
+			}
+		})
 		return true
 	default:
 		return false
 	}
 }
 
-// randomLatency returns a random duration between the minimum and maximum latency.
-func (n *LocalNode) randomLatency() time.Duration {
-	s := n.sim
-	return s.options.MinLatency + time.Duration(rand.Int63n(int64(s.options.MaxLatency-s.options.MinLatency)))
+// setState sets the state of the node.
+//
+// It is used by the simulation to restore nodes to a previous state.
+func (n *LocalNode) setState(state NodeState) {
+	n.state = state
 }
 
 // validateNode checks if the node exists in the simulation.
@@ -0,0 +1,349 @@
+package disse
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// otlpSpanKind is the kind of a span in the OTLP JSON encoding.
+type otlpSpanKind int
+
+const (
+	// otlpInternal is the kind of spans of handlers.
+	otlpInternal otlpSpanKind = 1
+	// otlpProducer is the kind of spans of messages, timers and interrupts.
+	otlpProducer otlpSpanKind = 4
+)
+
+// otlpStatusError is the status code of a span that ended with an error.
+const otlpStatusError = 2
+
+// otlpAttribute is a key value pair attached to a span or resource.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// otlpStatus is the status of a span.
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpSpan is a span in the OTLP JSON encoding.
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              otlpSpanKind    `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+
+	service Address
+	start   time.Duration
+	end     time.Duration
+	ended   bool
+}
+
+// attribute adds a string attribute to the span, unless the value is empty.
+func (s *otlpSpan) attribute(key string, value any) {
+	if value == nil || fmt.Sprint(value) == "" {
+		return
+	}
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = fmt.Sprint(value)
+	s.Attributes = append(s.Attributes, a)
+}
+
+// OtlpLogger is a Logger implementation that records the causality of a simulation as OpenTelemetry spans,
+// and writes them as OTLP JSON once the simulation finishes.
+//
+// Every message, timer and interrupt is a span from when it is sent or set until it is handled or dropped,
+// and every execution of a handler is a span that is a child of the span of the event it handles.
+// Messages, timers and interrupts are children of the span of the handler of their parent id, so each trace
+// is a causal chain of events that started with an event sent outside of any handler, such as in Init.
+// Spans are grouped into one service per top level node, and their times are the wall time the logger was
+// created plus the time of the simulation.
+//
+// It is safe to use from multiple goroutines.
+type OtlpLogger struct {
+	clock    Clock
+	logPath  string
+	epoch    time.Time
+	mu       sync.Mutex
+	spans    []*otlpSpan
+	inFlight map[string][]*otlpSpan
+	last     map[string]*otlpSpan
+	handlers map[string]*otlpSpan
+	handling map[Address][]otlpHandler
+	traces   uint64
+}
+
+// otlpHandler is the span of a handler that is running, and the id of the event it handles.
+type otlpHandler struct {
+	id   string
+	span *otlpSpan
+}
+
+// NewOtlpLogger creates a new OtlpLogger that writes to the given file once the simulation finishes,
+// and timestamps spans using the given clock.
+func NewOtlpLogger(logPath string, clock Clock) (*OtlpLogger, error) {
+	logfile, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	logfile.Close()
+	return &OtlpLogger{
+		clock:    clock,
+		logPath:  logPath,
+		epoch:    time.Now(),
+		spans:    make([]*otlpSpan, 0),
+		inFlight: make(map[string][]*otlpSpan),
+		last:     make(map[string]*otlpSpan),
+		handlers: make(map[string]*otlpSpan),
+		handling: make(map[Address][]otlpHandler),
+	}, nil
+}
+
+// WriteFile writes the spans recorded so far as OTLP JSON to the file at the given path.
+//
+// Spans that have not ended yet are ended at the current time of the simulation.
+func (l *OtlpLogger) WriteFile(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, err := json.MarshalIndent(l.export(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// export returns the OTLP JSON document of the spans recorded so far.
+func (l *OtlpLogger) export() any {
+	type scopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []*otlpSpan `json:"spans"`
+	}
+	type resourceSpans struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	now := l.clock.Now()
+	services := make(map[Address][]*otlpSpan)
+	for _, span := range l.spans {
+		end := span.end
+		if !span.ended {
+			end = now
+		}
+		span.StartTimeUnixNano = strconv.FormatInt(l.epoch.Add(span.start).UnixNano(), 10)
+		span.EndTimeUnixNano = strconv.FormatInt(l.epoch.Add(end).UnixNano(), 10)
+		services[span.service] = append(services[span.service], span)
+	}
+	addresses := make([]Address, 0, len(services))
+	for address := range services {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	resources := make([]resourceSpans, len(addresses))
+	for i, address := range addresses {
+		service := otlpAttribute{Key: "service.name"}
+		service.Value.StringValue = string(address)
+		resources[i].Resource.Attributes = []otlpAttribute{service}
+		scope := scopeSpans{Spans: services[address]}
+		scope.Scope.Name = "disse"
+		resources[i].ScopeSpans = []scopeSpans{scope}
+	}
+	return map[string]any{"resourceSpans": resources}
+}
+
+// start starts a new span at the given node, as a child of the given parent or of a new trace if it is nil.
+func (l *OtlpLogger) start(name string, kind otlpSpanKind, node Address, parent *otlpSpan) *otlpSpan {
+	span := &otlpSpan{
+		SpanId:  fmt.Sprintf("%016x", len(l.spans)+1),
+		Name:    name,
+		Kind:    kind,
+		service: node.GetRoot(),
+		start:   l.clock.Now(),
+	}
+	if parent != nil {
+		span.TraceId = parent.TraceId
+		span.ParentSpanId = parent.SpanId
+	} else {
+		l.traces++
+		span.TraceId = fmt.Sprintf("%032x", l.traces)
+	}
+	span.attribute("disse.node", node)
+	l.spans = append(l.spans, span)
+	return span
+}
+
+// finish ends a span at the current time.
+func (l *OtlpLogger) finish(span *otlpSpan) {
+	span.end = l.clock.Now()
+	span.ended = true
+}
+
+// send starts the span of a message, timer or interrupt with the given key, as a child of the span of the
+// handler of its parent, or of a new trace if it has no parent.
+//
+// The attributes of the span are given as alternating keys and values.
+func (l *OtlpLogger) send(key, parentId, name string, from Address, attributes ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	span := l.start(name, otlpProducer, from, l.handlers[parentId])
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.attribute(attributes[i].(string), attributes[i+1])
+	}
+	l.inFlight[key] = append(l.inFlight[key], span)
+	l.last[key] = span
+}
+
+// arrive ends the span of the message, timer or interrupt with the given key, and returns it.
+//
+// Events that arrive more than once, such as duplicated messages, return their last span again.
+func (l *OtlpLogger) arrive(key string) *otlpSpan {
+	spans := l.inFlight[key]
+	if len(spans) == 0 {
+		return l.last[key]
+	}
+	if len(spans) == 1 {
+		delete(l.inFlight, key)
+	} else {
+		l.inFlight[key] = spans[1:]
+	}
+	l.finish(spans[0])
+	return spans[0]
+}
+
+// handle ends the span of the event with the given key and id, and starts the span of its handler,
+// which is the parent of the events whose parent id is the id of the event until the handler returns.
+//
+// Handlers of the same node that run while another handler is running are nested inside it.
+func (l *OtlpLogger) handle(key, id, name string, to Address) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	span := l.start(name, otlpInternal, to, l.arrive(key))
+	l.handlers[id] = span
+	l.handling[to] = append(l.handling[to], otlpHandler{id, span})
+}
+
+// drop ends the span of the event with the given key with an error.
+func (l *OtlpLogger) drop(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if span := l.arrive(key); span != nil {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: "dropped"}
+	}
+}
+
+// LogSimulationState is called when the simulation state changes.
+//
+// The spans are written to the file of the logger when the simulation finishes.
+func (l *OtlpLogger) LogSimulationState(sim Simulation) {
+	if sim.GetState() != SimulationFinished {
+		return
+	}
+	if err := l.WriteFile(l.logPath); err != nil {
+		log.Println("failed to write OTLP log:", err)
+	}
+}
+
+// LogNodeState is called when the state of a node changes.
+func (l *OtlpLogger) LogNodeState(node Node) {}
+
+// LogSendMessage is called when a message is sent.
+func (l *OtlpLogger) LogSendMessage(from, to Address, message Message) {
+	l.send("message/"+string(message.Id), message.ParentId, string(message.Type), from,
+		"disse.from", from,
+		"disse.to", to,
+		"disse.message.id", message.Id,
+		"disse.message.data", message.Data,
+		"disse.message.reply_to", message.ReplyTo,
+	)
+}
+
+// LogHandleMessage is called when a message is handled.
+func (l *OtlpLogger) LogHandleMessage(from, to Address, message Message) {
+	l.handle("message/"+string(message.Id), string(message.Id), "handle "+string(message.Type), to)
+}
+
+// LogDropMessage is called when a message is dropped.
+func (l *OtlpLogger) LogDropMessage(from, to Address, message Message) {
+	l.drop("message/" + string(message.Id))
+}
+
+// LogSetTimer is called when a timer is set.
+func (l *OtlpLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {
+	l.send("timer/"+string(timer.Id), timer.ParentId, string(timer.Type), to,
+		"disse.timer.id", timer.Id,
+		"disse.timer.data", timer.Data,
+		"disse.timer.duration", duration,
+	)
+}
+
+// LogHandleTimer is called when a timer is handled.
+func (l *OtlpLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	l.handle("timer/"+string(timer.Id), string(timer.Id), "handle "+string(timer.Type), to)
+}
+
+// LogDropTimer is called when a timer is dropped.
+func (l *OtlpLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {
+	l.drop("timer/" + string(timer.Id))
+}
+
+// LogSendInterrupt is called when an interrupt is sent.
+func (l *OtlpLogger) LogSendInterrupt(from, to Address, interrupt Interrupt) {
+	l.send("interrupt/"+string(interrupt.Id), interrupt.ParentId, string(interrupt.Type), from,
+		"disse.from", from,
+		"disse.to", to,
+		"disse.interrupt.id", interrupt.Id,
+		"disse.interrupt.data", interrupt.Data,
+	)
+}
+
+// LogHandleInterrupt is called when an interrupt is handled.
+func (l *OtlpLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	l.handle("interrupt/"+string(interrupt.Id), string(interrupt.Id), "handle "+string(interrupt.Type), to)
+}
+
+// LogDropInterrupt is called when an interrupt is dropped.
+func (l *OtlpLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {
+	l.drop("interrupt/" + string(interrupt.Id))
+}
+
+// LogHandlerDone is called when a node has finished handling a message, timer or interrupt, and ends the span
+// of the innermost handler of the node that is running.
+func (l *OtlpLogger) LogHandlerDone(to Address, handled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	running := l.handling[to]
+	if len(running) == 0 {
+		return
+	}
+	h := running[len(running)-1]
+	if len(running) == 1 {
+		delete(l.handling, to)
+	} else {
+		l.handling[to] = running[:len(running)-1]
+	}
+	h.span.attribute("disse.handled", handled)
+	l.finish(h.span)
+	if l.handlers[h.id] == h.span {
+		delete(l.handlers, h.id)
+	}
+}
@@ -0,0 +1,167 @@
+package disse_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// otlpSpan is the part of a span written by an OtlpLogger that the tests check.
+type otlpSpan struct {
+	TraceId           string `json:"traceId"`
+	SpanId            string `json:"spanId"`
+	ParentSpanId      string `json:"parentSpanId"`
+	Name              string `json:"name"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string `json:"endTimeUnixNano"`
+}
+
+// readOtlp reads the spans of an OTLP JSON file by name, and the names of its services.
+func readOtlp(t *testing.T, path string) (map[string]otlpSpan, []string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Value struct {
+						StringValue string `json:"stringValue"`
+					} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+	spans := make(map[string]otlpSpan)
+	services := make([]string, 0)
+	for _, resource := range document.ResourceSpans {
+		services = append(services, resource.Resource.Attributes[0].Value.StringValue)
+		for _, scope := range resource.ScopeSpans {
+			for _, span := range scope.Spans {
+				if _, ok := spans[span.Name]; ok {
+					t.Fatalf("more than one span named %v", span.Name)
+				}
+				spans[span.Name] = span
+			}
+		}
+	}
+	return spans, services
+}
+
+func TestOtlpLoggerPingPong(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	path := filepath.Join(t.TempDir(), "otlp.json")
+	logger, err := ds.NewOtlpLogger(path, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.AddLogger(logger)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	spans, services := readOtlp(t, path)
+	if len(services) != 2 || services[0] != "a" || services[1] != "b" {
+		t.Errorf("services %v, want a and b", services)
+	}
+	chain := []string{"Ping", "handle Ping", "Pong", "handle Pong"}
+	if len(spans) != len(chain) {
+		t.Fatalf("%d spans written, want %v", len(spans), chain)
+	}
+	root := spans[chain[0]]
+	if root.ParentSpanId != "" {
+		t.Errorf("Ping has parent %v, want none", root.ParentSpanId)
+	}
+	for i := 1; i < len(chain); i++ {
+		span, parent := spans[chain[i]], spans[chain[i-1]]
+		if span.TraceId != root.TraceId {
+			t.Errorf("%v is in trace %v, want %v", chain[i], span.TraceId, root.TraceId)
+		}
+		if span.ParentSpanId != parent.SpanId {
+			t.Errorf("%v has parent %v, want %v", chain[i], span.ParentSpanId, chain[i-1])
+		}
+		if span.StartTimeUnixNano < parent.StartTimeUnixNano {
+			t.Errorf("%v starts at %v, before its parent at %v", chain[i], span.StartTimeUnixNano, parent.StartTimeUnixNano)
+		}
+	}
+}
+
+// manualClock is a clock that is moved forward by hand.
+type manualClock struct {
+	now time.Duration
+}
+
+func (c *manualClock) Now() time.Duration {
+	return c.now
+}
+
+// duration returns the time from the start to the end of a span.
+func (s otlpSpan) duration(t *testing.T) time.Duration {
+	start, err := strconv.ParseInt(s.StartTimeUnixNano, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := strconv.ParseInt(s.EndTimeUnixNano, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.Duration(end - start)
+}
+
+func TestOtlpLoggerNestedHandlers(t *testing.T) {
+	clock := &manualClock{}
+	path := filepath.Join(t.TempDir(), "otlp.json")
+	logger, err := ds.NewOtlpLogger(path, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, timer := ds.NewMessage(ping, nil), ds.NewTimer(next, nil)
+	timer.ParentId = string(message.Id)
+	reply := ds.NewReply(message, pong, nil)
+	reply.ParentId = string(message.Id)
+	steps := []func(){
+		func() { logger.LogSendMessage("b", "a", message) },
+		func() { logger.LogHandleMessage("b", "a", message) },
+		func() { logger.LogSetTimer("a", timer, 0) },
+		// The timer is handled while the message is still being handled.
+		func() { logger.LogHandleTimer("a", timer, 0) },
+		func() { logger.LogHandlerDone("a", true) },
+		func() { logger.LogHandlerDone("a", true) },
+		// The handler of the message has returned, so it is no longer the parent of events sent with its id.
+		func() { logger.LogSendMessage("a", "b", reply) },
+	}
+	for _, step := range steps {
+		step()
+		clock.now += time.Millisecond
+	}
+	clock.now += 10 * time.Millisecond
+	if err := logger.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	spans, _ := readOtlp(t, path)
+	if spans["handle Next"].ParentSpanId != spans["Next"].SpanId || spans["Next"].ParentSpanId != spans["handle Ping"].SpanId {
+		t.Errorf("handle Next is not nested in handle Ping: %+v", spans)
+	}
+	for name, want := range map[string]time.Duration{"handle Ping": 4 * time.Millisecond, "handle Next": time.Millisecond} {
+		if d := spans[name].duration(t); d != want {
+			t.Errorf("%v lasted %v, want %v", name, d, want)
+		}
+	}
+	if spans["Pong"].ParentSpanId != "" || spans["Pong"].TraceId == spans["Ping"].TraceId {
+		t.Errorf("Pong sent after its parent handler returned is %+v, want a new trace", spans["Pong"])
+	}
+}
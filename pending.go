@@ -0,0 +1,198 @@
+package disse
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// PendingEvent is a message, timer or interrupt that has been sent but not yet processed by its destination node.
+//
+// Pending events only exist when the simulation is driven step by step, for example by a Scheduler or the model checker.
+// The event may be processed at any time between Earliest and Latest, both measured from the start of the simulation.
+//
+// Seq uniquely identifies the event within a simulation, and increases in the order events are created.
+type PendingEvent struct {
+	Kind      EventKind
+	To        Address
+	Message   MessageTriplet
+	Timer     TimerTriplet
+	Interrupt InterruptTriplet
+	Earliest  time.Duration
+	Latest    time.Duration
+	Seq       uint64
+	delivered bool
+	wake      func()
+}
+
+// String returns a string representation of the pending event for debugging purposes.
+func (e PendingEvent) String() string {
+	switch e.Kind {
+	case HandleMessageEvent:
+		return fmt.Sprintf("Message(%v -> %v, %v)", e.Message.From, e.Message.To, e.Message.Message)
+	case HandleTimerEvent:
+		return fmt.Sprintf("Timer(%v, %v, %v)", e.Timer.To, e.Timer.Timer, e.Timer.Duration)
+	case HandleInterruptEvent:
+		return fmt.Sprintf("Interrupt(%v -> %v, %v)", e.Interrupt.From, e.Interrupt.To, e.Interrupt.Interrupt)
+	default:
+		return fmt.Sprintf("WakeUp(%v)", e.To)
+	}
+}
+
+// sendMessage passes a message through the interceptors of the simulation and delivers
+// the result to the message queue of its destination node.
+//
+// A random amount of latency is added if the sender and receiver are not the same node.
+func (s *LocalSimulation) sendMessage(mt MessageTriplet) {
+	if len(s.interceptors) == 0 {
+		s.queueMessage(mt, 0, false)
+		return
+	}
+	for _, i := range s.intercept(SendPhase, mt) {
+		s.queueMessage(i.MessageTriplet, i.Delay, false)
+	}
+}
+
+// queuedMessage is a message in the message queue of a node.
+type queuedMessage struct {
+	mt        MessageTriplet
+	delivered bool
+}
+
+// queueMessage delivers a message to the message queue of its destination node after latency and the given delay.
+//
+// If delivered is true, the message already arrived at its destination and was delayed by the interceptors
+// when it was delivered, so no latency is added and it is not intercepted again when it is handled.
+func (s *LocalSimulation) queueMessage(mt MessageTriplet, delay time.Duration, delivered bool) {
+	if s.controlled {
+		earliest, latest := s.clock, s.clock
+		if mt.To != mt.From && !delivered {
+			earliest, latest = s.clock+s.options.MinLatency, s.clock+s.options.MaxLatency
+		}
+		s.addPending(PendingEvent{Kind: HandleMessageEvent, To: mt.To, Message: mt, Earliest: earliest + delay, Latest: latest + delay, delivered: delivered})
+		return
+	}
+	go func() {
+		if mt.To != mt.From && !delivered {
+			time.Sleep(s.randomLatency())
+		}
+		time.Sleep(delay)
+		if !delivered {
+			s.arriveMessage(mt)
+		}
+		s.messageQueue[mt.To] <- queuedMessage{mt, delivered}
+	}()
+}
+
+// setTimer delivers a timer to the timer queue of its node once its duration has passed.
+func (s *LocalSimulation) setTimer(tt TimerTriplet) {
+	if s.controlled {
+		at := s.clock + tt.Duration
+		s.addPending(PendingEvent{Kind: HandleTimerEvent, To: tt.To, Timer: tt, Earliest: at, Latest: at})
+		return
+	}
+	go func() {
+		time.Sleep(tt.Duration)
+		s.arriveTimer(tt)
+		s.timerQueue[tt.To] <- tt
+	}()
+}
+
+// sendInterrupt immediately delivers an interrupt to the interrupt queue of its destination node.
+func (s *LocalSimulation) sendInterrupt(it InterruptTriplet) {
+	if s.controlled {
+		s.addPending(PendingEvent{Kind: HandleInterruptEvent, To: it.To, Interrupt: it, Earliest: s.clock, Latest: s.clock})
+		return
+	}
+	go func() {
+		s.interruptQueue[it.To] <- it
+	}()
+}
+
+// afterFunc calls fn for the given node once the duration has passed, and then checks the invariants of the simulation.
+func (s *LocalSimulation) afterFunc(node Address, duration time.Duration, fn func()) {
+	if s.controlled {
+		at := s.clock + duration
+		s.addPending(PendingEvent{Kind: NodeStateEvent, To: node, Earliest: at, Latest: at, wake: fn})
+		return
+	}
+	go func() {
+		<-time.After(duration)
+		s.processWake(fn)
+	}()
+}
+
+// arriveMessage logs the arrival of a message at its destination node.
+func (s *LocalSimulation) arriveMessage(mt MessageTriplet) {
+	defer s.lock()()
+	s.LogArriveMessage(mt.From, mt.To, mt.Message)
+}
+
+// arriveTimer logs the arrival of a timer at its node.
+func (s *LocalSimulation) arriveTimer(tt TimerTriplet) {
+	defer s.lock()()
+	s.LogArriveTimer(tt.To, tt.Timer, tt.Duration)
+}
+
+// randomLatency returns a random duration between the minimum and maximum latency.
+func (s *LocalSimulation) randomLatency() time.Duration {
+	return s.options.MinLatency + time.Duration(rand.Int63n(int64(s.options.MaxLatency-s.options.MinLatency)))
+}
+
+// addPending adds an event to the pending events of the simulation.
+func (s *LocalSimulation) addPending(event PendingEvent) {
+	s.seq++
+	event.Seq = s.seq
+	s.pending = append(s.pending, event)
+}
+
+// enabled returns the indices of the pending events that may be processed next.
+//
+// An event is enabled if it is due before the simulation ends and no other
+// pending event must be processed before the event can be processed.
+func (s *LocalSimulation) enabled() []int {
+	deadline := s.options.Duration
+	for _, event := range s.pending {
+		if event.Latest < deadline {
+			deadline = event.Latest
+		}
+	}
+	enabled := make([]int, 0, len(s.pending))
+	for i, event := range s.pending {
+		if event.Earliest <= deadline {
+			enabled = append(enabled, i)
+		}
+	}
+	return enabled
+}
+
+// execute removes the pending event at index i and processes it at the given time.
+//
+// The time is never earlier than the current time of the simulation or the earliest time of the event.
+func (s *LocalSimulation) execute(ctx context.Context, i int, at time.Duration) {
+	event := s.pending[i]
+	s.pending = append(s.pending[:i:i], s.pending[i+1:]...)
+	if at < event.Earliest {
+		at = event.Earliest
+	}
+	if at > s.clock {
+		s.mu.Lock()
+		s.clock = at
+		s.mu.Unlock()
+	}
+	switch event.Kind {
+	case HandleMessageEvent:
+		if !event.delivered {
+			s.LogArriveMessage(event.Message.From, event.Message.To, event.Message.Message)
+		}
+		s.processMessage(ctx, event.Message, !event.delivered)
+	case HandleTimerEvent:
+		s.LogArriveTimer(event.Timer.To, event.Timer.Timer, event.Timer.Duration)
+		s.processTimer(ctx, event.Timer)
+	case HandleInterruptEvent:
+		s.processInterrupt(ctx, event.Interrupt)
+	default:
+		s.processWake(event.wake)
+	}
+}
@@ -0,0 +1,220 @@
+package disse
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"time"
+)
+
+// ProcessWake is the type of timer used to wake up a process that is sleeping or waiting with a timeout.
+const ProcessWake TimerType = "ProcessWake"
+
+// ErrReceiveTimeout is the error returned by ReceiveTimeout when no matching message is received in time.
+var ErrReceiveTimeout = errors.New("receive timed out")
+
+// Process is the algorithm of a ProcessNode, written as a sequential function.
+//
+// The process is suspended whenever it calls Receive, ReceiveTimeout or Sleep, and is resumed by the
+// simulation when a matching message or timer is handled by the node.
+type Process func(ctx context.Context, node *ProcessNode)
+
+// MatchFunc returns true if a message received from a node is the message a process is waiting for.
+type MatchFunc func(message Message, from Address) bool
+
+// ProcessNode is a node whose algorithm is a Process instead of message and timer handlers.
+//
+// The process runs in its own goroutine, but only while the simulation is handling an event for the node,
+// so it is scheduled and logged like any other node. Messages that arrive while the process is not waiting
+// for them are kept in a mailbox until the process receives them, so a ProcessNode handles every message
+// sent to it while the process is running.
+//
+// When the simulation ends, the goroutine of a suspended process is stopped, and the process does not return.
+//
+// The state of a process cannot be saved, so a ProcessNode cannot be model checked.
+type ProcessNode struct {
+	*LocalNode
+	process Process
+	mailbox []MessageTriplet
+	match   MatchFunc
+	wake    TimerId
+	woken   *MessageTriplet
+	cause   string
+	resume  chan struct{}
+	yield   chan struct{}
+	done    bool
+}
+
+// NewProcessNode creates a new ProcessNode with the given address that runs the process once it is initialized.
+func NewProcessNode(sim *LocalSimulation, address Address, process Process) *ProcessNode {
+	return &ProcessNode{
+		LocalNode: NewLocalNode(sim, address),
+		process:   process,
+	}
+}
+
+// Init is called when the node is initialized by the simulation, and runs the process until it first blocks.
+func (n *ProcessNode) Init(ctx context.Context) {
+	n.mailbox = make([]MessageTriplet, 0)
+	n.resume = make(chan struct{})
+	n.yield = make(chan struct{})
+	n.cause = ""
+	n.done = false
+	go func() {
+		select {
+		case <-n.resume:
+		case <-ctx.Done():
+			return
+		}
+		n.process(ctx, n)
+		n.done = true
+		select {
+		case n.yield <- struct{}{}:
+		case <-ctx.Done():
+		}
+	}()
+	n.step(ctx)
+}
+
+// HandleMessage is called when the node receives a message.
+//
+// The message resumes the process if it is waiting for it, and is otherwise added to the mailbox.
+func (n *ProcessNode) HandleMessage(ctx context.Context, message Message, from Address) bool {
+	if n.done {
+		return false
+	}
+	mt := MessageTriplet{message, from, n.GetAddress()}
+	if n.match != nil && n.match(message, from) {
+		n.woken = &mt
+		n.cause = causeOf(ctx)
+		n.step(ctx)
+		return true
+	}
+	n.mailbox = append(n.mailbox, mt)
+	return true
+}
+
+// HandleTimer is called when a node receives a timer.
+//
+// Wake up timers resume the process if it is still waiting for them, and are otherwise ignored.
+func (n *ProcessNode) HandleTimer(ctx context.Context, timer Timer, duration time.Duration) bool {
+	if timer.Type != ProcessWake {
+		return false
+	}
+	if !n.done && n.wake == timer.Id {
+		n.woken = nil
+		n.cause = causeOf(ctx)
+		n.step(ctx)
+	}
+	return true
+}
+
+// Send sends a message to another node, as done by SendMessage.
+//
+// The parent of the message is the message or timer that last resumed the process.
+func (n *ProcessNode) Send(ctx context.Context, message Message, to Address) error {
+	return n.SendMessage(withCause(ctx, n.cause), message, to)
+}
+
+// Receive blocks the process until it receives a message that matches, and returns the message and its sender.
+//
+// Messages already in the mailbox are checked first, in the order they arrived.
+// If the simulation ends first, the process is stopped.
+func (n *ProcessNode) Receive(ctx context.Context, match MatchFunc) (Message, Address, error) {
+	return n.receive(ctx, match, 0)
+}
+
+// ReceiveTimeout is like Receive, but returns ErrReceiveTimeout if no matching message is received within the timeout.
+//
+// If the timeout is not positive, only the mailbox is checked and the process is not suspended.
+func (n *ProcessNode) ReceiveTimeout(ctx context.Context, match MatchFunc, timeout time.Duration) (Message, Address, error) {
+	if timeout <= 0 {
+		if mt, ok := n.takeMailbox(match); ok {
+			return mt.Message, mt.From, nil
+		}
+		return Message{}, "", ErrReceiveTimeout
+	}
+	return n.receive(ctx, match, timeout)
+}
+
+// Sleep blocks the process for the given duration.
+//
+// Messages that arrive while the process is sleeping are added to the mailbox.
+// If the simulation ends first, the process is stopped.
+func (n *ProcessNode) Sleep(ctx context.Context, duration time.Duration) error {
+	if err := n.setWake(ctx, duration); err != nil {
+		return err
+	}
+	n.suspend(ctx)
+	return nil
+}
+
+// receive blocks the process until it receives a message that matches, or until the timeout if it is positive.
+func (n *ProcessNode) receive(ctx context.Context, match MatchFunc, timeout time.Duration) (Message, Address, error) {
+	if mt, ok := n.takeMailbox(match); ok {
+		return mt.Message, mt.From, nil
+	}
+	if timeout > 0 {
+		if err := n.setWake(ctx, timeout); err != nil {
+			return Message{}, "", err
+		}
+	}
+	n.match = match
+	n.suspend(ctx)
+	n.match = nil
+	if n.woken == nil {
+		return Message{}, "", ErrReceiveTimeout
+	}
+	return n.woken.Message, n.woken.From, nil
+}
+
+// takeMailbox removes and returns the first message in the mailbox that matches, if there is one.
+func (n *ProcessNode) takeMailbox(match MatchFunc) (MessageTriplet, bool) {
+	for i, mt := range n.mailbox {
+		if match(mt.Message, mt.From) {
+			n.mailbox = append(n.mailbox[:i:i], n.mailbox[i+1:]...)
+			return mt, true
+		}
+	}
+	return MessageTriplet{}, false
+}
+
+// setWake sets the timer that wakes up the process after the given duration.
+func (n *ProcessNode) setWake(ctx context.Context, duration time.Duration) error {
+	timer := NewTimer(ProcessWake, nil)
+	n.wake = timer.Id
+	return n.SetTimer(withCause(ctx, n.cause), timer, duration)
+}
+
+// suspend hands control back to the simulation and blocks the process until it is resumed.
+//
+// If the simulation ends first, the goroutine of the process exits, so that processes that
+// loop forever do not outlive the simulation.
+func (n *ProcessNode) suspend(ctx context.Context) {
+	select {
+	case n.yield <- struct{}{}:
+	case <-ctx.Done():
+		runtime.Goexit()
+	}
+	select {
+	case <-n.resume:
+		n.wake = ""
+	case <-ctx.Done():
+		runtime.Goexit()
+	}
+}
+
+// step resumes the process and blocks the simulation until the process blocks again or returns.
+//
+// It returns early if the simulation ends first.
+func (n *ProcessNode) step(ctx context.Context) {
+	select {
+	case n.resume <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-n.yield:
+	case <-ctx.Done():
+	}
+}
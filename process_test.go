@@ -0,0 +1,112 @@
+package disse_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// ofType returns a MatchFunc that matches messages of the given type.
+func ofType(messageType ds.MessageType) ds.MatchFunc {
+	return func(message ds.Message, from ds.Address) bool {
+		return message.Type == messageType
+	}
+}
+
+func TestProcessPingPong(t *testing.T) {
+	sim := ds.NewLocalSimulation(testOptions())
+	rounds, pongs, pings := 3, 0, 0
+	sim.AddNode(ds.NewProcessNode(sim, "a", func(ctx context.Context, node *ds.ProcessNode) {
+		for i := 0; i < rounds; i++ {
+			node.Send(ctx, ds.NewMessage(ping, i), "b")
+			message, from, err := node.Receive(ctx, ofType(pong))
+			if err != nil || from != "b" || message.Data != i {
+				t.Errorf("round %d received %v from %v with %v", i, message, from, err)
+			}
+			pongs++
+		}
+	}))
+	sim.AddNode(ds.NewProcessNode(sim, "b", func(ctx context.Context, node *ds.ProcessNode) {
+		for {
+			message, from, _ := node.Receive(ctx, ofType(ping))
+			pings++
+			node.Send(ctx, ds.NewReply(message, pong, message.Data), from)
+		}
+	}))
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	if pongs != rounds || pings != rounds {
+		t.Errorf("%d pings and %d pongs handled, want %d", pings, pongs, rounds)
+	}
+}
+
+func TestProcessReceiveTimeout(t *testing.T) {
+	sim := ds.NewLocalSimulation(testOptions())
+	results := make([]error, 0)
+	times := make([]time.Duration, 0)
+	sim.AddNode(ds.NewProcessNode(sim, "a", func(ctx context.Context, node *ds.ProcessNode) {
+		receive := func(timeout time.Duration) {
+			_, _, err := node.ReceiveTimeout(ctx, ofType(ping), timeout)
+			results = append(results, err)
+			times = append(times, sim.Now())
+		}
+		// The mailbox is empty, so a timeout that is not positive returns at once.
+		receive(0)
+		receive(-time.Millisecond)
+		receive(10 * time.Millisecond)
+		// The ping arrives while the process sleeps, so it is in the mailbox.
+		node.Sleep(ctx, 20*time.Millisecond)
+		receive(0)
+	}))
+	sim.AddNode(ds.NewProcessNode(sim, "b", func(ctx context.Context, node *ds.ProcessNode) {
+		node.Sleep(ctx, 15*time.Millisecond)
+		node.Send(ctx, ds.NewMessage(ping, nil), "a")
+	}))
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []error{ds.ErrReceiveTimeout, ds.ErrReceiveTimeout, ds.ErrReceiveTimeout, nil}
+	wantTimes := []time.Duration{0, 0, 10 * time.Millisecond, 30 * time.Millisecond}
+	if len(results) != len(want) {
+		t.Fatalf("%d receives returned, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i] != want[i] || times[i] != wantTimes[i] {
+			t.Errorf("receive %d returned %v at %v, want %v at %v", i, results[i], times[i], want[i], wantTimes[i])
+		}
+	}
+}
+
+func TestProcessStopsWhenSimulationEnds(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sim := ds.NewLocalSimulation(testOptions())
+	for _, address := range []ds.Address{"a", "b", "c"} {
+		sim.AddNode(ds.NewProcessNode(sim, address, func(ctx context.Context, node *ds.ProcessNode) {
+			for {
+				node.Receive(ctx, ofType(ping))
+			}
+		}))
+	}
+	sim.AddNode(ds.NewProcessNode(sim, "d", func(ctx context.Context, node *ds.ProcessNode) {
+		for {
+			node.Sleep(ctx, time.Millisecond)
+		}
+	}))
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<16)
+		t.Errorf("%d goroutines left running after the simulation ended, want %d:\n%s", after, before, buf[:runtime.Stack(buf, true)])
+	}
+}
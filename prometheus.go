@@ -0,0 +1,202 @@
+package disse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricsEndpoint is the path of the HTTP endpoint on which the metrics of a simulation are exposed.
+const MetricsEndpoint = "/metrics"
+
+// prometheusEscaper escapes the values of Prometheus labels.
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusWriter writes metrics in the Prometheus text exposition format.
+type prometheusWriter struct {
+	w *bufio.Writer
+}
+
+// family writes the help and type lines of a metric family.
+func (p *prometheusWriter) family(name, kind, help string) {
+	fmt.Fprintf(p.w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// sample writes a single sample of a metric with the given labels, given as alternating names and values.
+func (p *prometheusWriter) sample(name string, value float64, labels ...string) {
+	p.w.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			p.w.WriteString("{")
+		} else {
+			p.w.WriteString(",")
+		}
+		fmt.Fprintf(p.w, `%v="%v"`, labels[i], prometheusEscaper.Replace(labels[i+1]))
+		if i+2 >= len(labels) {
+			p.w.WriteString("}")
+		}
+	}
+	fmt.Fprintf(p.w, " %v\n", strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// counts writes the samples of event counts, labelled by event.
+func (p *prometheusWriter) counts(name string, c *EventCounts, labels ...string) {
+	p.sample(name, float64(c.Sent), append(labels, "event", "sent")...)
+	p.sample(name, float64(c.Handled), append(labels, "event", "handled")...)
+	p.sample(name, float64(c.Dropped), append(labels, "event", "dropped")...)
+}
+
+// histogram writes the samples of a latency histogram in seconds.
+func (p *prometheusWriter) histogram(name string, h *Histogram, labels ...string) {
+	cumulative := 0
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		p.sample(name+"_bucket", float64(cumulative), append(labels, "le", strconv.FormatFloat(bound.Seconds(), 'g', -1, 64))...)
+	}
+	p.sample(name+"_bucket", float64(h.Count), append(labels, "le", "+Inf")...)
+	p.sample(name+"_sum", h.Sum.Seconds(), labels...)
+	p.sample(name+"_count", float64(h.Count), labels...)
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text exposition format.
+//
+// Every metric is prefixed with "disse_", and durations are written in seconds. Events are labelled by
+// their type or by the node at which they occurred, and latencies are labelled by message type.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	p := &prometheusWriter{w: bufio.NewWriter(w)}
+	p.family("disse_simulation_time_seconds", "gauge", "Time elapsed in the simulation.")
+	p.sample("disse_simulation_time_seconds", m.Duration.Seconds())
+
+	p.family("disse_messages_total", "counter", "Messages sent, handled and dropped by message type.")
+	for _, t := range sortedKeys(m.MessagesByType) {
+		p.counts("disse_messages_total", m.MessagesByType[t], "type", string(t))
+	}
+	p.family("disse_timers_total", "counter", "Timers set, handled and dropped by timer type.")
+	for _, t := range sortedKeys(m.TimersByType) {
+		p.counts("disse_timers_total", m.TimersByType[t], "type", string(t))
+	}
+	p.family("disse_interrupts_total", "counter", "Interrupts sent, handled and dropped.")
+	p.counts("disse_interrupts_total", &m.Interrupts)
+
+	p.family("disse_message_latency_seconds", "histogram", "Time from sending a message until it is handled by message type.")
+	for _, t := range sortedKeys(m.LatencyByType) {
+		p.histogram("disse_message_latency_seconds", m.LatencyByType[t], "type", string(t))
+	}
+
+	nodes := sortedKeys(m.Nodes)
+	p.family("disse_node_messages_total", "counter", "Messages sent, handled and dropped by node.")
+	for _, a := range nodes {
+		p.counts("disse_node_messages_total", &m.Nodes[a].Messages, "node", string(a))
+	}
+	p.family("disse_node_timers_total", "counter", "Timers set, handled and dropped by node.")
+	for _, a := range nodes {
+		p.counts("disse_node_timers_total", &m.Nodes[a].Timers, "node", string(a))
+	}
+	p.family("disse_node_interrupts_total", "counter", "Interrupts sent, handled and dropped by node.")
+	for _, a := range nodes {
+		p.counts("disse_node_interrupts_total", &m.Nodes[a].Interrupts, "node", string(a))
+	}
+	p.family("disse_node_state_changes_total", "counter", "Times each node entered each state.")
+	for _, a := range nodes {
+		for _, state := range sortedKeys(m.Nodes[a].StateChanges) {
+			p.sample("disse_node_state_changes_total", float64(m.Nodes[a].StateChanges[state]), "node", string(a), "state", string(state))
+		}
+	}
+	p.family("disse_node_running", "gauge", "Whether each node is running.")
+	for _, a := range nodes {
+		running := 0.0
+		if m.Nodes[a].State == Running {
+			running = 1
+		}
+		p.sample("disse_node_running", running, "node", string(a))
+	}
+	p.family("disse_node_uptime_seconds", "gauge", "Total time each node has been running.")
+	for _, a := range nodes {
+		p.sample("disse_node_uptime_seconds", m.Nodes[a].Uptime.Seconds(), "node", string(a))
+	}
+	p.family("disse_node_queue_depth", "gauge", "Messages that have arrived at each node and are waiting to be handled or dropped.")
+	for _, a := range nodes {
+		p.sample("disse_node_queue_depth", float64(m.Nodes[a].QueueDepth), "node", string(a))
+	}
+	p.family("disse_node_queue_depth_max", "gauge", "Largest queue depth of each node.")
+	for _, a := range nodes {
+		p.sample("disse_node_queue_depth_max", float64(m.Nodes[a].MaxQueueDepth), "node", string(a))
+	}
+	return p.w.Flush()
+}
+
+// WritePrometheusFile writes the metrics to the file at the given path in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheusFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.WritePrometheus(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ServeHTTP writes the metrics collected so far in the Prometheus text exposition format.
+func (l *MetricsLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	l.Metrics().WritePrometheus(w)
+}
+
+// Metrics returns the metrics collected so far by the simulation.
+func (s *LocalSimulation) Metrics() *Metrics {
+	return s.metrics.Metrics()
+}
+
+// serveMetrics exposes the metrics of the simulation on its metrics address until the returned function is called.
+//
+// A simulation with a metrics address is locked while it processes an event, and requests are served
+// with the simulation locked, so the metrics are consistent with the time of the simulation.
+// Nothing is served if the simulation has no metrics address.
+func (s *LocalSimulation) serveMetrics() (stop func()) {
+	if s.options.MetricsAddress == "" {
+		return func() {}
+	}
+	listener, err := net.Listen("tcp", s.options.MetricsAddress)
+	if err != nil {
+		log.Println("failed to serve metrics:", err)
+		return func() {}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(MetricsEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.metrics.ServeHTTP(w, r)
+	})
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("failed to serve metrics:", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
+
+// writeMetrics writes the final metrics of the simulation to its metrics path.
+//
+// Nothing is written if the simulation has no metrics path.
+func (s *LocalSimulation) writeMetrics() error {
+	if s.options.MetricsPath == "" {
+		return nil
+	}
+	return s.Metrics().WritePrometheusFile(s.options.MetricsPath)
+}
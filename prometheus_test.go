@@ -0,0 +1,147 @@
+package disse_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// prometheusSample matches a sample line of the Prometheus text exposition format.
+var prometheusSample = regexp.MustCompile(`^([a-z_]+)(\{[a-z]+="[^"]*"(,[a-z]+="[^"]*")*\})? \S+$`)
+
+// histogramFamily returns the family of a histogram sample, or the name of the sample if it is not one.
+func histogramFamily(name string, types map[string]string) string {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if family := strings.TrimSuffix(name, suffix); family != name && types[family] == "histogram" {
+			return family
+		}
+	}
+	return name
+}
+
+func TestMetricsServeHTTP(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	logger := ds.NewMetricsLogger(sim)
+	sim.AddLogger(logger)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(logger)
+	defer server.Close()
+	response, err := http.Get(server.URL + ds.MetricsEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("served with content type %q, want the text exposition format", contentType)
+	}
+
+	types := make(map[string]string)
+	samples := make(map[string]string)
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); strings.HasPrefix(line, "# TYPE ") && len(fields) == 4 {
+			types[fields[2]] = fields[3]
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		match := prometheusSample.FindStringSubmatch(line)
+		if match == nil {
+			t.Errorf("line %q is not a sample", line)
+			continue
+		}
+		if types[histogramFamily(match[1], types)] == "" {
+			t.Errorf("sample %q comes before the type of its family", line)
+		}
+		name, value, _ := strings.Cut(line, " ")
+		samples[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"disse_simulation_time_seconds":                               "0.1",
+		`disse_messages_total{type="Ping",event="sent"}`:              "1",
+		`disse_messages_total{type="Pong",event="handled"}`:           "1",
+		`disse_message_latency_seconds_bucket{type="Ping",le="+Inf"}`: "1",
+		`disse_message_latency_seconds_count{type="Pong"}`:            "1",
+		`disse_interrupts_total{event="dropped"}`:                     "0",
+		`disse_node_messages_total{node="a",event="sent"}`:            "1",
+		`disse_node_state_changes_total{node="b",state="Running"}`:    "1",
+		`disse_node_running{node="a"}`:                                "1",
+		`disse_node_uptime_seconds{node="b"}`:                         "0.1",
+		`disse_node_queue_depth{node="b"}`:                            "0",
+		`disse_node_queue_depth_max{node="b"}`:                        "1",
+	}
+	for name, value := range want {
+		if samples[name] != value {
+			t.Errorf("sample %v = %q, want %q", name, samples[name], value)
+		}
+	}
+	for family, kind := range map[string]string{
+		"disse_messages_total":          "counter",
+		"disse_message_latency_seconds": "histogram",
+		"disse_node_queue_depth":        "gauge",
+	} {
+		if types[family] != kind {
+			t.Errorf("%v has type %q, want %q", family, types[family], kind)
+		}
+	}
+}
+
+// busyNode handles every message slowly, counting the handlers of all busy nodes running at the same time.
+type busyNode struct {
+	*ds.LocalNode
+	active, maxActive *int32
+}
+
+func (n *busyNode) Init(ctx context.Context) {}
+
+func (n *busyNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	active := atomic.AddInt32(n.active, 1)
+	defer atomic.AddInt32(n.active, -1)
+	for max := atomic.LoadInt32(n.maxActive); active > max && !atomic.CompareAndSwapInt32(n.maxActive, max, active); {
+		max = atomic.LoadInt32(n.maxActive)
+	}
+	time.Sleep(20 * time.Millisecond)
+	return true
+}
+
+func (n *busyNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+func TestServingMetricsLocksSimulation(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	options := testOptions()
+	options.MetricsAddress = address
+	sim := ds.NewLocalSimulation(options)
+	var active, maxActive int32
+	sim.AddNode(newPingNode(sim, "a", "b", "c"))
+	sim.AddNode(&busyNode{LocalNode: ds.NewLocalNode(sim, "b"), active: &active, maxActive: &maxActive})
+	sim.AddNode(&busyNode{LocalNode: ds.NewLocalNode(sim, "c"), active: &active, maxActive: &maxActive})
+	sim.Run()
+	if maxActive != 1 {
+		t.Errorf("%d handlers ran at the same time while serving metrics, want 1", maxActive)
+	}
+}
@@ -0,0 +1,492 @@
+package disse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Scenario sets up a simulation by adding nodes, invariants and eventual properties to it.
+//
+// Scenarios are called once for every run of a randomized test, each time with a new simulation,
+// so they must create new nodes every time they are called.
+type Scenario func(sim *LocalSimulation) error
+
+// Fault is a crash or a sleep of a node injected into a simulation at a given time.
+type Fault struct {
+	At   time.Duration
+	Node Address
+	// Type is either StopInterrupt or SleepInterrupt.
+	Type InterruptType
+	// Duration is the duration of a SleepInterrupt.
+	Duration time.Duration
+}
+
+// Decision is a choice made by a Scheduler, recorded so that it can be replayed.
+//
+// Kind and To describe the chosen event, so that the decision can still be replayed
+// when the index of the event changes, for example after a fault is removed.
+type Decision struct {
+	Index int
+	At    time.Duration
+	Kind  EventKind
+	To    Address
+}
+
+// Schedule is a replayable description of a run of a scenario.
+//
+// The decisions are replayed in order, after which the pending event with the
+// earliest time is always processed next, at its earliest time. The latencies are those of
+// the simulation the schedule was recorded with, and are used when it is replayed.
+type Schedule struct {
+	Seed       int64
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	Duration   time.Duration
+	Faults     []Fault
+	Decisions  []Decision
+}
+
+// LoadSchedule loads a schedule from the JSON file at the given path.
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &Schedule{}
+	if err := json.Unmarshal(data, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Save saves the schedule as JSON to the file at the given path.
+func (s *Schedule) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// RandomTestOptions is used to set the options for a randomized test.
+//
+// A zero Seeds, Duration or MaxSleep is replaced by its default value, and so are the latencies
+// if MaxLatency is zero.
+type RandomTestOptions struct {
+	// Seeds is the number of seeds to run the scenario with.
+	Seeds int
+	// FirstSeed is the first seed, the following runs use consecutive seeds.
+	FirstSeed  int64
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	Duration   time.Duration
+	// MaxFaults is the maximum number of faults injected into each run.
+	MaxFaults int
+	// FaultTypes are the types of interrupts used as faults, which are StopInterrupt and SleepInterrupt.
+	FaultTypes []InterruptType
+	// MaxSleep is the maximum duration of a SleepInterrupt fault.
+	MaxSleep time.Duration
+	// ReproducerPath is the path the shrunk schedule of the first failing run is saved to.
+	ReproducerPath string
+	// NewScheduler creates the scheduler used for the run with the given seed.
+	//
+	// If nil, a RandomScheduler is used.
+	NewScheduler func(seed int64) Scheduler
+}
+
+const (
+	// DefaultSeeds is the default number of seeds used by a randomized test.
+	DefaultSeeds = 1000
+	// DefaultMaxFaults is the default maximum number of faults injected into each run.
+	DefaultMaxFaults = 1
+	// DefaultMaxSleep is the default maximum duration of a SleepInterrupt fault.
+	DefaultMaxSleep = 1 * time.Second
+	// DefaultReproducerPath is the default path to the saved schedule of a failing run.
+	DefaultReproducerPath = "reproducer.json"
+)
+
+// RandomTestResult is the result of a randomized test.
+type RandomTestResult struct {
+	// Runs is the number of runs performed, including the runs used to shrink the failing schedule.
+	Runs int
+	// Failure is the violation found by running the shrunk schedule, or nil if all runs passed.
+	Failure error
+	// Schedule is the shrunk schedule of the first failing run, or nil if all runs passed.
+	Schedule *Schedule
+	// Messages is the number of messages handled by the shrunk schedule.
+	Messages int
+	// BugProbability is the minimum probability that the runs found a bug of the depth the
+	// schedulers were created for, if such a bug exists.
+	//
+	// It is only set if the schedulers implement ProbabilisticScheduler.
+	BugProbability float64
+}
+
+// RandomTest runs the scenario once for every seed, with random latencies and faults.
+//
+// The order of events in each run is decided by the scheduler created by NewScheduler,
+// such as a PCTScheduler to search for ordering bugs of a bounded depth.
+//
+// When a run violates an invariant or an eventual property, its schedule is shrunk to a
+// minimal reproducer with fewer faults, fewer messages and a shorter duration that still
+// fails, which is saved to the reproducer path and can be run again with Replay.
+//
+// An error is returned if the scenario cannot be set up.
+func RandomTest(scenario Scenario, options *RandomTestOptions) (*RandomTestResult, error) {
+	if options == nil {
+		options = &RandomTestOptions{
+			Seeds:          DefaultSeeds,
+			MinLatency:     DefaultMinLatency,
+			MaxLatency:     DefaultMaxLatency,
+			Duration:       DefaultDuration,
+			MaxFaults:      DefaultMaxFaults,
+			FaultTypes:     []InterruptType{StopInterrupt},
+			MaxSleep:       DefaultMaxSleep,
+			ReproducerPath: DefaultReproducerPath,
+		}
+	}
+	if options.Seeds == 0 || options.MaxLatency == 0 || options.Duration == 0 || options.MaxSleep == 0 {
+		defaulted := *options
+		if defaulted.Seeds == 0 {
+			defaulted.Seeds = DefaultSeeds
+		}
+		if defaulted.MaxLatency == 0 {
+			defaulted.MinLatency, defaulted.MaxLatency = DefaultMinLatency, DefaultMaxLatency
+		}
+		if defaulted.Duration == 0 {
+			defaulted.Duration = DefaultDuration
+		}
+		if defaulted.MaxSleep == 0 {
+			defaulted.MaxSleep = DefaultMaxSleep
+		}
+		options = &defaulted
+	}
+	rt := &randomTest{scenario: scenario, options: options, result: &RandomTestResult{}}
+	missProbability := 1.0
+	for i := 0; i < options.Seeds; i++ {
+		seed := options.FirstSeed + int64(i)
+		schedule, err := rt.randomSchedule(seed)
+		if err != nil {
+			return nil, err
+		}
+		recorder := &recordingScheduler{scheduler: rt.newScheduler(seed)}
+		failure, err := rt.run(schedule, recorder)
+		if err != nil {
+			return nil, err
+		}
+		if scheduler, ok := recorder.scheduler.(ProbabilisticScheduler); ok {
+			missProbability *= 1 - scheduler.BugProbability()
+			rt.result.BugProbability = 1 - missProbability
+		}
+		if failure == nil {
+			continue
+		}
+		schedule.Decisions = recorder.decisions
+		if err := rt.shrink(schedule, failure); err != nil {
+			return nil, err
+		}
+		if options.ReproducerPath != "" {
+			if err := rt.result.Schedule.Save(options.ReproducerPath); err != nil {
+				log.Println("failed to save reproducer:", err)
+			}
+		}
+		break
+	}
+	return rt.result, nil
+}
+
+// Replay runs the scenario with the given schedule and returns the violation it causes, if any.
+//
+// The options are used to create the simulation, so that loggers can be set up to inspect the run.
+// If the options are nil, no loggers are created.
+func Replay(scenario Scenario, schedule *Schedule, options *LocalSimulationOptions) error {
+	sim, err := newScheduledSimulation(scenario, schedule, options)
+	if err != nil {
+		return err
+	}
+	return sim.RunScheduled(&replayScheduler{decisions: schedule.Decisions})
+}
+
+// newScheduledSimulation creates a simulation for a schedule, sets up the scenario and injects the faults.
+func newScheduledSimulation(scenario Scenario, schedule *Schedule, options *LocalSimulationOptions) (*LocalSimulation, error) {
+	if options == nil {
+		options = &LocalSimulationOptions{
+			MinLatency: DefaultMinLatency,
+			MaxLatency: DefaultMaxLatency,
+			BufferSize: DefaultBufferSize,
+		}
+	}
+	simOptions := *options
+	simOptions.Duration = schedule.Duration
+	if schedule.MaxLatency > 0 {
+		simOptions.MinLatency, simOptions.MaxLatency = schedule.MinLatency, schedule.MaxLatency
+	}
+	sim := NewLocalSimulation(&simOptions)
+	sim.seed = schedule.Seed
+	if err := scenario(sim); err != nil {
+		return nil, err
+	}
+	for _, fault := range schedule.Faults {
+		if err := sim.InjectFault(fault); err != nil {
+			return nil, err
+		}
+	}
+	return sim, nil
+}
+
+// InjectFault adds a fault to the simulation, which is processed like any other interrupt at the time of the fault.
+//
+// Faults only take effect when the simulation is run with RunScheduled.
+func (s *LocalSimulation) InjectFault(fault Fault) error {
+	if _, ok := s.nodes[fault.Node]; !ok {
+		return fmt.Errorf("node with address %v does not exist", fault.Node)
+	}
+	var data InterruptData
+	if fault.Type == SleepInterrupt {
+		data = SleepInterruptData{Duration: fault.Duration}
+	}
+	it := InterruptTriplet{NewInterrupt(fault.Type, data), fault.Node, fault.Node}
+	s.addPending(PendingEvent{Kind: HandleInterruptEvent, To: fault.Node, Interrupt: it, Earliest: fault.At, Latest: fault.At})
+	return nil
+}
+
+// randomTest holds the state of a randomized test.
+type randomTest struct {
+	scenario Scenario
+	options  *RandomTestOptions
+	result   *RandomTestResult
+}
+
+// newScheduler returns the scheduler for the run with the given seed.
+func (rt *randomTest) newScheduler(seed int64) Scheduler {
+	if rt.options.NewScheduler != nil {
+		return rt.options.NewScheduler(seed)
+	}
+	return NewRandomScheduler(seed)
+}
+
+// randomSchedule returns a schedule with random faults for the given seed.
+func (rt *randomTest) randomSchedule(seed int64) (*Schedule, error) {
+	sim := NewLocalSimulation(rt.simulationOptions(rt.options.Duration))
+	if err := rt.scenario(sim); err != nil {
+		return nil, err
+	}
+	addresses := sortedAddresses(sim.nodes)
+	r := rand.New(rand.NewSource(seed))
+	schedule := &Schedule{
+		Seed:       seed,
+		MinLatency: rt.options.MinLatency,
+		MaxLatency: rt.options.MaxLatency,
+		Duration:   rt.options.Duration,
+		Faults:     make([]Fault, 0),
+	}
+	if len(addresses) == 0 || len(rt.options.FaultTypes) == 0 || rt.options.MaxFaults <= 0 {
+		return schedule, nil
+	}
+	faults := r.Intn(rt.options.MaxFaults + 1)
+	for i := 0; i < faults; i++ {
+		fault := Fault{
+			At:   time.Duration(r.Int63n(int64(rt.options.Duration))),
+			Node: addresses[r.Intn(len(addresses))],
+			Type: rt.options.FaultTypes[r.Intn(len(rt.options.FaultTypes))],
+		}
+		if fault.Type == SleepInterrupt && rt.options.MaxSleep > 0 {
+			fault.Duration = time.Duration(r.Int63n(int64(rt.options.MaxSleep)))
+		}
+		schedule.Faults = append(schedule.Faults, fault)
+	}
+	return schedule, nil
+}
+
+// simulationOptions returns the options of the simulations run by the test.
+func (rt *randomTest) simulationOptions(duration time.Duration) *LocalSimulationOptions {
+	return &LocalSimulationOptions{
+		MinLatency: rt.options.MinLatency,
+		MaxLatency: rt.options.MaxLatency,
+		Duration:   duration,
+		BufferSize: DefaultBufferSize,
+	}
+}
+
+// run runs the scenario with the given schedule and scheduler.
+//
+// It returns the violation caused by the run, or an error if the scenario cannot be set up.
+func (rt *randomTest) run(schedule *Schedule, scheduler Scheduler) (violation, error) {
+	rt.result.Runs++
+	sim, err := newScheduledSimulation(rt.scenario, schedule, rt.simulationOptions(schedule.Duration))
+	if err != nil {
+		return nil, err
+	}
+	sim.quiet = true
+	err = sim.RunScheduled(scheduler)
+	var v violation
+	if errors.As(err, &v) {
+		return v, nil
+	}
+	return nil, err
+}
+
+// shrink repeatedly tries smaller variants of a failing schedule, keeping every variant that
+// still fails, until no variant is smaller. The smallest schedule is stored in the result.
+//
+// Schedules are compared by their number of faults, then by the number of messages handled
+// before the violation, then by their duration.
+func (rt *randomTest) shrink(schedule *Schedule, failure violation) error {
+	best, bestFailure := truncateSchedule(schedule, failure), failure
+	for improved := true; improved; {
+		improved = false
+		for _, candidate := range shrinkCandidates(best) {
+			candidateFailure, err := rt.run(candidate, &replayScheduler{decisions: candidate.Decisions})
+			if err != nil {
+				return err
+			}
+			if candidateFailure == nil || !smallerSchedule(candidate, candidateFailure, best, bestFailure) {
+				continue
+			}
+			best, bestFailure = truncateSchedule(candidate, candidateFailure), candidateFailure
+			improved = true
+			break
+		}
+	}
+	rt.result.Schedule = best
+	rt.result.Failure = bestFailure
+	rt.result.Messages = handledMessages(violationTrace(bestFailure))
+	return nil
+}
+
+// shrinkCandidates returns variants of a schedule with one fault removed or fewer decisions.
+func shrinkCandidates(schedule *Schedule) []*Schedule {
+	candidates := make([]*Schedule, 0)
+	for i := range schedule.Faults {
+		candidate := *schedule
+		candidate.Faults = append(append([]Fault(nil), schedule.Faults[:i]...), schedule.Faults[i+1:]...)
+		candidates = append(candidates, &candidate)
+	}
+	for _, n := range []int{0, len(schedule.Decisions) / 2, len(schedule.Decisions) - 1} {
+		if n >= 0 && n < len(schedule.Decisions) {
+			candidate := *schedule
+			candidate.Decisions = schedule.Decisions[:n]
+			candidates = append(candidates, &candidate)
+		}
+	}
+	return candidates
+}
+
+// truncateSchedule returns a copy of the schedule that ends when the failure was detected.
+func truncateSchedule(schedule *Schedule, failure violation) *Schedule {
+	truncated := *schedule
+	switch v := failure.(type) {
+	case *InvariantViolation:
+		truncated.Duration = v.Event.Time
+	case *LivenessViolation:
+		truncated.Duration = v.Deadline
+	}
+	return &truncated
+}
+
+// smallerSchedule reports whether schedule a with failure fa is smaller than schedule b with failure fb.
+func smallerSchedule(a *Schedule, fa violation, b *Schedule, fb violation) bool {
+	if len(a.Faults) != len(b.Faults) {
+		return len(a.Faults) < len(b.Faults)
+	}
+	ma, mb := handledMessages(violationTrace(fa)), handledMessages(violationTrace(fb))
+	if ma != mb {
+		return ma < mb
+	}
+	if a.Duration != b.Duration {
+		return a.Duration < b.Duration
+	}
+	return len(a.Decisions) < len(b.Decisions)
+}
+
+// violationTrace returns the trace of a violation.
+func violationTrace(v violation) []Event {
+	switch v := v.(type) {
+	case *InvariantViolation:
+		return v.Trace
+	case *LivenessViolation:
+		return v.Trace
+	default:
+		return nil
+	}
+}
+
+// handledMessages returns the number of messages handled in a trace.
+func handledMessages(trace []Event) int {
+	messages := 0
+	for _, event := range trace {
+		if event.Kind == HandleMessageEvent {
+			messages++
+		}
+	}
+	return messages
+}
+
+// recordingScheduler is a Scheduler that records the decisions of another scheduler.
+type recordingScheduler struct {
+	scheduler Scheduler
+	decisions []Decision
+}
+
+// Next returns the decision of the recorded scheduler.
+func (s *recordingScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	index, at := s.scheduler.Next(now, pending, enabled)
+	s.decisions = append(s.decisions, Decision{Index: index, At: at, Kind: pending[index].Kind, To: pending[index].To})
+	return index, at
+}
+
+// replayScheduler is a Scheduler that replays recorded decisions and then
+// processes the pending event with the earliest time.
+type replayScheduler struct {
+	decisions []Decision
+	next      int
+}
+
+// Next returns the next recorded decision if it is valid, or the pending event with the earliest time.
+//
+// A decision is valid if its index is enabled and the event at the index matches the recorded event.
+// Otherwise the first enabled event that matches it is processed instead, as indices shift when a
+// schedule is shrunk, and the decision is skipped if there is none.
+func (s *replayScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	for s.next < len(s.decisions) {
+		decision := s.decisions[s.next]
+		s.next++
+		if containsIndex(enabled, decision.Index) && decision.matches(pending[decision.Index]) {
+			return decision.Index, decision.At
+		}
+		for _, i := range enabled {
+			if decision.To != "" && decision.matches(pending[i]) {
+				return i, decision.At
+			}
+		}
+	}
+	earliest := enabled[0]
+	for _, i := range enabled {
+		if pending[i].Earliest < pending[earliest].Earliest {
+			earliest = i
+		}
+	}
+	return earliest, pending[earliest].Earliest
+}
+
+// matches reports whether the pending event is the event the decision was recorded for.
+//
+// Decisions saved without their event match every event.
+func (d Decision) matches(event PendingEvent) bool {
+	return d.To == "" || d.Kind == event.Kind && d.To == event.To
+}
+
+// containsIndex reports whether the index is in the given indices.
+func containsIndex(indices []int, index int) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
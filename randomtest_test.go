@@ -0,0 +1,69 @@
+package disse_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// pingScenario is a scenario in which a pings b and c, and a must never receive a pong.
+func pingScenario(sim *ds.LocalSimulation) error {
+	sim.AddNode(newPingNode(sim, "a", "b", "c"))
+	sim.AddNode(newPingNode(sim, "b"))
+	sim.AddNode(newPingNode(sim, "c"))
+	sim.AddInvariant("no pongs", noPongs)
+	return nil
+}
+
+func TestRandomTestShrinksSchedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reproducer.json")
+	// Zero latencies, duration and sleeps are replaced by their defaults.
+	result, err := ds.RandomTest(pingScenario, &ds.RandomTestOptions{
+		Seeds:          10,
+		MaxFaults:      3,
+		FaultTypes:     []ds.InterruptType{ds.SleepInterrupt},
+		ReproducerPath: path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failure == nil {
+		t.Fatalf("no failure found in %d runs", result.Runs)
+	}
+	if len(result.Schedule.Faults) != 0 {
+		t.Errorf("shrunk schedule has faults %v", result.Schedule.Faults)
+	}
+	if result.Messages != 2 {
+		t.Errorf("shrunk schedule handles %d messages, want 2", result.Messages)
+	}
+	if want := result.Failure.(*ds.InvariantViolation).Event.Time; result.Schedule.Duration != want {
+		t.Errorf("shrunk schedule lasts %v, want %v", result.Schedule.Duration, want)
+	}
+
+	schedule, err := ds.LoadSchedule(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ds.Replay(pingScenario, schedule, nil)
+	if err == nil || err.Error() != result.Failure.Error() {
+		t.Errorf("Replay() = %v, want %v", err, result.Failure)
+	}
+}
+
+func TestRandomTestPasses(t *testing.T) {
+	result, err := ds.RandomTest(func(sim *ds.LocalSimulation) error {
+		sim.AddNode(newPingNode(sim, "a", "b"))
+		sim.AddNode(newPingNode(sim, "b"))
+		return nil
+	}, &ds.RandomTestOptions{Seeds: 20, MaxFaults: 2, FaultTypes: []ds.InterruptType{ds.StopInterrupt, ds.SleepInterrupt}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failure != nil || result.Schedule != nil {
+		t.Errorf("unexpected failure %v", result.Failure)
+	}
+	if result.Runs != 20 {
+		t.Errorf("%d runs, want 20", result.Runs)
+	}
+}
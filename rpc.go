@@ -0,0 +1,142 @@
+package disse
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RpcTimeout is the type of timer used to time out a call.
+const RpcTimeout TimerType = "RpcTimeout"
+
+// ErrRpcTimeout is the error passed to the callback of a call that did not receive a reply in time.
+var ErrRpcTimeout = errors.New("rpc timed out")
+
+// RpcCallback is called with the reply to a call, or with ErrRpcTimeout if no reply was received in time.
+type RpcCallback func(ctx context.Context, reply Message, err error)
+
+// rpcCall is a call that is waiting for a reply.
+type rpcCall struct {
+	to       Address
+	request  Message
+	timeout  time.Duration
+	retries  int
+	callback RpcCallback
+	timer    TimerId
+}
+
+// rpcNode is implemented by nodes that embed a LocalNode, so the simulation can deliver replies and timeouts of calls.
+//
+// Replies and timeouts are matched against the calls of a node and all its sub nodes before any handler is called.
+type rpcNode interface {
+	handleReply(ctx context.Context, message Message, from Address) bool
+	handleRpcTimeout(ctx context.Context, timer Timer) bool
+}
+
+// Call sends a request to another node and calls the callback with the matching reply.
+//
+// The reply must be created with NewReply or sent with Reply, so that it carries the id of the request.
+// If no reply is received within the timeout, the callback is called with ErrRpcTimeout.
+// Replies to a call are handled by the node that made the call, before the HandleMessage of any node is called,
+// and timeouts of calls are never passed to HandleTimer.
+func (n *LocalNode) Call(ctx context.Context, to Address, request Message, timeout time.Duration, callback RpcCallback) error {
+	return n.CallWithRetries(ctx, to, request, timeout, 0, callback)
+}
+
+// CallWithRetries is like Call, but sends the request again up to retries times when the timeout expires.
+//
+// Every retry sends the same message with the same id, so the receiver can recognise duplicates.
+func (n *LocalNode) CallWithRetries(ctx context.Context, to Address, request Message, timeout time.Duration, retries int, callback RpcCallback) error {
+	if err := n.SendMessage(ctx, request, to); err != nil {
+		return err
+	}
+	if n.calls == nil {
+		n.calls = make(map[MessageId]*rpcCall)
+	}
+	call := &rpcCall{
+		to:       to,
+		request:  request,
+		timeout:  timeout,
+		retries:  retries,
+		callback: callback,
+	}
+	n.calls[request.Id] = call
+	return n.setRpcTimeout(ctx, call)
+}
+
+// Reply sends a reply with the given messageType and data to a request received from a node.
+func (n *LocalNode) Reply(ctx context.Context, request Message, from Address, messageType MessageType, data MessageData) error {
+	return n.SendMessage(ctx, NewReply(request, messageType, data), from)
+}
+
+// setRpcTimeout sets the timer that times out the current attempt of a call.
+func (n *LocalNode) setRpcTimeout(ctx context.Context, call *rpcCall) error {
+	timer := NewTimer(RpcTimeout, call.request.Id)
+	call.timer = timer.Id
+	return n.SetTimer(ctx, timer, call.timeout)
+}
+
+// handleReply calls the callback of the call that the message replies to, if the call was made by this node.
+func (n *LocalNode) handleReply(ctx context.Context, message Message, from Address) bool {
+	if message.ReplyTo == "" {
+		return false
+	}
+	call, ok := n.calls[message.ReplyTo]
+	if !ok {
+		return false
+	}
+	delete(n.calls, message.ReplyTo)
+	call.callback(ctx, message, nil)
+	return true
+}
+
+// handleRpcTimeout retries or times out the call of the timer, if the call was made by this node.
+//
+// Timers of earlier attempts of a call are ignored. If the request cannot be sent again,
+// the callback is called with the error.
+func (n *LocalNode) handleRpcTimeout(ctx context.Context, timer Timer) bool {
+	if timer.Type != RpcTimeout {
+		return false
+	}
+	id := timer.Data.(MessageId)
+	call, ok := n.calls[id]
+	if !ok || call.timer != timer.Id {
+		return ok
+	}
+	if call.retries > 0 {
+		call.retries--
+		err := n.SendMessage(ctx, call.request, call.to)
+		if err == nil {
+			err = n.setRpcTimeout(ctx, call)
+		}
+		if err == nil {
+			return true
+		}
+		delete(n.calls, id)
+		call.callback(ctx, Message{}, err)
+		return true
+	}
+	delete(n.calls, id)
+	call.callback(ctx, Message{}, ErrRpcTimeout)
+	return true
+}
+
+// saveCalls returns a copy of the calls of the node that are waiting for a reply.
+//
+// It is used by the model checker to save the state of the node.
+func (n *LocalNode) saveCalls() map[MessageId]rpcCall {
+	calls := make(map[MessageId]rpcCall, len(n.calls))
+	for id, call := range n.calls {
+		calls[id] = *call
+	}
+	return calls
+}
+
+// restoreCalls restores the calls of the node previously returned by saveCalls.
+func (n *LocalNode) restoreCalls(calls map[MessageId]rpcCall) {
+	n.calls = make(map[MessageId]*rpcCall, len(calls))
+	for id, call := range calls {
+		call := call
+		n.calls[id] = &call
+	}
+}
@@ -0,0 +1,125 @@
+package disse_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const (
+	request ds.MessageType = "Request"
+	reply   ds.MessageType = "Reply"
+)
+
+// rpcServer replies to every request after ignoring the first ignore requests.
+type rpcServer struct {
+	*ds.LocalNode
+	ignore   int
+	requests []ds.MessageId
+}
+
+func (n *rpcServer) Init(ctx context.Context) {}
+
+func (n *rpcServer) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	if message.Type != request {
+		return false
+	}
+	n.requests = append(n.requests, message.Id)
+	if len(n.requests) > n.ignore {
+		n.Reply(ctx, message, from, reply, nil)
+	}
+	return true
+}
+
+func (n *rpcServer) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+// rpcClient calls the server s when it is initialized and records the results passed to the callback.
+type rpcClient struct {
+	*ds.LocalNode
+	sim     *ds.LocalSimulation
+	timeout time.Duration
+	retries int
+	request ds.Message
+	replies []ds.Message
+	errs    []error
+	at      []time.Duration
+}
+
+func (n *rpcClient) Init(ctx context.Context) {
+	n.request = ds.NewMessage(request, nil)
+	n.CallWithRetries(ctx, "s", n.request, n.timeout, n.retries, func(ctx context.Context, reply ds.Message, err error) {
+		n.replies = append(n.replies, reply)
+		n.errs = append(n.errs, err)
+		n.at = append(n.at, n.sim.Now())
+	})
+}
+
+func (n *rpcClient) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	return false
+}
+
+func (n *rpcClient) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+// runCall runs a call with the given timeout and retries to a server that ignores the first ignore requests.
+func runCall(t *testing.T, timeout time.Duration, retries, ignore int) (*rpcClient, *rpcServer) {
+	t.Helper()
+	sim := ds.NewLocalSimulation(testOptions())
+	client := &rpcClient{LocalNode: ds.NewLocalNode(sim, "c"), sim: sim, timeout: timeout, retries: retries}
+	server := &rpcServer{LocalNode: ds.NewLocalNode(sim, "s"), ignore: ignore}
+	sim.AddNode(client)
+	sim.AddNode(server)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.errs) != 1 {
+		t.Fatalf("callback called %d times, want once", len(client.errs))
+	}
+	return client, server
+}
+
+func TestCallReply(t *testing.T) {
+	client, server := runCall(t, 20*time.Millisecond, 0, 0)
+	if client.errs[0] != nil || client.replies[0].Type != reply || client.replies[0].ReplyTo != client.request.Id {
+		t.Errorf("callback called with %v and %v, want the reply", client.replies[0], client.errs[0])
+	}
+	if len(server.requests) != 1 {
+		t.Errorf("server received %d requests, want 1", len(server.requests))
+	}
+}
+
+func TestCallWithRetriesTimeout(t *testing.T) {
+	client, server := runCall(t, 10*time.Millisecond, 2, 3)
+	if client.errs[0] != ds.ErrRpcTimeout {
+		t.Errorf("callback called with %v, want %v", client.errs[0], ds.ErrRpcTimeout)
+	}
+	if client.at[0] != 30*time.Millisecond {
+		t.Errorf("call timed out at %v, want after three attempts at 30ms", client.at[0])
+	}
+	if len(server.requests) != 3 {
+		t.Errorf("server received %d requests, want 3", len(server.requests))
+	}
+}
+
+func TestCallWithRetriesReusesId(t *testing.T) {
+	client, server := runCall(t, 10*time.Millisecond, 3, 2)
+	if client.errs[0] != nil || client.replies[0].ReplyTo != client.request.Id {
+		t.Errorf("callback called with %v and %v, want the reply", client.replies[0], client.errs[0])
+	}
+	if len(server.requests) != 3 {
+		t.Fatalf("server received %d requests, want 3", len(server.requests))
+	}
+	for i, id := range server.requests {
+		if id != client.request.Id {
+			t.Errorf("attempt %d has id %v, want %v", i, id, client.request.Id)
+		}
+	}
+	if client.at[0] <= 20*time.Millisecond || client.at[0] > 30*time.Millisecond {
+		t.Errorf("reply received at %v, want during the third attempt", client.at[0])
+	}
+}
@@ -0,0 +1,297 @@
+package disse
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Scheduler decides which pending event a simulation processes next, and when.
+//
+// Schedulers are used by RunScheduled to run simulations deterministically in virtual time.
+type Scheduler interface {
+	// Next returns the index in pending of the event to process next, and the time at which to process it.
+	//
+	// The indices in enabled are the events that can be processed next without any other
+	// pending event exceeding its latest time. The returned time is raised to the current
+	// time or the earliest time of the event if it is before either of them.
+	Next(now time.Duration, pending []PendingEvent, enabled []int) (index int, at time.Duration)
+}
+
+// RandomScheduler is a Scheduler that processes each message after a random latency between
+// the minimum and maximum latency of the simulation, like a simulation that is not scheduled.
+//
+// It is deterministic for a given seed.
+type RandomScheduler struct {
+	rand  *rand.Rand
+	times map[uint64]time.Duration
+}
+
+// NewRandomScheduler creates a new RandomScheduler with the given seed.
+func NewRandomScheduler(seed int64) *RandomScheduler {
+	return &RandomScheduler{
+		rand:  rand.New(rand.NewSource(seed)),
+		times: make(map[uint64]time.Duration),
+	}
+}
+
+// Next returns the enabled event with the earliest randomly drawn processing time.
+//
+// The time of an event is drawn the first time it is enabled. The event with the earliest drawn time is always
+// enabled, as it is processed no later than the latest time of any other event.
+func (s *RandomScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	next, nextAt := -1, time.Duration(0)
+	for _, i := range enabled {
+		event := pending[i]
+		at, ok := s.times[event.Seq]
+		if !ok {
+			at = event.Earliest
+			if event.Latest > event.Earliest {
+				at += time.Duration(s.rand.Int63n(int64(event.Latest - event.Earliest)))
+			}
+			s.times[event.Seq] = at
+		}
+		if next == -1 || at < nextAt {
+			next, nextAt = i, at
+		}
+	}
+	delete(s.times, pending[next].Seq)
+	return next, nextAt
+}
+
+// ProbabilisticScheduler is implemented by schedulers that guarantee a minimum probability
+// of finding a bug of a given depth in a single run.
+type ProbabilisticScheduler interface {
+	Scheduler
+	// BugProbability returns the minimum probability that the run found a bug of the depth
+	// the scheduler was created for, if such a bug exists.
+	BugProbability() float64
+}
+
+// PCTScheduler is a Scheduler that implements Probabilistic Concurrency Testing (PCT).
+//
+// Every node is given a random priority when it first receives an event, and the enabled event
+// whose node has the highest priority is processed next. At depth - 1 randomly chosen steps,
+// the priority of the node that processes the event is lowered below every initial priority.
+//
+// With n nodes and k steps, a run finds a bug of depth d with probability at least 1 / (n * k^(d-1)),
+// where the depth of a bug is the number of ordering constraints between events needed to trigger it.
+type PCTScheduler struct {
+	rand         *rand.Rand
+	depth        int
+	steps        int
+	step         int
+	priorities   map[Address]float64
+	changePoints map[int]float64
+}
+
+// DefaultPCTSteps is the default number of steps a PCTScheduler expects a run to take.
+const DefaultPCTSteps = 1000
+
+// NewPCTScheduler creates a new PCTScheduler with the given seed, bug depth and expected number of steps.
+//
+// If steps is not positive, DefaultPCTSteps is used.
+func NewPCTScheduler(seed int64, depth, steps int) *PCTScheduler {
+	if steps <= 0 {
+		steps = DefaultPCTSteps
+	}
+	s := &PCTScheduler{
+		rand:         rand.New(rand.NewSource(seed)),
+		depth:        depth,
+		steps:        steps,
+		priorities:   make(map[Address]float64),
+		changePoints: make(map[int]float64),
+	}
+	for i := 0; i < depth-1 && i < steps; i++ {
+		step := s.rand.Intn(steps) + 1
+		for _, ok := s.changePoints[step]; ok; _, ok = s.changePoints[step] {
+			step = s.rand.Intn(steps) + 1
+		}
+		s.changePoints[step] = float64(depth - 2 - i)
+	}
+	return s
+}
+
+// Next returns the enabled event whose node has the highest priority, at its earliest time.
+//
+// Events for the same node are processed in the order of their earliest time.
+func (s *PCTScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	next := -1
+	for _, i := range enabled {
+		event := pending[i]
+		if _, ok := s.priorities[event.To]; !ok {
+			s.priorities[event.To] = float64(s.depth) + s.rand.Float64()
+		}
+		if next == -1 {
+			next = i
+			continue
+		}
+		priority, nextPriority := s.priorities[event.To], s.priorities[pending[next].To]
+		if priority > nextPriority || (event.To == pending[next].To && event.Earliest < pending[next].Earliest) {
+			next = i
+		}
+	}
+	s.step++
+	if priority, ok := s.changePoints[s.step]; ok {
+		s.priorities[pending[next].To] = priority
+	}
+	return next, pending[next].Earliest
+}
+
+// BugProbability returns 1 / (n * k^(d-1)), where n is the number of nodes that received events,
+// k is the expected number of steps and d is the bug depth of the scheduler.
+func (s *PCTScheduler) BugProbability() float64 {
+	nodes := len(s.priorities)
+	if nodes == 0 {
+		nodes = 1
+	}
+	return 1 / (float64(nodes) * math.Pow(float64(s.steps), float64(s.depth-1)))
+}
+
+// earliestFirst returns the enabled event with the earliest time, breaking ties by the order the events were created.
+func earliestFirst(pending []PendingEvent, enabled []int) int {
+	next := enabled[0]
+	for _, i := range enabled {
+		if pending[i].Earliest < pending[next].Earliest || (pending[i].Earliest == pending[next].Earliest && pending[i].Seq < pending[next].Seq) {
+			next = i
+		}
+	}
+	return next
+}
+
+// RoundRobinScheduler is a Scheduler that takes turns between the nodes of the simulation.
+//
+// Nodes are visited in lexicographic order of their addresses, and each node processes its enabled event
+// with the earliest time when it is its turn. Nodes without enabled events are skipped.
+type RoundRobinScheduler struct {
+	last Address
+}
+
+// NewRoundRobinScheduler creates a new RoundRobinScheduler.
+func NewRoundRobinScheduler() *RoundRobinScheduler {
+	return &RoundRobinScheduler{}
+}
+
+// Next returns the earliest enabled event of the next node in turn, at its earliest time.
+func (s *RoundRobinScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	next := -1
+	for _, i := range enabled {
+		to, best := pending[i].To, Address("")
+		if next != -1 {
+			best = pending[next].To
+		}
+		switch {
+		case next == -1:
+			next = i
+		case (to > s.last) != (best > s.last):
+			if to > s.last {
+				next = i
+			}
+		case to != best:
+			if to < best {
+				next = i
+			}
+		case pending[i].Earliest < pending[next].Earliest:
+			next = i
+		}
+	}
+	s.last = pending[next].To
+	return next, pending[next].Earliest
+}
+
+// DelayBoundedScheduler is a Scheduler that explores schedules within a bound on the number of delays.
+//
+// Without delays it processes the enabled event with the earliest time. At up to delays randomly chosen
+// steps, it delays that event and processes the next one in the same order instead. Bugs that need few
+// delays to be triggered are found by runs with a small bound, regardless of the length of the run.
+type DelayBoundedScheduler struct {
+	step   int
+	delays map[int]bool
+}
+
+// NewDelayBoundedScheduler creates a new DelayBoundedScheduler with the given seed, number of delays and expected number of steps.
+//
+// If steps is not positive, DefaultPCTSteps is used.
+func NewDelayBoundedScheduler(seed int64, delays, steps int) *DelayBoundedScheduler {
+	if steps <= 0 {
+		steps = DefaultPCTSteps
+	}
+	r := rand.New(rand.NewSource(seed))
+	s := &DelayBoundedScheduler{delays: make(map[int]bool)}
+	for i := 0; i < delays && i < steps; i++ {
+		step := r.Intn(steps) + 1
+		for s.delays[step] {
+			step = r.Intn(steps) + 1
+		}
+		s.delays[step] = true
+	}
+	return s
+}
+
+// Next returns the enabled event with the earliest time, or the next one at a delay step.
+func (s *DelayBoundedScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	s.step++
+	next := earliestFirst(pending, enabled)
+	if s.delays[s.step] && len(enabled) > 1 {
+		rest := make([]int, 0, len(enabled)-1)
+		for _, i := range enabled {
+			if i != next {
+				rest = append(rest, i)
+			}
+		}
+		next = earliestFirst(pending, rest)
+	}
+	return next, pending[next].Earliest
+}
+
+// AdversaryScheduler is a Scheduler that delays the events chosen by an adversary for as long as possible.
+//
+// Events that are not delayed are scheduled by another scheduler. A delayed event is only processed once
+// no other event can be processed before its latest time, and it is then processed at its latest time.
+type AdversaryScheduler struct {
+	scheduler Scheduler
+	delay     func(event PendingEvent) bool
+}
+
+// NewAdversaryScheduler creates a new AdversaryScheduler that delays the events for which delay returns true
+// and schedules every other event with the given scheduler.
+func NewAdversaryScheduler(scheduler Scheduler, delay func(event PendingEvent) bool) *AdversaryScheduler {
+	return &AdversaryScheduler{
+		scheduler: scheduler,
+		delay:     delay,
+	}
+}
+
+// Next returns the event chosen by the scheduler among the events that are not delayed, or the delayed
+// event with the earliest latest time if it would otherwise miss it.
+func (s *AdversaryScheduler) Next(now time.Duration, pending []PendingEvent, enabled []int) (int, time.Duration) {
+	delayed := -1
+	others := make([]PendingEvent, 0, len(pending))
+	indices := make([]int, 0, len(pending))
+	for i, event := range pending {
+		if !s.delay(event) {
+			others = append(others, event)
+			indices = append(indices, i)
+		} else if delayed == -1 || event.Latest < pending[delayed].Latest {
+			delayed = i
+		}
+	}
+	otherEnabled := make([]int, 0, len(enabled))
+	for j, i := range indices {
+		for _, e := range enabled {
+			if e == i {
+				otherEnabled = append(otherEnabled, j)
+			}
+		}
+	}
+	if len(otherEnabled) > 0 {
+		j, at := s.scheduler.Next(now, others, otherEnabled)
+		if at < others[j].Earliest {
+			at = others[j].Earliest
+		}
+		if delayed == -1 || at <= pending[delayed].Latest {
+			return indices[j], at
+		}
+	}
+	return delayed, pending[delayed].Latest
+}
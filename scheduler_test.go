@@ -0,0 +1,208 @@
+package disse_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// pendingMessages returns count pending messages for each address, due one millisecond apart.
+func pendingMessages(count int, latest time.Duration, addresses ...ds.Address) []ds.PendingEvent {
+	pending := make([]ds.PendingEvent, 0)
+	for i := 0; i < count; i++ {
+		for _, address := range addresses {
+			pending = append(pending, ds.PendingEvent{
+				Kind:     ds.HandleMessageEvent,
+				To:       address,
+				Earliest: time.Duration(i+1) * time.Millisecond,
+				Latest:   latest,
+				Seq:      uint64(len(pending) + 1),
+			})
+		}
+	}
+	return pending
+}
+
+// enabledEvents returns the indices of the pending events that are due before every latest time, like a simulation.
+func enabledEvents(pending []ds.PendingEvent) []int {
+	deadline := time.Duration(math.MaxInt64)
+	for _, event := range pending {
+		if event.Latest < deadline {
+			deadline = event.Latest
+		}
+	}
+	enabled := make([]int, 0)
+	for i, event := range pending {
+		if event.Earliest <= deadline {
+			enabled = append(enabled, i)
+		}
+	}
+	return enabled
+}
+
+// scheduled is an event chosen by a scheduler and the time it was chosen for.
+type scheduled struct {
+	event ds.PendingEvent
+	at    time.Duration
+	// earliest is true if the event was the enabled event with the earliest time.
+	earliest bool
+}
+
+// drain asks the scheduler for the next event until no events are pending.
+func drain(t *testing.T, scheduler ds.Scheduler, pending []ds.PendingEvent) []scheduled {
+	t.Helper()
+	pending = append([]ds.PendingEvent(nil), pending...)
+	order := make([]scheduled, 0, len(pending))
+	for len(pending) > 0 {
+		enabled := enabledEvents(pending)
+		i, at := scheduler.Next(0, pending, enabled)
+		found := false
+		earliest := true
+		for _, j := range enabled {
+			found = found || j == i
+			if pending[j].Earliest < pending[i].Earliest || (pending[j].Earliest == pending[i].Earliest && pending[j].Seq < pending[i].Seq) {
+				earliest = false
+			}
+		}
+		if !found {
+			t.Fatalf("scheduler chose %v, which is not enabled", pending[i])
+		}
+		order = append(order, scheduled{pending[i], at, earliest})
+		pending = append(pending[:i:i], pending[i+1:]...)
+	}
+	return order
+}
+
+// runs returns the addresses of the scheduled events with consecutive repeats removed.
+func runs(order []scheduled) []ds.Address {
+	addresses := make([]ds.Address, 0)
+	for _, s := range order {
+		if len(addresses) == 0 || addresses[len(addresses)-1] != s.event.To {
+			addresses = append(addresses, s.event.To)
+		}
+	}
+	return addresses
+}
+
+func TestPCTSchedulerPrioritizesNodes(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		order := drain(t, ds.NewPCTScheduler(seed, 1, 10), pendingMessages(4, time.Second, "a", "b", "c"))
+		if got := runs(order); len(got) != 3 {
+			t.Errorf("seed %d processed nodes in the order %v, want every node once", seed, got)
+		}
+		last := make(map[ds.Address]time.Duration)
+		for _, s := range order {
+			if s.event.Earliest < last[s.event.To] {
+				t.Errorf("seed %d processed %v after an event due at %v", seed, s.event, last[s.event.To])
+			}
+			last[s.event.To] = s.event.Earliest
+			if s.at != s.event.Earliest {
+				t.Errorf("seed %d processed %v at %v", seed, s.event, s.at)
+			}
+		}
+	}
+}
+
+func TestPCTSchedulerChangePoints(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		// With depth 3 and 2 steps, the nodes of both steps are lowered below the third node,
+		// and to different priorities.
+		order := drain(t, ds.NewPCTScheduler(seed, 3, 2), pendingMessages(4, time.Second, "a", "b", "c"))
+		got := runs(order)
+		if len(got) != 5 || got[0] == got[1] || got[3] != got[0] && got[3] != got[1] || got[4] != got[0] && got[4] != got[1] {
+			t.Errorf("seed %d processed nodes in the order %v, want x y z and then x and y in any order", seed, got)
+		}
+	}
+}
+
+func TestPCTSchedulerBugProbability(t *testing.T) {
+	s := ds.NewPCTScheduler(1, 3, 10)
+	if got, want := s.BugProbability(), 1.0/100; got != want {
+		t.Errorf("BugProbability() before any event = %v, want %v", got, want)
+	}
+	drain(t, s, pendingMessages(2, time.Second, "a", "b", "c"))
+	if got, want := s.BugProbability(), 1.0/(3*10*10); got != want {
+		t.Errorf("BugProbability() = %v, want %v", got, want)
+	}
+
+	result, err := ds.RandomTest(func(sim *ds.LocalSimulation) error {
+		sim.AddNode(newPingNode(sim, "a", "b"))
+		sim.AddNode(newPingNode(sim, "b"))
+		return nil
+	}, &ds.RandomTestOptions{
+		Seeds:        3,
+		NewScheduler: func(seed int64) ds.Scheduler { return ds.NewPCTScheduler(seed, 2, 10) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1 - math.Pow(1-1.0/(2*10), 3); math.Abs(result.BugProbability-want) > 1e-9 {
+		t.Errorf("BugProbability = %v, want %v", result.BugProbability, want)
+	}
+}
+
+func TestRoundRobinSchedulerTakesTurns(t *testing.T) {
+	pending := append(pendingMessages(2, time.Second, "a", "c"), pendingMessages(1, time.Second, "b")...)
+	order := drain(t, ds.NewRoundRobinScheduler(), pending)
+	got := runs(order)
+	want := []ds.Address{"a", "b", "c", "a", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("processed nodes in the order %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("processed nodes in the order %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDelayBoundedSchedulerDelayBudget(t *testing.T) {
+	for _, delays := range []int{0, 1, 3} {
+		for seed := int64(0); seed < 10; seed++ {
+			order := drain(t, ds.NewDelayBoundedScheduler(seed, delays, 12), pendingMessages(4, time.Second, "a", "b", "c"))
+			used := 0
+			for _, s := range order {
+				if !s.earliest {
+					used++
+				}
+			}
+			if used > delays {
+				t.Errorf("seed %d delayed %d events with a budget of %d", seed, used, delays)
+			}
+			if delays > 0 && used == 0 {
+				t.Errorf("seed %d delayed no events with a budget of %d", seed, delays)
+			}
+		}
+	}
+}
+
+func TestAdversarySchedulerReleasesDelayedEventsAtLatest(t *testing.T) {
+	pending := []ds.PendingEvent{
+		{Kind: ds.HandleMessageEvent, To: "x", Earliest: 1 * time.Millisecond, Latest: 5 * time.Millisecond, Seq: 1},
+		{Kind: ds.HandleMessageEvent, To: "a", Earliest: 1 * time.Millisecond, Latest: 5 * time.Millisecond, Seq: 2},
+		{Kind: ds.HandleMessageEvent, To: "a", Earliest: 2 * time.Millisecond, Latest: 6 * time.Millisecond, Seq: 3},
+		{Kind: ds.HandleMessageEvent, To: "b", Earliest: 7 * time.Millisecond, Latest: 8 * time.Millisecond, Seq: 4},
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		adversary := ds.NewAdversaryScheduler(ds.NewRandomScheduler(seed), func(event ds.PendingEvent) bool {
+			return event.To == "x"
+		})
+		order := drain(t, adversary, pending)
+		for i, s := range order {
+			if s.event.To != "x" {
+				continue
+			}
+			if s.at != s.event.Latest {
+				t.Errorf("seed %d released %v at %v, want %v", seed, s.event, s.at, s.event.Latest)
+			}
+			if i != len(order)-2 && i != len(order)-3 {
+				t.Errorf("seed %d released %v as event %d", seed, s.event, i)
+			}
+		}
+		if last := order[len(order)-1].event; last.To != "b" {
+			t.Errorf("seed %d processed %v last, want the event due after the delayed event", seed, last)
+		}
+	}
+}
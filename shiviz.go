@@ -0,0 +1,116 @@
+package disse
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ShiVizRegex is the regular expression that ShiViz uses to parse the logs written by a ShiVizLogger.
+const ShiVizRegex = `(?<host>\S*) (?<clock>{.*})\n(?<event>.*)`
+
+// shiVizDataLength is the maximum length of the data of a message, timer or interrupt in the description of an event.
+const shiVizDataLength = 64
+
+// ShiVizLogger is a Logger implementation that logs events in the ShiViz input format.
+//
+// Each event is written as the host at which it occurred and its vector clock on one line, followed by a
+// description of the event on the next line. The file starts with ShiVizRegex and an empty line, so it can be
+// uploaded to ShiViz as is. Hosts are top level nodes.
+//
+// Only the events that advance the vector clock of a node are logged, which are sending and handling messages
+// and handling timers and interrupts. The clock of the logger must be a LogicalClocker that tracks logical clocks,
+// such as a LocalSimulation with LogicalClocks set in its options, otherwise no events are logged.
+type ShiVizLogger struct {
+	clock  LogicalClocker
+	logger *log.Logger
+}
+
+// NewShiVizLogger creates a new ShiVizLogger that logs to the given file and reads vector clocks from the given clock.
+func NewShiVizLogger(logPath string, clock LogicalClocker) (*ShiVizLogger, error) {
+	const (
+		prefix = ""
+		flag   = 0
+	)
+	logfile, err := os.Create(logPath)
+	if err != nil {
+		return nil, err
+	}
+	l := &ShiVizLogger{
+		clock:  clock,
+		logger: log.New(logfile, prefix, flag),
+	}
+	l.logger.Println(ShiVizRegex)
+	l.logger.Println()
+	return l, nil
+}
+
+// log writes an event that occurred at the given node with the given description.
+func (l *ShiVizLogger) log(node Address, format string, args ...any) {
+	_, vector := l.clock.LogicalClock(node)
+	if len(vector) == 0 {
+		return
+	}
+	clock, err := json.Marshal(vector)
+	if err != nil {
+		return
+	}
+	description := strings.ReplaceAll(fmt.Sprintf(format, args...), "\n", " ")
+	l.logger.Printf("%v %s\n%v\n", node.GetRoot(), clock, description)
+}
+
+// shiVizSummary returns a short description of the type and data of a message, timer or interrupt.
+func shiVizSummary(eventType any, data any) string {
+	if data == nil {
+		return fmt.Sprint(eventType)
+	}
+	s := fmt.Sprint(data)
+	if len(s) > shiVizDataLength {
+		s = s[:shiVizDataLength-3] + "..."
+	}
+	return fmt.Sprintf("%v(%v)", eventType, s)
+}
+
+// LogSimulationState is called when the simulation state changes.
+func (l *ShiVizLogger) LogSimulationState(sim Simulation) {}
+
+// LogNodeState is called when the state of a node changes.
+func (l *ShiVizLogger) LogNodeState(node Node) {}
+
+// LogSendMessage is called when a message is sent.
+func (l *ShiVizLogger) LogSendMessage(from, to Address, message Message) {
+	l.log(from, "send %v to %v", shiVizSummary(message.Type, message.Data), to)
+}
+
+// LogHandleMessage is called when a message is handled.
+func (l *ShiVizLogger) LogHandleMessage(from, to Address, message Message) {
+	l.log(to, "receive %v from %v", shiVizSummary(message.Type, message.Data), from)
+}
+
+// LogDropMessage is called when a message is dropped.
+func (l *ShiVizLogger) LogDropMessage(from, to Address, message Message) {}
+
+// LogSetTimer is called when a timer is set.
+func (l *ShiVizLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {}
+
+// LogHandleTimer is called when a timer is handled.
+func (l *ShiVizLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	l.log(to, "timer %v after %v", shiVizSummary(timer.Type, timer.Data), duration)
+}
+
+// LogDropTimer is called when a timer is dropped.
+func (l *ShiVizLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {}
+
+// LogSendInterrupt is called when an interrupt is sent.
+func (l *ShiVizLogger) LogSendInterrupt(from, to Address, interrupt Interrupt) {}
+
+// LogHandleInterrupt is called when an interrupt is handled.
+func (l *ShiVizLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	l.log(to, "interrupt %v from %v", shiVizSummary(interrupt.Type, interrupt.Data), from)
+}
+
+// LogDropInterrupt is called when an interrupt is dropped.
+func (l *ShiVizLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {}
@@ -0,0 +1,114 @@
+package disse_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// fixedClocks is a LogicalClocker that returns the same vector clock for every node.
+type fixedClocks ds.VectorClock
+
+func (c fixedClocks) LogicalClock(address ds.Address) (uint64, ds.VectorClock) {
+	return 0, ds.VectorClock(c).Copy()
+}
+
+// readShiViz returns the events written to a ShiViz log, after the regular expression and the empty line.
+func readShiViz(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := ds.ShiVizRegex + "\n\n"
+	if !strings.HasPrefix(string(data), header) {
+		t.Fatalf("log starts with %q, want %q", data, header)
+	}
+	return strings.TrimPrefix(string(data), header)
+}
+
+func TestShiVizLogger(t *testing.T) {
+	options := testOptions()
+	options.LogicalClocks = true
+	sim, _, _ := newPingSimulation(options)
+	path := filepath.Join(t.TempDir(), "shiviz.log")
+	logger, err := ds.NewShiVizLogger(path, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.AddLogger(logger)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `a {"a":1}
+send Ping to b
+b {"a":1,"b":1}
+receive Ping from a
+b {"a":1,"b":2}
+send Pong to a
+a {"a":2,"b":2}
+receive Pong from b
+`
+	if got := readShiViz(t, path); got != want {
+		t.Errorf("ShiViz log =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestShiVizLoggerDescriptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		clock fixedClocks
+		log   func(l *ds.ShiVizLogger)
+		want  string
+	}{
+		{
+			name:  "data",
+			clock: fixedClocks{"a": 1},
+			log: func(l *ds.ShiVizLogger) {
+				l.LogSendMessage("a.sub", "b", ds.NewMessage(ping, 42))
+			},
+			want: "a {\"a\":1}\nsend Ping(42) to b\n",
+		},
+		{
+			name:  "long data on more than one line",
+			clock: fixedClocks{"b": 2},
+			log: func(l *ds.ShiVizLogger) {
+				l.LogHandleTimer("b", ds.NewTimer(next, "first\n"+strings.Repeat("x", 100)), 0)
+			},
+			want: "b {\"b\":2}\ntimer Next(first " + strings.Repeat("x", 55) + "...) after 0s\n",
+		},
+		{
+			name:  "interrupt",
+			clock: fixedClocks{"b": 1},
+			log: func(l *ds.ShiVizLogger) {
+				l.LogHandleInterrupt("a", "b", ds.NewInterrupt(ds.StopInterrupt, nil))
+			},
+			want: "b {\"b\":1}\ninterrupt " + string(ds.StopInterrupt) + " from a\n",
+		},
+		{
+			name:  "without logical clocks",
+			clock: fixedClocks{},
+			log: func(l *ds.ShiVizLogger) {
+				l.LogSendMessage("a", "b", ds.NewMessage(ping, nil))
+			},
+			want: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shiviz.log")
+			logger, err := ds.NewShiVizLogger(path, test.clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.log(logger)
+			if got := readShiViz(t, path); got != test.want {
+				t.Errorf("ShiViz log = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
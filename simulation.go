@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -33,14 +37,21 @@ type Simulation interface {
 
 // LocalSimulationOptions is used to set the options for the simulation.
 type LocalSimulationOptions struct {
-	MinLatency   time.Duration
-	MaxLatency   time.Duration
-	Duration     time.Duration
-	BufferSize   int
-	DebugLogPath string
-	UmlLogPath   string
-	JavaPath     string
-	PlantumlPath string
+	MinLatency       time.Duration
+	MaxLatency       time.Duration
+	Duration         time.Duration
+	BufferSize       int
+	DebugLogPath     string
+	UmlLogPath       string
+	SvgPath          string
+	JsonLogPath      string
+	JavaPath         string
+	PlantumlPath     string
+	ViolationLogPath string
+	MetricsAddress   string
+	MetricsPath      string
+	Scheduler        Scheduler
+	LogicalClocks    bool
 }
 
 const (
@@ -56,10 +67,16 @@ const (
 	DefaultDebugLogPath = "debug.log"
 	// DefaultUmlLogPath is the default path to the UML log.
 	DefaultUmlLogPath = "uml.log"
+	// DefaultSvgPath is the suggested path to the SVG sequence diagram, which is only drawn if SvgPath is set.
+	DefaultSvgPath = "uml.svg"
+	// DefaultJsonLogPath is the suggested path to the JSON Lines event log, which is only written if JsonLogPath is set.
+	DefaultJsonLogPath = "events.jsonl"
 	// DefaultJavaPath is the default path to the java executable.
 	DefaultJavaPath = "/usr/bin/java"
 	// DefaultPlantumlPath is the default path to the plantuml jar file.
 	DefaultPlantumlPath = "/usr/share/plantuml/plantuml.jar"
+	// DefaultViolationLogPath is the suggested path to the violation log, which is only written if ViolationLogPath is set.
+	DefaultViolationLogPath = "violation.log"
 )
 
 // LocalSimulation sets up and runs the distributed system simulation locally using shared memory.
@@ -67,16 +84,49 @@ type LocalSimulation struct {
 	options        *LocalSimulationOptions
 	nodes          map[Address]Node
 	wg             *sync.WaitGroup
-	messageQueue   map[Address]chan MessageTriplet
+	messageQueue   map[Address]chan queuedMessage
 	timerQueue     map[Address]chan TimerTriplet
 	interruptQueue map[Address]chan InterruptTriplet
 	loggers        []Logger
+	metrics        *MetricsLogger
+	jsonLogger     *JsonLogger
+	interceptors   []Interceptor
+	interceptMu    sync.Mutex
 	state          SimulationState
+	mu             sync.Mutex
+	cancel         context.CancelFunc
+	start          time.Time
+	invariants     []namedInvariant
+	properties     []EventuallyProperty
+	trace          *TraceLogger
+	violation      violation
+	controlled     bool
+	clock          time.Duration
+	pending        []PendingEvent
+	seq            uint64
+	quiet          bool
+	seed           int64
+	ids            *idGenerator
+	clocksMu       sync.Mutex
+	lamport        map[Address]uint64
+	vector         map[Address]VectorClock
+}
+
+// violation is an error that can be written to a violation log.
+type violation interface {
+	error
+	WriteFile(path string) error
 }
 
 // NewLocalSimulation creates a new simulation with the given options.
 //
-// If the options are nil, the default options are used.
+// If the options are nil, the default options are used, which write no JSON log, SVG diagram or violation log.
+// The debug, UML and JSON loggers are only created if their log paths are set, and the SVG diagram
+// is only drawn from the trace of the simulation if its path is set. Metrics are always collected,
+// but are only served or written if the metrics address or path is set. If the metrics are served,
+// the nodes of a simulation that runs in real time handle events one at a time, as when it has invariants.
+// If LogicalClocks is set, every message is stamped with the Lamport and vector clocks of its sender,
+// which are merged into the clocks of the receiver when the message is handled.
 func NewLocalSimulation(options *LocalSimulationOptions) *LocalSimulation {
 	if options == nil {
 		options = &LocalSimulationOptions{
@@ -94,35 +144,76 @@ func NewLocalSimulation(options *LocalSimulationOptions) *LocalSimulation {
 		options:        options,
 		wg:             &sync.WaitGroup{},
 		nodes:          make(map[Address]Node),
-		messageQueue:   make(map[Address]chan MessageTriplet),
+		messageQueue:   make(map[Address]chan queuedMessage),
 		timerQueue:     make(map[Address]chan TimerTriplet),
 		interruptQueue: make(map[Address]chan InterruptTriplet),
 		loggers:        make([]Logger, 0),
 		state:          SimulationNotStarted,
+		lamport:        make(map[Address]uint64),
+		vector:         make(map[Address]VectorClock),
 	}
 
-	debugLogger, err := NewDebugLogger(options.DebugLogPath)
-	if err != nil {
-		log.Println("failed to create debug logger:", err)
-	} else {
-		sim.AddLogger(debugLogger)
+	if options.DebugLogPath != "" {
+		debugLogger, err := NewDebugLogger(options.DebugLogPath)
+		if err != nil {
+			log.Println("failed to create debug logger:", err)
+		} else {
+			sim.AddLogger(debugLogger)
+		}
 	}
 
-	umlLogger, err := NewUmlLogger(options.UmlLogPath)
-	if err != nil {
-		log.Println("failed to create UML logger:", err)
-	} else {
-		sim.AddLogger(umlLogger)
+	if options.UmlLogPath != "" {
+		umlLogger, err := NewUmlLogger(options.UmlLogPath)
+		if err != nil {
+			log.Println("failed to create UML logger:", err)
+		} else {
+			sim.AddLogger(umlLogger)
+		}
+	}
+
+	if options.SvgPath != "" {
+		sim.recorder()
 	}
 
+	if options.JsonLogPath != "" {
+		jsonLogger, err := NewJsonLogger(options.JsonLogPath, sim)
+		if err != nil {
+			log.Println("failed to create JSON logger:", err)
+		} else {
+			sim.jsonLogger = jsonLogger
+			sim.AddLogger(jsonLogger)
+		}
+	}
+
+	sim.metrics = NewMetricsLogger(sim)
+	sim.AddLogger(sim.metrics)
+
 	return sim
 }
 
+// Now returns the time elapsed since the simulation started running.
+//
+// When the simulation is driven step by step, this is the virtual time of the simulation instead.
+func (s *LocalSimulation) Now() time.Duration {
+	if s.controlled {
+		return s.clock
+	}
+	if s.start.IsZero() {
+		return 0
+	}
+	return time.Since(s.start)
+}
+
 // GetState returns the state of the simulation.
 func (s *LocalSimulation) GetState() SimulationState {
 	return s.state
 }
 
+// GetNodes returns the top level nodes of the simulation.
+func (s *LocalSimulation) GetNodes() map[Address]Node {
+	return s.nodes
+}
+
 // AddNode adds a node to the simulation.
 func (s *LocalSimulation) AddNode(node Node) error {
 	address := node.GetAddress()
@@ -130,7 +221,7 @@ func (s *LocalSimulation) AddNode(node Node) error {
 		return fmt.Errorf("node with address %v already exists in simulation", address)
 	}
 	s.nodes[address] = node
-	s.messageQueue[address] = make(chan MessageTriplet, s.options.BufferSize)
+	s.messageQueue[address] = make(chan queuedMessage, s.options.BufferSize)
 	s.timerQueue[address] = make(chan TimerTriplet, s.options.BufferSize)
 	s.interruptQueue[address] = make(chan InterruptTriplet, s.options.BufferSize)
 	return nil
@@ -159,62 +250,168 @@ func (s *LocalSimulation) RemoveLogger(logger Logger) {
 	}
 }
 
+// SetSeed sets the seed the logged ids of messages, timers and interrupts are generated from by RunScheduled.
+func (s *LocalSimulation) SetSeed(seed int64) {
+	s.seed = seed
+}
+
 // Run runs the simulation.
+//
+// If an invariant is violated, the simulation is stopped early. Otherwise, eventual properties are checked
+// once the simulation finishes. The violation found, if any, is returned by Violation.
+//
+// If the options of the simulation have a Scheduler, the simulation is run in virtual time with it, as done by RunScheduled.
 func (s *LocalSimulation) Run() {
+	if s.options.Scheduler != nil {
+		s.RunScheduled(s.options.Scheduler)
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), s.options.Duration)
 	defer cancel()
+	s.cancel = cancel
+	s.start = time.Now()
+	defer s.serveMetrics()()
 	s.startSim(ctx)
 	<-ctx.Done()
 	s.stopSim()
+	s.finish()
+}
+
+// Violation returns the violation found by the last run of the simulation, or nil if there was none.
+//
+// It is an *InvariantViolation if an invariant was violated, or a *LivenessViolation if an eventual property
+// was not satisfied.
+func (s *LocalSimulation) Violation() error {
+	if s.violation == nil {
+		return nil
+	}
+	return s.violation
+}
+
+// RunScheduled runs the simulation step by step in virtual time, letting the scheduler decide
+// which pending message, timer or interrupt is processed next and when.
+//
+// The simulation ends when there are no pending events left before the duration of the simulation.
+// The ids of messages, timers and interrupts are logged as ids generated from the seed of the simulation,
+// so the logs of the run are reproducible if the scheduler is. The generated ids are only kept until the run ends.
+//
+// Violations are found in the same way as by Run, and the violation found, if any, is also returned.
+func (s *LocalSimulation) RunScheduled(scheduler Scheduler) error {
+	s.ids = newIdGenerator(s.seed)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.cancel = cancel
+	s.controlled = true
+	defer s.serveMetrics()()
+	s.startSim(ctx)
+	for s.violation == nil {
+		enabled := s.enabled()
+		if len(enabled) == 0 {
+			break
+		}
+		i, at := scheduler.Next(s.clock, s.pending, enabled)
+		if at < s.pending[i].Earliest {
+			at = s.pending[i].Earliest
+		}
+		if at > s.options.Duration {
+			break
+		}
+		s.execute(ctx, i, at)
+	}
+	if s.violation == nil {
+		s.mu.Lock()
+		s.clock = s.options.Duration
+		s.mu.Unlock()
+	}
+	s.stopSim()
+	err := s.finish()
+	s.ids = nil
+	return err
+}
+
+// finish generates the UML image, draws the SVG diagram and writes the metrics of a finished simulation,
+// reports any error writing the JSON log, and reports any violation.
+func (s *LocalSimulation) finish() error {
 	err := s.generateUmlImage()
 	if err != nil {
 		log.Println("failed to generate UML image:", err)
 	}
+	if err := s.writeSvg(); err != nil {
+		log.Println("failed to write SVG diagram:", err)
+	}
+	if err := s.writeMetrics(); err != nil {
+		log.Println("failed to write metrics:", err)
+	}
+	if s.jsonLogger != nil && s.jsonLogger.Err() != nil {
+		log.Println("failed to write JSON log:", s.jsonLogger.Err())
+	}
+	if s.violation == nil {
+		s.violation = s.checkEventually()
+	}
+	return s.reportViolation()
+}
+
+// reportViolation logs the violation of the simulation, if any, and writes it to the violation log.
+func (s *LocalSimulation) reportViolation() error {
+	if s.violation == nil {
+		return nil
+	}
+	if !s.quiet {
+		log.Println(s.violation)
+	}
+	if s.options.ViolationLogPath != "" {
+		if err := s.violation.WriteFile(s.options.ViolationLogPath); err != nil {
+			log.Println("failed to write violation log:", err)
+		}
+	}
+	return s.violation
 }
 
 // initNode initializes a node and all it's sub nodes.
 func (s *LocalSimulation) initNode(ctx context.Context, node Node) {
 	node.Init(ctx)
 	s.LogNodeState(node)
-	for _, subNode := range node.GetSubNodes() {
-		s.initNode(ctx, subNode)
+	subNodes := node.GetSubNodes()
+	for _, address := range sortedAddresses(subNodes) {
+		s.initNode(ctx, subNodes[address])
 	}
 }
 
 // startSim starts the simulation by initializing all nodes and sub nodes.
+//
+// When the simulation is driven step by step, no goroutines are started for the nodes.
 func (s *LocalSimulation) startSim(ctx context.Context) {
 	s.LogSimulationState()
-	for _, node := range s.nodes {
-		s.initNode(ctx, node)
-		s.wg.Add(1)
-		go func(_node Address) {
-			for {
-				select {
-				case <-ctx.Done():
-					s.wg.Done()
-					return
-				case mt := <-s.messageQueue[_node]:
-					if handled := s.handleMessage(ctx, mt); !handled {
-						s.dropMessage(ctx, mt)
-					}
-				case tt := <-s.timerQueue[_node]:
-					if handled := s.handleTimer(ctx, tt); !handled {
-						s.dropTimer(ctx, tt)
-					}
-				case it := <-s.interruptQueue[_node]:
-					if handled := s.handleInterrupt(ctx, it); !handled {
-						s.dropInterrupt(ctx, it)
-					} else {
-						s.LogNodeState(s.nodes[_node])
-					}
-				}
-			}
-		}(node.GetAddress())
+	for _, address := range sortedAddresses(s.nodes) {
+		s.initNode(ctx, s.nodes[address])
+		if !s.controlled {
+			s.startNode(ctx, address)
+		}
 	}
 	s.state = SimulationRunning
 	s.LogSimulationState()
 }
 
+// startNode starts a goroutine that processes the messages, timers and interrupts of a node until the context is done.
+func (s *LocalSimulation) startNode(ctx context.Context, address Address) {
+	s.wg.Add(1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				s.wg.Done()
+				return
+			case qm := <-s.messageQueue[address]:
+				s.processMessage(ctx, qm.mt, !qm.delivered)
+			case tt := <-s.timerQueue[address]:
+				s.processTimer(ctx, tt)
+			case it := <-s.interruptQueue[address]:
+				s.processInterrupt(ctx, it)
+			}
+		}
+	}()
+}
+
 // stopSim stops the simulation by closing the message and timer queues and waiting for all nodes to stop doing work.
 func (s *LocalSimulation) stopSim() {
 	s.wg.Wait()
@@ -223,31 +420,171 @@ func (s *LocalSimulation) stopSim() {
 }
 
 // generateUmlImage generates a UML image of the simulation using PlantUML (requires java).
+//
+// Nothing is generated if the simulation has no UML log. If java or PlantUML is not installed and the
+// simulation draws its own SVG diagram, the image is skipped instead of failing.
 func (s *LocalSimulation) generateUmlImage() error {
+	if s.options.UmlLogPath == "" {
+		return nil
+	}
+	paths, err := s.writeStateDiagrams()
+	if err != nil {
+		return err
+	}
 	javaPath := s.options.JavaPath
 	plantumlPath := s.options.PlantumlPath
 	if javaPath == "" || plantumlPath == "" {
 		return fmt.Errorf("javaPath or plantumlPath not set. UML image not generated")
 	}
-	if s.options.UmlLogPath == "" {
-		return fmt.Errorf("umlLogPath not set. UML image not generated")
+	if s.options.SvgPath != "" && !plantumlInstalled(javaPath, plantumlPath) {
+		return nil
 	}
-	cmd := exec.Command(javaPath, "-jar", plantumlPath, s.options.UmlLogPath)
-	err := cmd.Run()
+	cmd := exec.Command(javaPath, append([]string{"-jar", plantumlPath, s.options.UmlLogPath}, paths...)...)
+	err = cmd.Run()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// plantumlInstalled returns true if the java executable and the PlantUML jar file exist.
+func plantumlInstalled(javaPath, plantumlPath string) bool {
+	if _, err := exec.LookPath(javaPath); err != nil {
+		return false
+	}
+	_, err := os.Stat(plantumlPath)
+	return err == nil
+}
+
+// writeStateDiagrams writes the state diagram of every node that is a finite state machine next to the UML log,
+// and returns the paths of the diagrams.
+//
+// The diagram of a node with address a is written to "uml-a-fsm.log" for a UML log at "uml.log".
+func (s *LocalSimulation) writeStateDiagrams() ([]string, error) {
+	nodes := make(map[Address]Node)
+	for _, node := range s.nodes {
+		flattenNodes(node, nodes)
+	}
+	ext := filepath.Ext(s.options.UmlLogPath)
+	base := strings.TrimSuffix(s.options.UmlLogPath, ext)
+	paths := make([]string, 0)
+	for _, address := range sortedAddresses(nodes) {
+		fsm, ok := nodes[address].(interface{ WriteStateDiagram(string) error })
+		if !ok {
+			continue
+		}
+		path := fmt.Sprintf("%v-%v-fsm%v", base, address, ext)
+		if err := fsm.WriteStateDiagram(path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// lock locks the simulation if it has invariants or serves its metrics, and returns the function that unlocks it.
+//
+// While such a simulation is locked, no other message, timer, interrupt or wake up is processed, so invariants
+// and metrics requests always observe a consistent state. Other simulations are never locked, so the
+// nodes of a simulation that runs in real time handle events concurrently.
+func (s *LocalSimulation) lock() (unlock func()) {
+	if len(s.invariants) == 0 && s.options.MetricsAddress == "" {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// processMessage handles or drops a message and then checks the invariants of the simulation.
+//
+// If intercept is true, the message is first passed through the interceptors, as done by deliverMessage.
+func (s *LocalSimulation) processMessage(ctx context.Context, mt MessageTriplet, intercept bool) {
+	defer s.lock()()
+	if s.violation != nil {
+		return
+	}
+	mark := s.traceLen()
+	s.deliverMessage(ctx, mt, intercept)
+	s.check(mark)
+}
+
+// processTimer handles or drops a timer and then checks the invariants of the simulation.
+func (s *LocalSimulation) processTimer(ctx context.Context, tt TimerTriplet) {
+	defer s.lock()()
+	if s.violation != nil {
+		return
+	}
+	mark := s.traceLen()
+	if handled := s.handleTimer(ctx, tt); !handled {
+		s.dropTimer(ctx, tt)
+	}
+	s.check(mark)
+}
+
+// processInterrupt handles or drops an interrupt and then checks the invariants of the simulation.
+func (s *LocalSimulation) processInterrupt(ctx context.Context, it InterruptTriplet) {
+	defer s.lock()()
+	if s.violation != nil {
+		return
+	}
+	mark := s.traceLen()
+	if handled := s.handleInterrupt(ctx, it); !handled {
+		s.dropInterrupt(ctx, it)
+	} else {
+		s.LogNodeState(s.nodes[it.To])
+	}
+	s.check(mark)
+}
+
+// processWake calls the function that wakes up a node and then checks the invariants of the simulation.
+func (s *LocalSimulation) processWake(wake func()) {
+	defer s.lock()()
+	if s.violation != nil {
+		return
+	}
+	mark := s.traceLen()
+	wake()
+	s.check(mark)
+}
+
+// traceLen returns the number of events recorded in the trace of the simulation.
+func (s *LocalSimulation) traceLen() int {
+	if s.trace == nil {
+		return 0
+	}
+	return s.trace.Len()
+}
+
+// check checks the invariants of the simulation and stops the simulation on the first violation.
+func (s *LocalSimulation) check(mark int) {
+	if v := s.checkInvariants(mark); v != nil {
+		s.violation = v
+		s.cancel()
+	}
+}
+
+// handleReply recursively searches a node and its sub nodes for the call that the message replies to.
+func (s *LocalSimulation) handleReply(ctx context.Context, node Node, message Message, from Address) bool {
+	if r, ok := node.(rpcNode); ok && r.handleReply(ctx, message, from) {
+		return true
+	}
+	subNodes := node.GetSubNodes()
+	for _, address := range sortedAddresses(subNodes) {
+		if s.handleReply(ctx, subNodes[address], message, from) {
+			return true
+		}
+	}
+	return false
+}
+
 // _handleMessage is a helper function for handleMessage.
 func (s *LocalSimulation) _handleMessage(ctx context.Context, node Node, message Message, from Address) bool {
 	if node.HandleMessage(ctx, message, from) {
 		return true
 	}
 	subNodes := node.GetSubNodes()
-	for _, subNode := range subNodes {
-		if s._handleMessage(ctx, subNode, message, from) {
+	for _, address := range sortedAddresses(subNodes) {
+		if s._handleMessage(ctx, subNodes[address], message, from) {
 			return true
 		}
 	}
@@ -257,14 +594,19 @@ func (s *LocalSimulation) _handleMessage(ctx context.Context, node Node, message
 // handleMessage handles a message by recursively searching a node
 // and it's sub nodes for a handler for the message.
 //
+// Replies to calls made by the node or its sub nodes are handled by the call first.
 // If the node is not running, or no handler is found, the message is dropped.
 func (s *LocalSimulation) handleMessage(ctx context.Context, mt MessageTriplet) bool {
 	node := s.nodes[mt.To]
 	if node.GetState() != Running {
 		return false
 	}
+	s.receiveClocks(mt.To, mt.Message)
 	s.LogHandleMessage(mt.From, mt.To, mt.Message)
-	return s._handleMessage(ctx, node, mt.Message, mt.From)
+	ctx = withCause(ctx, string(mt.Message.Id))
+	handled := s.handleReply(ctx, node, mt.Message, mt.From) || s._handleMessage(ctx, node, mt.Message, mt.From)
+	s.LogHandlerDone(mt.To, handled)
+	return handled
 }
 
 // dropMessage drops a message.
@@ -274,14 +616,28 @@ func (s *LocalSimulation) dropMessage(ctx context.Context, mt MessageTriplet) {
 	s.LogDropMessage(mt.From, mt.To, mt.Message)
 }
 
+// handleRpcTimeout recursively searches a node and its sub nodes for the call that the timer times out.
+func (s *LocalSimulation) handleRpcTimeout(ctx context.Context, node Node, timer Timer) bool {
+	if r, ok := node.(rpcNode); ok && r.handleRpcTimeout(ctx, timer) {
+		return true
+	}
+	subNodes := node.GetSubNodes()
+	for _, address := range sortedAddresses(subNodes) {
+		if s.handleRpcTimeout(ctx, subNodes[address], timer) {
+			return true
+		}
+	}
+	return false
+}
+
 // _handleTimer is a helper function for handleTimer.
 func (s *LocalSimulation) _handleTimer(ctx context.Context, node Node, timer Timer, duration time.Duration) bool {
 	if node.HandleTimer(ctx, timer, duration) {
 		return true
 	}
 	subNodes := node.GetSubNodes()
-	for _, subNode := range subNodes {
-		if s._handleTimer(ctx, subNode, timer, duration) {
+	for _, address := range sortedAddresses(subNodes) {
+		if s._handleTimer(ctx, subNodes[address], timer, duration) {
 			return true
 		}
 	}
@@ -291,13 +647,24 @@ func (s *LocalSimulation) _handleTimer(ctx context.Context, node Node, timer Tim
 // handleTimer handles a timer by sending it to the appropriate node.
 //
 // If the node is not running, or no handler is found, the timer is dropped.
+// Timeouts of calls are handled by the call, and are never passed to the handlers of the node,
+// so timeouts of calls that have already received a reply are ignored.
 func (s *LocalSimulation) handleTimer(ctx context.Context, tt TimerTriplet) bool {
 	node := s.nodes[tt.To]
 	if node.GetState() != Running {
 		return false
 	}
+	s.tickClocks(tt.To)
 	s.LogHandleTimer(tt.To, tt.Timer, tt.Duration)
-	return s._handleTimer(ctx, node, tt.Timer, tt.Duration)
+	ctx = withCause(ctx, string(tt.Timer.Id))
+	handled := true
+	if tt.Timer.Type == RpcTimeout {
+		s.handleRpcTimeout(ctx, node, tt.Timer)
+	} else {
+		handled = s._handleTimer(ctx, node, tt.Timer, tt.Duration)
+	}
+	s.LogHandlerDone(tt.To, handled)
+	return handled
 }
 
 // dropTimer drops a timer.
@@ -313,8 +680,8 @@ func (s *LocalSimulation) _handleInterrupt(ctx context.Context, node Node, inter
 		return true
 	}
 	subNodes := node.GetSubNodes()
-	for _, subNode := range subNodes {
-		if s._handleInterrupt(ctx, subNode, interrupt, from) {
+	for _, address := range sortedAddresses(subNodes) {
+		if s._handleInterrupt(ctx, subNodes[address], interrupt, from) {
 			return true
 		}
 	}
@@ -329,8 +696,12 @@ func (s *LocalSimulation) handleInterrupt(ctx context.Context, it InterruptTripl
 	if node.GetState() != Running {
 		return false
 	}
+	s.tickClocks(it.To)
 	s.LogHandleInterrupt(it.From, it.To, it.Interrupt)
-	return s._handleInterrupt(ctx, node, it.Interrupt, it.From)
+	ctx = withCause(ctx, string(it.Interrupt.Id))
+	handled := s._handleInterrupt(ctx, node, it.Interrupt, it.From)
+	s.LogHandlerDone(it.To, handled)
+	return handled
 }
 
 // dropInterrupt drops an interrupt.
@@ -339,3 +710,33 @@ func (s *LocalSimulation) handleInterrupt(ctx context.Context, it InterruptTripl
 func (s *LocalSimulation) dropInterrupt(ctx context.Context, it InterruptTriplet) {
 	s.LogDropInterrupt(it.From, it.To, it.Interrupt)
 }
+
+// causeKey is the key of the context value that holds the id of the message, timer or interrupt being handled.
+type causeKey struct{}
+
+// withCause returns a copy of the context for the handler of the message, timer or interrupt with the given id,
+// which becomes the parent of every message, timer and interrupt sent with the context.
+func withCause(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, causeKey{}, id)
+}
+
+// causeOf returns the id of the message, timer or interrupt whose handler the context was given to,
+// or an empty string if the context was not given to a handler.
+func causeOf(ctx context.Context) string {
+	id, _ := ctx.Value(causeKey{}).(string)
+	return id
+}
+
+// sortedAddresses returns the addresses of the given nodes in lexicographic order.
+//
+// It is used to visit nodes in a deterministic order.
+func sortedAddresses(nodes map[Address]Node) []Address {
+	addresses := make([]Address, 0, len(nodes))
+	for address := range nodes {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+	return addresses
+}
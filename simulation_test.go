@@ -0,0 +1,172 @@
+package disse_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const (
+	ping ds.MessageType = "Ping"
+	pong ds.MessageType = "Pong"
+)
+
+// pingNode sends a ping to each of its peers when it is initialized, and replies to every ping with a pong.
+type pingNode struct {
+	*ds.LocalNode
+	peers []ds.Address
+	pongs int
+}
+
+func newPingNode(sim *ds.LocalSimulation, address ds.Address, peers ...ds.Address) *pingNode {
+	return &pingNode{LocalNode: ds.NewLocalNode(sim, address), peers: peers}
+}
+
+func (n *pingNode) Init(ctx context.Context) {
+	for _, peer := range n.peers {
+		n.SendMessage(ctx, ds.NewMessage(ping, nil), peer)
+	}
+}
+
+func (n *pingNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	switch message.Type {
+	case ping:
+		n.SendMessage(ctx, ds.NewReply(message, pong, nil), from)
+		return true
+	case pong:
+		n.pongs++
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *pingNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+// testOptions returns the options of a simulation that writes no files.
+func testOptions() *ds.LocalSimulationOptions {
+	return &ds.LocalSimulationOptions{
+		MinLatency: time.Millisecond,
+		MaxLatency: 5 * time.Millisecond,
+		Duration:   100 * time.Millisecond,
+		BufferSize: ds.DefaultBufferSize,
+	}
+}
+
+// newPingSimulation creates a simulation in which a pings b.
+func newPingSimulation(options *ds.LocalSimulationOptions) (*ds.LocalSimulation, *pingNode, *pingNode) {
+	sim := ds.NewLocalSimulation(options)
+	a, b := newPingNode(sim, "a", "b"), newPingNode(sim, "b")
+	sim.AddNode(a)
+	sim.AddNode(b)
+	return sim, a, b
+}
+
+// noPongs is an invariant that is violated once a receives a pong.
+func noPongs(nodes map[ds.Address]ds.Node) error {
+	if nodes["a"].(*pingNode).pongs > 0 {
+		return errors.New("a received a pong")
+	}
+	return nil
+}
+
+func TestRunWithoutViolation(t *testing.T) {
+	sim, a, _ := newPingSimulation(testOptions())
+	sim.AddInvariant("at most one pong", func(nodes map[ds.Address]ds.Node) error {
+		if nodes["a"].(*pingNode).pongs > 1 {
+			return errors.New("a received more than one pong")
+		}
+		return nil
+	})
+	var s ds.Simulation = sim
+	s.Run()
+	if err := sim.Violation(); err != nil {
+		t.Errorf("Violation() = %v, want nil", err)
+	}
+	if a.pongs != 1 {
+		t.Errorf("a received %d pongs, want 1", a.pongs)
+	}
+}
+
+func TestRunStopsOnViolation(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	sim.AddInvariant("no pongs", noPongs)
+	sim.Run()
+
+	var violation *ds.InvariantViolation
+	if !errors.As(sim.Violation(), &violation) {
+		t.Fatalf("Violation() = %v, want an invariant violation", sim.Violation())
+	}
+	if violation.Invariant != "no pongs" || violation.Err.Error() != "a received a pong" {
+		t.Errorf("violation of %q with %v", violation.Invariant, violation.Err)
+	}
+	if violation.Event.Kind != ds.HandleMessageEvent || violation.Event.Message.Type != pong {
+		t.Errorf("violation after %v, want the handling of the pong", violation.Event)
+	}
+	if last := violation.Trace[len(violation.Trace)-1]; last.Kind != violation.Event.Kind || last.Id() != violation.Event.Id() {
+		t.Errorf("causal history ends with %v, want %v", last, violation.Event)
+	}
+	kinds := make([]ds.EventKind, 0)
+	for _, event := range violation.Trace {
+		if event.Kind != ds.NodeStateEvent {
+			kinds = append(kinds, event.Kind)
+		}
+	}
+	want := []ds.EventKind{ds.SendMessageEvent, ds.HandleMessageEvent, ds.SendMessageEvent, ds.HandleMessageEvent}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("causal history %v, want %v", kinds, want)
+	}
+}
+
+func TestRunScheduledReturnsViolation(t *testing.T) {
+	sim, _, _ := newPingSimulation(testOptions())
+	sim.AddInvariant("no pongs", noPongs)
+	err := sim.RunScheduled(ds.NewRandomScheduler(1))
+	if err == nil || err != sim.Violation() {
+		t.Errorf("RunScheduled() = %v, want the violation %v", err, sim.Violation())
+	}
+}
+
+func TestViolationLogIsOptIn(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// The default options are run in virtual time, so the default duration does not slow the test down.
+	sim, _, _ := newPingSimulation(nil)
+	sim.AddInvariant("no pongs", noPongs)
+	sim.RunScheduled(ds.NewRandomScheduler(1))
+	if _, err := os.Stat(ds.DefaultViolationLogPath); !os.IsNotExist(err) {
+		t.Errorf("violation log written with the default options")
+	}
+
+	options := testOptions()
+	options.ViolationLogPath = filepath.Join(dir, "violation.log")
+	sim, _, _ = newPingSimulation(options)
+	sim.AddInvariant("no pongs", noPongs)
+	sim.Run()
+	data, err := os.ReadFile(options.ViolationLogPath)
+	if err != nil {
+		t.Fatalf("violation log not written: %v", err)
+	}
+	for _, want := range []string{"Invariant: no pongs", "Violation: a received a pong", "Causal history:", "HandleMessage(a -> b, Ping"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("violation log does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package disse
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// svgMargin is the margin around the diagram, in pixels.
+	svgMargin = 20
+	// svgAxisWidth is the width of the time axis on the left of the diagram, in pixels.
+	svgAxisWidth = 80
+	// svgLaneWidth is the distance between the lifelines of two nodes, in pixels.
+	svgLaneWidth = 180
+	// svgHeaderHeight is the height of the boxes with the names of the nodes, in pixels.
+	svgHeaderHeight = 30
+	// svgRowHeight is the height given to every arrow when choosing the scale of the time axis, in pixels.
+	svgRowHeight = 24
+	// svgMinHeight and svgMaxHeight bound the height of the time axis, in pixels.
+	svgMinHeight = 400
+	svgMaxHeight = 8000
+	// svgLoopWidth is the width of the self loops of timers, in pixels.
+	svgLoopWidth = 30
+	// svgMinSlope is the height of an arrow that would otherwise be horizontal, in pixels.
+	svgMinSlope = 8
+	// svgTicks is the number of ticks on the time axis.
+	svgTicks = 10
+)
+
+// svgArrow is an arrow in a sequence diagram, for a message, timer or interrupt.
+type svgArrow struct {
+	id      string
+	from    Address
+	to      Address
+	label   string
+	start   time.Duration
+	end     time.Duration
+	dashed  bool
+	dropped bool
+	pending bool
+}
+
+// svgNote is a note on the lifeline of a node, for a change of its state.
+type svgNote struct {
+	node  Address
+	text  string
+	time  time.Duration
+	muted bool
+}
+
+// svgDiagram is the layout of a sequence diagram built from the events of a trace.
+type svgDiagram struct {
+	nodes  []Address
+	lanes  map[Address]int
+	arrows []svgArrow
+	notes  []svgNote
+	end    time.Duration
+	scale  float64
+}
+
+// newSvgDiagram lays out the events of a trace as a sequence diagram.
+//
+// Every message, timer and interrupt is matched with the first handle or drop event of the same id after it is sent.
+// Those that are never handled or dropped are drawn up to the end of the trace. An event that is dropped right
+// after it was handled, because the node had no handler for it, is drawn as dropped.
+func newSvgDiagram(events []Event) *svgDiagram {
+	d := &svgDiagram{
+		nodes:  make([]Address, 0),
+		lanes:  make(map[Address]int),
+		arrows: make([]svgArrow, 0),
+		notes:  make([]svgNote, 0),
+	}
+	open := make(map[string][]int)
+	handled := make(map[Address]int)
+	for _, event := range events {
+		if event.Time > d.end {
+			d.end = event.Time
+		}
+		switch event.Kind {
+		case SimulationStateEvent:
+			continue
+		case NodeStateEvent:
+			delete(handled, event.To)
+			d.lane(event.To)
+			if event.FsmState != "" {
+				d.notes = append(d.notes, svgNote{node: event.To, text: string(event.FsmState), time: event.Time})
+			} else if event.NodeState != Running {
+				d.notes = append(d.notes, svgNote{node: event.To, text: string(event.NodeState), time: event.Time, muted: true})
+			}
+			continue
+		}
+		d.lane(event.From)
+		d.lane(event.To)
+		id := event.Id()
+		switch event.Kind {
+		case SendMessageEvent, SendInterruptEvent, SetTimerEvent:
+			arrow := svgArrow{id: id, from: event.Node(), to: event.To, start: event.Time, pending: true}
+			switch event.Kind {
+			case SendMessageEvent:
+				arrow.label = string(event.Message.Type)
+				arrow.dashed = event.Message.ReplyTo != ""
+			case SendInterruptEvent:
+				arrow.label = string(event.Interrupt.Type)
+				arrow.dashed = true
+			case SetTimerEvent:
+				arrow.label = fmt.Sprintf("%v (%v)", event.Timer.Type, event.Duration)
+			}
+			open[id] = append(open[id], len(d.arrows))
+			d.arrows = append(d.arrows, arrow)
+		default:
+			dropped := event.Kind == DropMessageEvent || event.Kind == DropTimerEvent || event.Kind == DropInterruptEvent
+			last, ok := handled[event.To]
+			delete(handled, event.To)
+			if dropped && ok && d.arrows[last].id == id {
+				d.arrows[last].dropped = true
+				continue
+			}
+			indexes := open[id]
+			if len(indexes) == 0 {
+				continue
+			}
+			open[id] = indexes[1:]
+			arrow := &d.arrows[indexes[0]]
+			arrow.pending = false
+			arrow.end = event.Time
+			if event.Arrival > arrow.start && event.Arrival < event.Time {
+				arrow.end = event.Arrival
+			}
+			arrow.dropped = dropped
+			if !dropped {
+				handled[event.To] = indexes[0]
+			}
+		}
+	}
+	for i := range d.arrows {
+		if d.arrows[i].pending {
+			d.arrows[i].end = d.end
+		}
+	}
+	height := svgRowHeight * float64(len(d.arrows)+len(d.notes))
+	if height < svgMinHeight {
+		height = svgMinHeight
+	}
+	if height > svgMaxHeight {
+		height = svgMaxHeight
+	}
+	if d.end > 0 {
+		d.scale = height / float64(d.end)
+	}
+	return d
+}
+
+// lane adds a lifeline for a node if it does not have one yet.
+func (d *svgDiagram) lane(address Address) {
+	if address == "" {
+		return
+	}
+	if _, ok := d.lanes[address]; ok {
+		return
+	}
+	d.lanes[address] = len(d.nodes)
+	d.nodes = append(d.nodes, address)
+}
+
+// x returns the horizontal position of the lifeline of a node.
+func (d *svgDiagram) x(address Address) float64 {
+	return svgMargin + svgAxisWidth + svgLaneWidth/2 + float64(d.lanes[address])*svgLaneWidth
+}
+
+// y returns the vertical position of a time.
+func (d *svgDiagram) y(t time.Duration) float64 {
+	return svgMargin + svgHeaderHeight + svgMinSlope + float64(t)*d.scale
+}
+
+// width returns the width of the diagram.
+func (d *svgDiagram) width() float64 {
+	return 2*svgMargin + svgAxisWidth + float64(len(d.nodes))*svgLaneWidth
+}
+
+// height returns the height of the diagram.
+func (d *svgDiagram) height() float64 {
+	return d.y(d.end) + svgMinSlope + svgHeaderHeight + svgMargin
+}
+
+// write writes the diagram as SVG.
+func (d *svgDiagram) write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	width, height := d.width(), d.height()
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	fmt.Fprintln(b, `<defs>`)
+	fmt.Fprintln(b, `<marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#333"/></marker>`)
+	fmt.Fprintln(b, `</defs>`)
+	fmt.Fprintf(b, `<rect width="%.0f" height="%.0f" fill="white"/>`+"\n", width, height)
+	d.writeAxis(b)
+	bottom := d.y(d.end) + svgMinSlope
+	for _, node := range d.nodes {
+		x := d.x(node)
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999" stroke-dasharray="4 4"/>`+"\n", x, float64(svgMargin+svgHeaderHeight), x, bottom)
+		d.writeHeader(b, node, svgMargin)
+		d.writeHeader(b, node, bottom)
+	}
+	for _, note := range d.notes {
+		d.writeNote(b, note)
+	}
+	for _, arrow := range d.arrows {
+		if arrow.from == arrow.to {
+			d.writeLoop(b, arrow)
+		} else {
+			d.writeArrow(b, arrow)
+		}
+	}
+	fmt.Fprintln(b, `</svg>`)
+	return b.Flush()
+}
+
+// writeAxis writes the time axis on the left of the diagram.
+func (d *svgDiagram) writeAxis(b *bufio.Writer) {
+	x := float64(svgMargin + svgAxisWidth - 10)
+	precision := time.Duration(1)
+	for precision*10*svgTicks*100 <= d.end {
+		precision *= 10
+	}
+	fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", x, d.y(0), x, d.y(d.end))
+	for i := 0; i <= svgTicks; i++ {
+		t := d.end * time.Duration(i) / svgTicks
+		y := d.y(t)
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", x-4, y, x, y)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle" font-size="10">%v</text>`+"\n", x-6, y, html.EscapeString(t.Round(precision).String()))
+	}
+}
+
+// writeHeader writes the box with the name of a node at the given height.
+func (d *svgDiagram) writeHeader(b *bufio.Writer, node Address, y float64) {
+	x := d.x(node)
+	w := float64(svgLaneWidth - 20)
+	fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%d" rx="4" fill="#fde8e8" stroke="#c33"/>`+"\n", x-w/2, y, w, svgHeaderHeight)
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="middle">%v</text>`+"\n", x, y+svgHeaderHeight/2, html.EscapeString(string(node)))
+}
+
+// writeNote writes a note with the state of a node on its lifeline.
+func (d *svgDiagram) writeNote(b *bufio.Writer, note svgNote) {
+	x, y := d.x(note.node), d.y(note.time)
+	fill := "#fff8c4"
+	if note.muted {
+		fill = "#eee"
+	}
+	w := float64(7*len(note.text) + 10)
+	fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="16" fill="%v" stroke="#999"/>`+"\n", x-w/2, y-8, w, fill)
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="middle" font-size="10">%v</text>`+"\n", x, y, html.EscapeString(note.text))
+}
+
+// writeArrow writes the arrow of a message or interrupt between two nodes.
+//
+// The arrow ends where the message arrived, so its slope shows its latency. Dropped messages end early
+// with a cross, and messages still in flight at the end of the trace are drawn faded.
+func (d *svgDiagram) writeArrow(b *bufio.Writer, arrow svgArrow) {
+	x1, y1 := d.x(arrow.from), d.y(arrow.start)
+	x2, y2 := d.x(arrow.to), d.y(arrow.end)
+	if y2-y1 < svgMinSlope {
+		y2 = y1 + svgMinSlope
+	}
+	if arrow.dropped {
+		x2 = x1 + 0.75*(x2-x1)
+	}
+	style := `stroke="#333"`
+	if arrow.dashed {
+		style += ` stroke-dasharray="6 3"`
+	}
+	if arrow.pending {
+		style += ` stroke-opacity="0.4"`
+	}
+	if arrow.dropped {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" %v/>`+"\n", x1, y1, x2, y2, style)
+		d.writeCross(b, x2, y2)
+	} else {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" %v marker-end="url(#arrow)"/>`+"\n", x1, y1, x2, y2, style)
+	}
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">%v</text>`+"\n", (x1+x2)/2, (y1+y2)/2-4, html.EscapeString(arrow.label))
+}
+
+// writeLoop writes the self loop of a timer, or of a message a node sent to itself.
+//
+// The loop spans from when the timer was set until it fired, and ends with a cross if it was dropped.
+func (d *svgDiagram) writeLoop(b *bufio.Writer, arrow svgArrow) {
+	x, y1, y2 := d.x(arrow.from), d.y(arrow.start), d.y(arrow.end)
+	if y2-y1 < svgMinSlope {
+		y2 = y1 + svgMinSlope
+	}
+	style := `stroke="#333" fill="none"`
+	if arrow.dashed {
+		style += ` stroke-dasharray="6 3"`
+	}
+	if arrow.pending {
+		style += ` stroke-opacity="0.4"`
+	}
+	if arrow.dropped {
+		fmt.Fprintf(b, `<path d="M %.1f %.1f h %d V %.1f" %v/>`+"\n", x, y1, svgLoopWidth, y2, style)
+		d.writeCross(b, x+svgLoopWidth, y2)
+	} else {
+		fmt.Fprintf(b, `<path d="M %.1f %.1f h %d V %.1f h %d" %v marker-end="url(#arrow)"/>`+"\n", x, y1, svgLoopWidth, y2, -svgLoopWidth, style)
+	}
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" dominant-baseline="middle">%v</text>`+"\n", x+svgLoopWidth+4, y1, html.EscapeString(arrow.label))
+}
+
+// writeCross writes the cross that marks where a message, timer or interrupt was lost.
+func (d *svgDiagram) writeCross(b *bufio.Writer, x, y float64) {
+	fmt.Fprintf(b, `<path d="M %.1f %.1f l 8 8 M %.1f %.1f l -8 8" stroke="#c33" stroke-width="2"/>`+"\n", x-4, y-4, x+4, y-4)
+}
+
+// WriteSvg writes a sequence diagram of the events of a trace as SVG, without needing PlantUML or java.
+//
+// Every node has a lifeline and time runs down the diagram on a linear scale, so the slope of the arrow of a
+// message shows its latency. Replies and interrupts are drawn with dashed arrows, timers as self loops from when
+// they were set until they fired, and dropped messages, timers and interrupts end with a cross. The states of
+// finite state machines, and nodes that stop or sleep, are drawn as notes on their lifelines.
+func WriteSvg(w io.Writer, events []Event) error {
+	return newSvgDiagram(events).write(w)
+}
+
+// WriteSvgFile writes a sequence diagram of the events of a trace as SVG to the file at the given path.
+func WriteSvgFile(path string, events []Event) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WriteSvg(file, events)
+}
+
+// writeSvg draws the trace of the simulation to its SVG path.
+//
+// Nothing is written if the simulation has no SVG path.
+func (s *LocalSimulation) writeSvg() error {
+	if s.options.SvgPath == "" {
+		return nil
+	}
+	return WriteSvgFile(s.options.SvgPath, s.trace.Events())
+}
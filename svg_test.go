@@ -0,0 +1,66 @@
+package disse_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// svgTrace is a trace in which a pings b and sets a timer, b replies and then crashes,
+// and the next ping of a is dropped while a message to c is still in flight.
+func svgTrace() []ds.Event {
+	ms := time.Millisecond
+	ping1 := ds.Message{Id: "p1", Type: ping}
+	pong1 := ds.Message{Id: "q1", Type: pong, ReplyTo: "p1", ParentId: "p1"}
+	ping2 := ds.Message{Id: "p2", Type: ping, ParentId: "t"}
+	hello := ds.Message{Id: "h", Type: "Hello", ParentId: "t"}
+	timer := ds.Timer{Id: "t", Type: next}
+	stop := ds.Interrupt{Id: "s", Type: ds.StopInterrupt}
+	return []ds.Event{
+		{Kind: ds.SimulationStateEvent, SimulationState: ds.SimulationRunning},
+		{Kind: ds.NodeStateEvent, To: "a", NodeState: ds.Running, FsmState: "Waiting"},
+		{Kind: ds.NodeStateEvent, To: "b", NodeState: ds.Running},
+		{Kind: ds.NodeStateEvent, To: "c", NodeState: ds.Running},
+		{Kind: ds.SendMessageEvent, From: "a", To: "b", Message: ping1},
+		{Kind: ds.SetTimerEvent, To: "a", Timer: timer, Duration: 40 * ms},
+		{Kind: ds.HandleMessageEvent, Time: 10 * ms, Arrival: 8 * ms, From: "a", To: "b", Message: ping1},
+		{Kind: ds.SendMessageEvent, Time: 10 * ms, From: "b", To: "a", Message: pong1},
+		{Kind: ds.HandleMessageEvent, Time: 20 * ms, From: "b", To: "a", Message: pong1},
+		{Kind: ds.SendInterruptEvent, Time: 25 * ms, From: "c", To: "b", Interrupt: stop},
+		{Kind: ds.HandleInterruptEvent, Time: 30 * ms, From: "c", To: "b", Interrupt: stop},
+		{Kind: ds.NodeStateEvent, Time: 30 * ms, To: "b", NodeState: ds.Stopped},
+		{Kind: ds.HandleTimerEvent, Time: 40 * ms, To: "a", Timer: timer, Duration: 40 * ms},
+		{Kind: ds.NodeStateEvent, Time: 40 * ms, To: "a", NodeState: ds.Running, FsmState: "Retrying"},
+		{Kind: ds.SendMessageEvent, Time: 40 * ms, From: "a", To: "b", Message: ping2},
+		{Kind: ds.SendMessageEvent, Time: 40 * ms, From: "a", To: "c", Message: hello},
+		{Kind: ds.DropMessageEvent, Time: 45 * ms, From: "a", To: "b", Message: ping2},
+		{Kind: ds.SimulationStateEvent, Time: 50 * ms, SimulationState: ds.SimulationFinished},
+	}
+}
+
+func TestWriteSvg(t *testing.T) {
+	var got bytes.Buffer
+	if err := ds.WriteSvg(&got, svgTrace()); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "trace.svg")
+	if *update {
+		if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("WriteSvg() does not match %v, run go test -update to see the changes:\n%s", golden, got.String())
+	}
+}
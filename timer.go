@@ -19,10 +19,14 @@ type TimerType string
 type TimerData any
 
 // Timer is a message that is sent to a node after a certain amount of time to trigger certain events.
+//
+// ParentId is the id of the message, timer or interrupt that the node was handling when it set the timer,
+// taken from the context given to the handler, and is empty for timers set with any other context.
 type Timer struct {
-	Id   TimerId
-	Type TimerType
-	Data TimerData
+	Id       TimerId
+	Type     TimerType
+	Data     TimerData
+	ParentId string
 }
 
 // String returns a string representation of the timer for debugging purposes.
@@ -0,0 +1,353 @@
+package disse
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock returns the current time of a simulation, measured from the start of the simulation.
+//
+// LocalSimulation implements Clock.
+type Clock interface {
+	Now() time.Duration
+}
+
+// EventKind is a string that identifies the kind of an event recorded in a trace.
+type EventKind string
+
+const (
+	// SimulationStateEvent is recorded when the simulation state changes.
+	SimulationStateEvent EventKind = "SimulationState"
+	// NodeStateEvent is recorded when the state of a node changes.
+	NodeStateEvent EventKind = "NodeState"
+	// SendMessageEvent is recorded when a message is sent.
+	SendMessageEvent EventKind = "SendMessage"
+	// HandleMessageEvent is recorded when a message is handled.
+	HandleMessageEvent EventKind = "HandleMessage"
+	// DropMessageEvent is recorded when a message is dropped.
+	DropMessageEvent EventKind = "DropMessage"
+	// SetTimerEvent is recorded when a timer is set.
+	SetTimerEvent EventKind = "SetTimer"
+	// HandleTimerEvent is recorded when a timer is handled.
+	HandleTimerEvent EventKind = "HandleTimer"
+	// DropTimerEvent is recorded when a timer is dropped.
+	DropTimerEvent EventKind = "DropTimer"
+	// SendInterruptEvent is recorded when an interrupt is sent.
+	SendInterruptEvent EventKind = "SendInterrupt"
+	// HandleInterruptEvent is recorded when an interrupt is handled.
+	HandleInterruptEvent EventKind = "HandleInterrupt"
+	// DropInterruptEvent is recorded when an interrupt is dropped.
+	DropInterruptEvent EventKind = "DropInterrupt"
+)
+
+// Event is a single event recorded in a simulation trace.
+//
+// Only the fields relevant to the kind of the event are set. Lamport and Vector are the logical
+// clocks of the node at which the event occurred, after the event, if the simulation tracks them.
+// Arrival is the time a message or timer that is handled or dropped arrived at the node, which is
+// the time of the event if the arrival was not logged.
+type Event struct {
+	Kind            EventKind
+	Time            time.Duration
+	From            Address
+	To              Address
+	Message         Message
+	Timer           Timer
+	Interrupt       Interrupt
+	Duration        time.Duration
+	NodeState       NodeState
+	FsmState        FsmState
+	SimulationState SimulationState
+	Lamport         uint64
+	Vector          VectorClock
+	Arrival         time.Duration
+}
+
+// HappensBefore returns true if the event happened before the other event, according to their vector clocks.
+//
+// It always returns false if the simulation did not track logical clocks.
+func (e Event) HappensBefore(other Event) bool {
+	return e.Vector != nil && other.Vector != nil && e.Vector.HappensBefore(other.Vector)
+}
+
+// Id returns the id of the message, timer or interrupt of the event, or an empty string for state events.
+func (e Event) Id() string {
+	switch e.Kind {
+	case SendMessageEvent, HandleMessageEvent, DropMessageEvent:
+		return string(e.Message.Id)
+	case SetTimerEvent, HandleTimerEvent, DropTimerEvent:
+		return string(e.Timer.Id)
+	case SendInterruptEvent, HandleInterruptEvent, DropInterruptEvent:
+		return string(e.Interrupt.Id)
+	default:
+		return ""
+	}
+}
+
+// ParentId returns the id of the message, timer or interrupt that caused the message, timer or interrupt
+// of the event, or an empty string if it was not caused by another event.
+func (e Event) ParentId() string {
+	switch e.Kind {
+	case SendMessageEvent, HandleMessageEvent, DropMessageEvent:
+		return e.Message.ParentId
+	case SetTimerEvent, HandleTimerEvent, DropTimerEvent:
+		return e.Timer.ParentId
+	case SendInterruptEvent, HandleInterruptEvent, DropInterruptEvent:
+		return e.Interrupt.ParentId
+	default:
+		return ""
+	}
+}
+
+// Node returns the address of the node at which the event occurred.
+//
+// Send events occur at the sender, all other events occur at the receiver.
+func (e Event) Node() Address {
+	switch e.Kind {
+	case SendMessageEvent, SendInterruptEvent:
+		return e.From
+	default:
+		return e.To
+	}
+}
+
+// String returns a string representation of the event for debugging purposes.
+func (e Event) String() string {
+	switch e.Kind {
+	case SimulationStateEvent:
+		return fmt.Sprintf("[%v] %v(%v)", e.Time, e.Kind, e.SimulationState)
+	case NodeStateEvent:
+		if e.FsmState != "" {
+			return fmt.Sprintf("[%v] %v(%v, %v, %v)", e.Time, e.Kind, e.To, e.NodeState, e.FsmState)
+		}
+		return fmt.Sprintf("[%v] %v(%v, %v)", e.Time, e.Kind, e.To, e.NodeState)
+	case SendMessageEvent, HandleMessageEvent, DropMessageEvent:
+		return fmt.Sprintf("[%v] %v(%v -> %v, %v)", e.Time, e.Kind, e.From, e.To, e.Message)
+	case SetTimerEvent, HandleTimerEvent, DropTimerEvent:
+		return fmt.Sprintf("[%v] %v(%v, %v, %v)", e.Time, e.Kind, e.To, e.Timer, e.Duration)
+	case SendInterruptEvent, HandleInterruptEvent, DropInterruptEvent:
+		return fmt.Sprintf("[%v] %v(%v -> %v, %v)", e.Time, e.Kind, e.From, e.To, e.Interrupt)
+	default:
+		return fmt.Sprintf("[%v] %v", e.Time, e.Kind)
+	}
+}
+
+// TraceLogger is a Logger implementation that records every event in memory.
+//
+// It also implements ArrivalLogger, to record when the messages and timers it handles or drops arrived.
+// It is safe to use from multiple goroutines.
+type TraceLogger struct {
+	clock    Clock
+	mu       sync.Mutex
+	events   []Event
+	arrivals map[string][]time.Duration
+}
+
+// NewTraceLogger creates a new TraceLogger that timestamps events using the given clock.
+func NewTraceLogger(clock Clock) *TraceLogger {
+	return &TraceLogger{
+		clock:    clock,
+		events:   make([]Event, 0),
+		arrivals: make(map[string][]time.Duration),
+	}
+}
+
+// Events returns a copy of the events recorded so far.
+func (l *TraceLogger) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]Event, len(l.events))
+	copy(events, l.events)
+	return events
+}
+
+// Len returns the number of events recorded so far.
+func (l *TraceLogger) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.events)
+}
+
+// Truncate discards all events after the first n events.
+func (l *TraceLogger) Truncate(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n < len(l.events) {
+		l.events = l.events[:n]
+	}
+}
+
+// record appends an event to the trace, setting its time and logical clocks from the clock.
+func (l *TraceLogger) record(event Event) {
+	event.Time = l.clock.Now()
+	if lc, ok := l.clock.(LogicalClocker); ok && event.Kind != SimulationStateEvent {
+		event.Lamport, event.Vector = lc.LogicalClock(event.Node())
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch event.Kind {
+	case HandleMessageEvent, DropMessageEvent, HandleTimerEvent, DropTimerEvent:
+		event.Arrival = event.Time
+		if arrivals := l.arrivals[event.Id()]; len(arrivals) > 0 {
+			event.Arrival = arrivals[0]
+			if len(arrivals) == 1 {
+				delete(l.arrivals, event.Id())
+			} else {
+				l.arrivals[event.Id()] = arrivals[1:]
+			}
+		}
+	}
+	l.events = append(l.events, event)
+}
+
+// arrive records the arrival of the message or timer with the given id.
+func (l *TraceLogger) arrive(id string) {
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.arrivals[id] = append(l.arrivals[id], now)
+}
+
+// LogArriveMessage is called when a message arrives at its destination node.
+func (l *TraceLogger) LogArriveMessage(from, to Address, message Message) {
+	l.arrive(string(message.Id))
+}
+
+// LogArriveTimer is called when a timer arrives at its node.
+func (l *TraceLogger) LogArriveTimer(to Address, timer Timer, duration time.Duration) {
+	l.arrive(string(timer.Id))
+}
+
+// LogSimulationState is called when the simulation state changes.
+func (l *TraceLogger) LogSimulationState(sim Simulation) {
+	l.record(Event{Kind: SimulationStateEvent, SimulationState: sim.GetState()})
+}
+
+// LogNodeState is called when the state of a node changes.
+func (l *TraceLogger) LogNodeState(node Node) {
+	event := Event{Kind: NodeStateEvent, To: node.GetAddress(), NodeState: node.GetState()}
+	if fsm, ok := node.(FsmStater); ok {
+		event.FsmState = fsm.GetFsmState()
+	}
+	l.record(event)
+}
+
+// LogSendMessage is called when a message is sent.
+func (l *TraceLogger) LogSendMessage(from, to Address, message Message) {
+	l.record(Event{Kind: SendMessageEvent, From: from, To: to, Message: message})
+}
+
+// LogHandleMessage is called when a message is handled.
+func (l *TraceLogger) LogHandleMessage(from, to Address, message Message) {
+	l.record(Event{Kind: HandleMessageEvent, From: from, To: to, Message: message})
+}
+
+// LogDropMessage is called when a message is dropped.
+func (l *TraceLogger) LogDropMessage(from, to Address, message Message) {
+	l.record(Event{Kind: DropMessageEvent, From: from, To: to, Message: message})
+}
+
+// LogSetTimer is called when a timer is set.
+func (l *TraceLogger) LogSetTimer(to Address, timer Timer, duration time.Duration) {
+	l.record(Event{Kind: SetTimerEvent, To: to, Timer: timer, Duration: duration})
+}
+
+// LogHandleTimer is called when a timer is handled.
+func (l *TraceLogger) LogHandleTimer(to Address, timer Timer, duration time.Duration) {
+	l.record(Event{Kind: HandleTimerEvent, To: to, Timer: timer, Duration: duration})
+}
+
+// LogDropTimer is called when a timer is dropped.
+func (l *TraceLogger) LogDropTimer(to Address, timer Timer, duration time.Duration) {
+	l.record(Event{Kind: DropTimerEvent, To: to, Timer: timer, Duration: duration})
+}
+
+// LogSendInterrupt is called when an interrupt is sent.
+func (l *TraceLogger) LogSendInterrupt(from, to Address, interrupt Interrupt) {
+	l.record(Event{Kind: SendInterruptEvent, From: from, To: to, Interrupt: interrupt})
+}
+
+// LogHandleInterrupt is called when an interrupt is handled.
+func (l *TraceLogger) LogHandleInterrupt(from, to Address, interrupt Interrupt) {
+	l.record(Event{Kind: HandleInterruptEvent, From: from, To: to, Interrupt: interrupt})
+}
+
+// LogDropInterrupt is called when an interrupt is dropped.
+func (l *TraceLogger) LogDropInterrupt(from, to Address, interrupt Interrupt) {
+	l.record(Event{Kind: DropInterruptEvent, From: from, To: to, Interrupt: interrupt})
+}
+
+// CausalChain returns the chain of handled events that caused the event at index i of a trace.
+//
+// The chain starts with the handling of an event that was sent outside of any handler, such as in Init,
+// and ends with the handling of the parent of the event. Each event in the chain is the last handling of
+// its message, timer or interrupt before the next event in the chain.
+func CausalChain(events []Event, i int) []Event {
+	chain := make([]Event, 0)
+	parent := events[i].ParentId()
+	for parent != "" {
+		j := i - 1
+		for j >= 0 && !(events[j].Id() == parent && isHandleEvent(events[j].Kind)) {
+			j--
+		}
+		if j < 0 {
+			break
+		}
+		chain = append(chain, events[j])
+		i, parent = j, events[j].ParentId()
+	}
+	for l, r := 0, len(chain)-1; l < r; l, r = l+1, r-1 {
+		chain[l], chain[r] = chain[r], chain[l]
+	}
+	return chain
+}
+
+// isHandleEvent returns true if the kind is the handling of a message, timer or interrupt.
+func isHandleEvent(kind EventKind) bool {
+	return kind == HandleMessageEvent || kind == HandleTimerEvent || kind == HandleInterruptEvent
+}
+
+// CausalHistory returns the events of a trace that happened before the event at index i, in the order they
+// were recorded, followed by the event itself.
+//
+// An event happened before another if it occurred earlier at the same top level node, or if it happened before
+// a message, timer or interrupt was sent that the node of the other event handled or dropped before it.
+// Changes of the state of the simulation are not part of any history.
+func CausalHistory(events []Event, i int) []Event {
+	frontier := map[Address]int{events[i].Node().GetRoot(): i}
+	wanted := make(map[string]bool)
+	history := make([]Event, 0)
+	for j := i; j >= 0; j-- {
+		event := events[j]
+		if event.Kind == SimulationStateEvent {
+			continue
+		}
+		node := event.Node().GetRoot()
+		f, ok := frontier[node]
+		included := ok && j <= f
+		if isSendEvent(event.Kind) && wanted[event.Id()] {
+			delete(wanted, event.Id())
+			included = true
+			if !ok || f < j {
+				frontier[node] = j
+			}
+		}
+		if !included {
+			continue
+		}
+		history = append(history, event)
+		if !isSendEvent(event.Kind) && event.Id() != "" {
+			wanted[event.Id()] = true
+		}
+	}
+	for l, r := 0, len(history)-1; l < r; l, r = l+1, r-1 {
+		history[l], history[r] = history[r], history[l]
+	}
+	return history
+}
+
+// isSendEvent returns true if the kind is the sending of a message or interrupt, or the setting of a timer.
+func isSendEvent(kind EventKind) bool {
+	return kind == SendMessageEvent || kind == SetTimerEvent || kind == SendInterruptEvent
+}
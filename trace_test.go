@@ -0,0 +1,121 @@
+package disse_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// causalTrace is a trace in which b replies to a ping from a, while c sends an unrelated message to d.
+func causalTrace() []ds.Event {
+	m1 := ds.Message{Id: "m1", Type: ping}
+	m2 := ds.Message{Id: "m2", Type: pong, ReplyTo: "m1", ParentId: "m1"}
+	x := ds.Message{Id: "x", Type: ping}
+	return []ds.Event{
+		{Kind: ds.SendMessageEvent, From: "a", To: "b", Message: m1},
+		{Kind: ds.SendMessageEvent, From: "c", To: "d", Message: x},
+		{Kind: ds.HandleMessageEvent, From: "a", To: "b", Message: m1},
+		{Kind: ds.SendMessageEvent, From: "b", To: "a", Message: m2},
+		{Kind: ds.HandleMessageEvent, From: "c", To: "d", Message: x},
+		{Kind: ds.HandleMessageEvent, From: "b", To: "a", Message: m2},
+	}
+}
+
+// ids returns the kinds and ids of the events, for comparing them in tests.
+func ids(events []ds.Event) string {
+	s := ""
+	for _, event := range events {
+		s += fmt.Sprintf("%v(%v) ", event.Kind, event.Id())
+	}
+	return s
+}
+
+func TestCausalChain(t *testing.T) {
+	trace := causalTrace()
+	if got, want := ids(ds.CausalChain(trace, 5)), ids([]ds.Event{trace[2]}); got != want {
+		t.Errorf("CausalChain() = %v, want %v", got, want)
+	}
+	if got := ds.CausalChain(trace, 2); len(got) != 0 {
+		t.Errorf("CausalChain() of an event sent in Init = %v, want nothing", ids(got))
+	}
+}
+
+func TestCausalHistory(t *testing.T) {
+	trace := causalTrace()
+	want := ids([]ds.Event{trace[0], trace[2], trace[3], trace[5]})
+	if got := ids(ds.CausalHistory(trace, 5)); got != want {
+		t.Errorf("CausalHistory() = %v, want %v", got, want)
+	}
+	want = ids([]ds.Event{trace[1], trace[4]})
+	if got := ids(ds.CausalHistory(trace, 4)); got != want {
+		t.Errorf("CausalHistory() = %v, want %v", got, want)
+	}
+}
+
+// relayNode replies to a ping with a pong once the timer it sets for the ping fires.
+type relayNode struct {
+	*ds.LocalNode
+	from ds.Address
+}
+
+func (n *relayNode) Init(ctx context.Context) {}
+
+func (n *relayNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	if message.Type != ping {
+		return false
+	}
+	n.from = from
+	n.SetTimer(ctx, ds.NewTimer(next, nil), 10*time.Millisecond)
+	return true
+}
+
+func (n *relayNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	n.SendMessage(ctx, ds.NewMessage(pong, nil), n.from)
+	return true
+}
+
+func TestParentIds(t *testing.T) {
+	sim := ds.NewLocalSimulation(testOptions())
+	sim.AddNode(newPingNode(sim, "a", "b"))
+	sim.AddNode(&relayNode{LocalNode: ds.NewLocalNode(sim, "b")})
+	trace := ds.NewTraceLogger(sim)
+	sim.AddLogger(trace)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	events := trace.Events()
+
+	var sendPing, setTimer, sendPong ds.Event
+	last := -1
+	for i, event := range events {
+		switch {
+		case event.Kind == ds.SendMessageEvent && event.Message.Type == ping:
+			sendPing = event
+		case event.Kind == ds.SetTimerEvent:
+			setTimer = event
+		case event.Kind == ds.SendMessageEvent && event.Message.Type == pong:
+			sendPong = event
+		case event.Kind == ds.HandleMessageEvent && event.Message.Type == pong:
+			last = i
+		}
+	}
+	if last < 0 {
+		t.Fatal("pong was never handled")
+	}
+	if sendPing.ParentId() != "" {
+		t.Errorf("ping sent in Init has parent %v, want none", sendPing.ParentId())
+	}
+	if setTimer.ParentId() != sendPing.Id() {
+		t.Errorf("timer has parent %v, want the ping %v", setTimer.ParentId(), sendPing.Id())
+	}
+	if sendPong.ParentId() != setTimer.Id() {
+		t.Errorf("pong has parent %v, want the timer %v", sendPong.ParentId(), setTimer.Id())
+	}
+	want := fmt.Sprintf("%v(%v) %v(%v) %v(%v) ", ds.HandleMessageEvent, sendPing.Id(), ds.HandleTimerEvent, setTimer.Id(), ds.HandleMessageEvent, sendPong.Id())
+	if got := ids(append(ds.CausalChain(events, last), events[last])); got != want {
+		t.Errorf("CausalChain() of the pong = %v, want %v", got, want)
+	}
+}
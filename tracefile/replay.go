@@ -0,0 +1,184 @@
+package tracefile
+
+import (
+	"context"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// Replayer replays the events of a log to loggers, as if the simulation that wrote the log was running again.
+//
+// Replayer implements Clock, so loggers that timestamp events, such as TraceLogger, can be created
+// with the replayer as their clock to see the time each event originally occurred at.
+type Replayer struct {
+	records []Record
+	now     time.Duration
+}
+
+// NewReplayer creates a new Replayer for the given records.
+func NewReplayer(records []Record) *Replayer {
+	return &Replayer{
+		records: records,
+	}
+}
+
+// Now returns the time of the event that is being replayed.
+func (r *Replayer) Now() time.Duration {
+	return r.now
+}
+
+// Replay calls the logger function for every event, in order, on each of the loggers.
+//
+// The nodes and simulation passed to LogNodeState and LogSimulationState only report their
+// address and state, and nodes report the state of their machine if the event has one.
+func (r *Replayer) Replay(loggers ...ds.Logger) {
+	for _, record := range r.records {
+		r.now = record.Time
+		for _, logger := range loggers {
+			replay(record.Event, logger)
+		}
+	}
+}
+
+// replay calls the logger function for a single event.
+func replay(e ds.Event, logger ds.Logger) {
+	switch e.Kind {
+	case ds.SimulationStateEvent:
+		logger.LogSimulationState(&replaySimulation{state: e.SimulationState})
+	case ds.NodeStateEvent:
+		node := &replayNode{address: e.To, state: e.NodeState}
+		if e.FsmState != "" {
+			logger.LogNodeState(&replayFsmNode{replayNode: node, fsmState: e.FsmState})
+		} else {
+			logger.LogNodeState(node)
+		}
+	case ds.SendMessageEvent:
+		logger.LogSendMessage(e.From, e.To, e.Message)
+	case ds.HandleMessageEvent:
+		logger.LogHandleMessage(e.From, e.To, e.Message)
+	case ds.DropMessageEvent:
+		logger.LogDropMessage(e.From, e.To, e.Message)
+	case ds.SetTimerEvent:
+		logger.LogSetTimer(e.To, e.Timer, e.Duration)
+	case ds.HandleTimerEvent:
+		logger.LogHandleTimer(e.To, e.Timer, e.Duration)
+	case ds.DropTimerEvent:
+		logger.LogDropTimer(e.To, e.Timer, e.Duration)
+	case ds.SendInterruptEvent:
+		logger.LogSendInterrupt(e.From, e.To, e.Interrupt)
+	case ds.HandleInterruptEvent:
+		logger.LogHandleInterrupt(e.From, e.To, e.Interrupt)
+	case ds.DropInterruptEvent:
+		logger.LogDropInterrupt(e.From, e.To, e.Interrupt)
+	}
+}
+
+// replaySimulation is the simulation passed to LogSimulationState when replaying an event.
+//
+// It only reports the state of the simulation, its other methods do nothing.
+type replaySimulation struct {
+	state ds.SimulationState
+}
+
+// GetState returns the state of the simulation when the event occurred.
+func (s *replaySimulation) GetState() ds.SimulationState {
+	return s.state
+}
+
+// AddNode does nothing.
+func (s *replaySimulation) AddNode(ds.Node) error {
+	return nil
+}
+
+// RemoveNode does nothing.
+func (s *replaySimulation) RemoveNode(ds.Address) {}
+
+// AddLogger does nothing.
+func (s *replaySimulation) AddLogger(ds.Logger) {}
+
+// RemoveLogger does nothing.
+func (s *replaySimulation) RemoveLogger(ds.Logger) {}
+
+// Run does nothing.
+func (s *replaySimulation) Run() {}
+
+// replayNode is the node passed to LogNodeState when replaying an event.
+//
+// It only reports its address and state, its other methods do nothing and it has no sub nodes.
+type replayNode struct {
+	address ds.Address
+	state   ds.NodeState
+}
+
+// Init does nothing.
+func (n *replayNode) Init(context.Context) {}
+
+// GetAddress returns the address of the node.
+func (n *replayNode) GetAddress() ds.Address {
+	return n.address
+}
+
+// GetState returns the state of the node when the event occurred.
+func (n *replayNode) GetState() ds.NodeState {
+	return n.state
+}
+
+// GetSubNodes returns an empty map.
+func (n *replayNode) GetSubNodes() map[ds.Address]ds.Node {
+	return make(map[ds.Address]ds.Node)
+}
+
+// AddSubNode does nothing.
+func (n *replayNode) AddSubNode(ds.Node) error {
+	return nil
+}
+
+// RemoveSubNode does nothing.
+func (n *replayNode) RemoveSubNode(ds.Address) {}
+
+// SendMessage does nothing.
+func (n *replayNode) SendMessage(context.Context, ds.Message, ds.Address) error {
+	return nil
+}
+
+// BroadcastMessage does nothing.
+func (n *replayNode) BroadcastMessage(context.Context, ds.Message, []ds.Address) error {
+	return nil
+}
+
+// SetTimer does nothing.
+func (n *replayNode) SetTimer(context.Context, ds.Timer, time.Duration) error {
+	return nil
+}
+
+// SendInterrupt does nothing.
+func (n *replayNode) SendInterrupt(context.Context, ds.Interrupt, ds.Address) error {
+	return nil
+}
+
+// HandleMessage does nothing and reports the message as not handled.
+func (n *replayNode) HandleMessage(context.Context, ds.Message, ds.Address) bool {
+	return false
+}
+
+// HandleTimer does nothing and reports the timer as not handled.
+func (n *replayNode) HandleTimer(context.Context, ds.Timer, time.Duration) bool {
+	return false
+}
+
+// HandleInterrupt does nothing and reports the interrupt as not handled.
+func (n *replayNode) HandleInterrupt(context.Context, ds.Interrupt, ds.Address) bool {
+	return false
+}
+
+// replayFsmNode is a replayNode that also reports the state of its machine.
+type replayFsmNode struct {
+	*replayNode
+	fsmState ds.FsmState
+}
+
+// GetFsmState returns the state of the machine when the event occurred.
+func (n *replayFsmNode) GetFsmState() ds.FsmState {
+	return n.fsmState
+}
@@ -0,0 +1,134 @@
+// Package tracefile reads the JSON Lines event logs written by JsonLogger back into typed events.
+//
+// The events can be analysed like the events recorded by a TraceLogger, or replayed to any
+// Logger, for example to draw the UML diagram of a run after it has finished.
+package tracefile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// Record is an event read from a JSON Lines event log.
+//
+// The data of the message, timer or interrupt of the event is decoded into generic JSON values,
+// such as maps and float64s. Data holds the raw encoding, which DecodeData decodes into a typed value.
+type Record struct {
+	ds.Event
+	Wall time.Time
+	Data json.RawMessage
+}
+
+// DecodeData decodes the data of the message, timer or interrupt of the event into v.
+func (r Record) DecodeData(v any) error {
+	if r.Data == nil {
+		return fmt.Errorf("%v event has no data", r.Kind)
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+// Read reads the events of a JSON Lines event log from r.
+func Read(r io.Reader) ([]Record, error) {
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record, err := decode(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ReadFile reads the events of the JSON Lines event log at the given path.
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Events returns the events of the records, in the same order.
+func Events(records []Record) []ds.Event {
+	events := make([]ds.Event, len(records))
+	for i, record := range records {
+		events[i] = record.Event
+	}
+	return events
+}
+
+// decode decodes a single line of a JSON Lines event log.
+func decode(line []byte) (Record, error) {
+	var event ds.JsonEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return Record{}, err
+	}
+	var data any
+	if event.Data != nil {
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return Record{}, err
+		}
+	}
+	record := Record{
+		Event: ds.Event{
+			Kind:            event.Kind,
+			Time:            event.Time,
+			From:            event.From,
+			To:              event.To,
+			Duration:        event.Duration,
+			NodeState:       event.NodeState,
+			FsmState:        event.FsmState,
+			SimulationState: event.SimulationState,
+			Lamport:         event.Lamport,
+			Vector:          event.Vector,
+		},
+		Wall: event.Wall,
+		Data: event.Data,
+	}
+	switch event.Kind {
+	case ds.SimulationStateEvent, ds.NodeStateEvent:
+	case ds.SendMessageEvent, ds.HandleMessageEvent, ds.DropMessageEvent:
+		record.Message = ds.Message{
+			Id:       ds.MessageId(event.Id),
+			Type:     ds.MessageType(event.Type),
+			Data:     data,
+			ReplyTo:  event.ReplyTo,
+			Lamport:  event.MessageLamport,
+			Vector:   event.MessageVector,
+			ParentId: event.ParentId,
+		}
+	case ds.SetTimerEvent, ds.HandleTimerEvent, ds.DropTimerEvent:
+		record.Timer = ds.Timer{
+			Id:       ds.TimerId(event.Id),
+			Type:     ds.TimerType(event.Type),
+			Data:     data,
+			ParentId: event.ParentId,
+		}
+	case ds.SendInterruptEvent, ds.HandleInterruptEvent, ds.DropInterruptEvent:
+		record.Interrupt = ds.Interrupt{
+			Id:       ds.InterruptId(event.Id),
+			Type:     ds.InterruptType(event.Type),
+			Data:     data,
+			ParentId: event.ParentId,
+		}
+	default:
+		return Record{}, fmt.Errorf("unknown event kind %q", event.Kind)
+	}
+	return record, nil
+}
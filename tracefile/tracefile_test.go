@@ -0,0 +1,134 @@
+package tracefile_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/tracefile"
+)
+
+const (
+	ping ds.MessageType = "Ping"
+	pong ds.MessageType = "Pong"
+	wait ds.TimerType   = "Wait"
+)
+
+// counter is the data of a ping.
+type counter struct {
+	Count int
+}
+
+// pingNode sends a ping to each of its peers when its timer fires, and replies to every ping with a pong.
+type pingNode struct {
+	*ds.LocalNode
+	peers []ds.Address
+}
+
+func (n *pingNode) Init(ctx context.Context) {
+	if len(n.peers) > 0 {
+		n.SetTimer(ctx, ds.NewTimer(wait, nil), 10*time.Millisecond)
+	}
+}
+
+func (n *pingNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	if message.Type == ping {
+		n.SendMessage(ctx, ds.NewReply(message, pong, nil), from)
+	}
+	return true
+}
+
+func (n *pingNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	for i, peer := range n.peers {
+		n.SendMessage(ctx, ds.NewMessage(ping, counter{Count: i}), peer)
+	}
+	return true
+}
+
+// runLogged runs a simulation in which a pings b and c, and returns the path of its JSON log and its trace.
+func runLogged(t *testing.T) (string, []ds.Event) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sim := ds.NewLocalSimulation(&ds.LocalSimulationOptions{
+		MinLatency:    time.Millisecond,
+		MaxLatency:    5 * time.Millisecond,
+		Duration:      100 * time.Millisecond,
+		JsonLogPath:   path,
+		LogicalClocks: true,
+	})
+	for _, address := range []ds.Address{"b", "c"} {
+		sim.AddNode(&pingNode{LocalNode: ds.NewLocalNode(sim, address)})
+	}
+	sim.AddNode(&pingNode{LocalNode: ds.NewLocalNode(sim, "a"), peers: []ds.Address{"b", "c"}})
+	trace := ds.NewTraceLogger(sim)
+	sim.AddLogger(trace)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+	return path, trace.Events()
+}
+
+// sameEvent reports whether an event read from a log is the event recorded by a trace, ignoring data, arrival times
+// and logical clocks.
+func sameEvent(read, traced ds.Event) bool {
+	return read.Kind == traced.Kind && read.Time == traced.Time &&
+		read.From == traced.From && read.To == traced.To &&
+		read.Id() == traced.Id() && read.ParentId() == traced.ParentId() && read.Duration == traced.Duration &&
+		read.Message.Type == traced.Message.Type && read.Message.ReplyTo == traced.Message.ReplyTo &&
+		read.Timer.Type == traced.Timer.Type &&
+		read.NodeState == traced.NodeState && read.SimulationState == traced.SimulationState
+}
+
+func TestRead(t *testing.T) {
+	path, traced := runLogged(t)
+	records, err := tracefile.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := tracefile.Events(records)
+	if len(events) != len(traced) {
+		t.Fatalf("read %d events, want %d", len(events), len(traced))
+	}
+	pings := 0
+	for i := range traced {
+		if !sameEvent(events[i], traced[i]) {
+			t.Errorf("event %d read as %+v, want %+v", i, events[i], traced[i])
+		}
+		if events[i].Lamport != traced[i].Lamport || events[i].Vector.String() != traced[i].Vector.String() {
+			t.Errorf("event %d read with clocks %d %v, want %d %v", i, events[i].Lamport, events[i].Vector, traced[i].Lamport, traced[i].Vector)
+		}
+		if events[i].Kind != ds.SendMessageEvent || events[i].Message.Type != ping {
+			continue
+		}
+		var data counter
+		if err := records[i].DecodeData(&data); err != nil || data != traced[i].Message.Data {
+			t.Errorf("ping data decoded as %v with %v, want %v", data, err, traced[i].Message.Data)
+		}
+		pings++
+	}
+	if pings != 2 {
+		t.Errorf("read %d sent pings, want 2", pings)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	path, traced := runLogged(t)
+	records, err := tracefile.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := tracefile.NewReplayer(records)
+	trace := ds.NewTraceLogger(replayer)
+	replayer.Replay(trace)
+	replayed := trace.Events()
+	if len(replayed) != len(traced) {
+		t.Fatalf("replayed %d events, want %d", len(replayed), len(traced))
+	}
+	for i := range traced {
+		if !sameEvent(replayed[i], traced[i]) {
+			t.Errorf("event %d replayed as %+v, want %+v", i, replayed[i], traced[i])
+		}
+	}
+}
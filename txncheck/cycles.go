@@ -0,0 +1,138 @@
+package txncheck
+
+import "sort"
+
+// stronglyConnected returns the strongly connected components of the graph with more than one transaction,
+// using Tarjan's algorithm. The transactions of every component are sorted by index.
+func (g *graph) stronglyConnected() [][]int {
+	index := make(map[int]int)
+	low := make(map[int]int)
+	onStack := make(map[int]bool)
+	stack := make([]int, 0)
+	components := make([][]int, 0)
+	next := 0
+
+	var connect func(v int)
+	connect = func(v int) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, dependency := range g.edges[v] {
+			w := dependency.To
+			if _, ok := index[w]; !ok {
+				connect(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		component := make([]int, 0)
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Ints(component)
+			components = append(components, component)
+		}
+	}
+	for v := range g.history {
+		if _, ok := index[v]; !ok {
+			connect(v)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// path returns the shortest path of dependencies from one transaction to another that stays within
+// the component and only uses the allowed types of dependencies, or nil if there is none.
+func (g *graph) path(from, to int, component map[int]bool, allowed ...DependencyType) []Dependency {
+	isAllowed := func(dependencyType DependencyType) bool {
+		for _, a := range allowed {
+			if a == dependencyType {
+				return true
+			}
+		}
+		return false
+	}
+	previous := map[int]Dependency{}
+	visited := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if v == to {
+			path := make([]Dependency, 0)
+			for v != from {
+				path = append([]Dependency{previous[v]}, path...)
+				v = previous[v].From
+			}
+			return path
+		}
+		for _, dependency := range g.edges[v] {
+			w := dependency.To
+			if component[w] && !visited[w] && isAllowed(dependency.Type) {
+				visited[w] = true
+				previous[w] = dependency
+				queue = append(queue, w)
+			}
+		}
+	}
+	return nil
+}
+
+// cycleThrough returns a cycle that starts with a dependency of the given type and continues
+// with the allowed types of dependencies, or nil if there is none.
+func (g *graph) cycleThrough(component []int, first DependencyType, allowed ...DependencyType) []Dependency {
+	members := make(map[int]bool)
+	for _, v := range component {
+		members[v] = true
+	}
+	for _, v := range component {
+		for _, dependency := range g.edges[v] {
+			if dependency.Type != first || !members[dependency.To] {
+				continue
+			}
+			if path := g.path(dependency.To, dependency.From, members, allowed...); path != nil {
+				return append([]Dependency{dependency}, path...)
+			}
+		}
+	}
+	return nil
+}
+
+// findCycles adds an anomaly for every type of cycle found in each strongly connected component of the graph.
+func (g *graph) findCycles() {
+	for _, component := range g.stronglyConnected() {
+		if cycle := g.cycleThrough(component, WW, WW); cycle != nil {
+			g.addCycle(G0, cycle)
+		}
+		if cycle := g.cycleThrough(component, WR, WW, WR); cycle != nil {
+			g.addCycle(G1c, cycle)
+		}
+		if cycle := g.cycleThrough(component, RW, WW, WR); cycle != nil {
+			g.addCycle(GSingle, cycle)
+		} else if cycle := g.cycleThrough(component, RW, WW, WR, RW); cycle != nil {
+			g.addCycle(G2, cycle)
+		}
+	}
+}
+
+// addCycle adds an anomaly that is a cycle of dependencies.
+func (g *graph) addCycle(anomalyType AnomalyType, cycle []Dependency) {
+	g.anomalies = append(g.anomalies, Anomaly{Type: anomalyType, Cycle: cycle})
+}
@@ -0,0 +1,277 @@
+package txncheck
+
+// version is a value of a key.
+type version struct {
+	key   string
+	value any
+}
+
+// graph is the dependency graph between the transactions of a history.
+type graph struct {
+	history      []Transaction
+	dependencies []Dependency
+	edges        map[int][]Dependency
+	seen         map[Dependency]bool
+	anomalies    []Anomaly
+}
+
+// newGraph creates a dependency graph without edges for the history.
+func newGraph(history []Transaction) *graph {
+	return &graph{
+		history:      history,
+		dependencies: make([]Dependency, 0),
+		edges:        make(map[int][]Dependency),
+		seen:         make(map[Dependency]bool),
+		anomalies:    make([]Anomaly, 0),
+	}
+}
+
+// addDependency adds an edge to the graph.
+//
+// Edges from a transaction to itself and edges to or from aborted transactions are ignored.
+func (g *graph) addDependency(from, to int, dependencyType DependencyType, key string) {
+	if from == to || g.history[from].Status == Aborted || g.history[to].Status == Aborted {
+		return
+	}
+	dependency := Dependency{From: from, To: to, Type: dependencyType, Key: key}
+	if g.seen[dependency] {
+		return
+	}
+	g.seen[dependency] = true
+	g.dependencies = append(g.dependencies, dependency)
+	g.edges[from] = append(g.edges[from], dependency)
+}
+
+// addAnomaly adds an anomaly that is not a cycle.
+func (g *graph) addAnomaly(anomalyType AnomalyType, key string, transactions ...int) {
+	g.anomalies = append(g.anomalies, Anomaly{Type: anomalyType, Key: key, Transactions: transactions})
+}
+
+// externalReads returns the reads of a transaction that happen before the transaction writes to the key.
+func externalReads(t Transaction) []Mop {
+	reads := make([]Mop, 0)
+	written := make(map[string]bool)
+	for _, op := range t.Ops {
+		if op.Type == Read && !written[op.Key] {
+			reads = append(reads, op)
+		} else if op.Type != Read {
+			written[op.Key] = true
+		}
+	}
+	return reads
+}
+
+// writes returns the writer of every value written in the history, and the values that
+// were overwritten by a later write of the same transaction.
+func (g *graph) writes(mopType MopType) (map[version]int, map[version]bool) {
+	writers := make(map[version]int)
+	intermediate := make(map[version]bool)
+	for i, t := range g.history {
+		last := make(map[string]any)
+		for _, op := range t.Ops {
+			if op.Type != mopType {
+				continue
+			}
+			writers[version{op.Key, op.Value}] = i
+			if previous, ok := last[op.Key]; ok {
+				intermediate[version{op.Key, previous}] = true
+			}
+			last[op.Key] = op.Value
+		}
+	}
+	return writers, intermediate
+}
+
+// lostUpdates adds a LostUpdate anomaly for every version that was read and then written by more than one transaction.
+func (g *graph) lostUpdates(groups map[version][]int, order []version) {
+	for _, v := range order {
+		if len(groups[v]) > 1 {
+			g.addAnomaly(LostUpdate, v.key, groups[v]...)
+		}
+	}
+}
+
+// asList returns the value of a read of a list.
+func asList(value any) []any {
+	if list, ok := value.([]any); ok {
+		return list
+	}
+	return nil
+}
+
+// isPrefix returns true if a is a prefix of b.
+func isPrefix(a, b []any) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// inferListAppend adds the dependencies of a list-append history.
+//
+// The version order of every key is the longest list read from it, which every other read must be a prefix of.
+func (g *graph) inferListAppend() {
+	writers, intermediate := g.writes(Append)
+
+	keys := make([]string, 0)
+	orders := make(map[string][]any)
+	orderReader := make(map[string]int)
+	for i, t := range g.history {
+		if t.Status != Committed {
+			continue
+		}
+		for _, op := range t.Ops {
+			if op.Type != Read {
+				continue
+			}
+			list := asList(op.Value)
+			longest, ok := orders[op.Key]
+			if !ok {
+				keys = append(keys, op.Key)
+			}
+			if isPrefix(longest, list) {
+				orders[op.Key], orderReader[op.Key] = list, i
+			} else if !isPrefix(list, longest) {
+				g.addAnomaly(IncompatibleOrder, op.Key, orderReader[op.Key], i)
+			}
+		}
+	}
+	for _, key := range keys {
+		order := orders[key]
+		for j := 1; j < len(order); j++ {
+			previous, okPrevious := writers[version{key, order[j-1]}]
+			next, okNext := writers[version{key, order[j]}]
+			if okPrevious && okNext {
+				g.addDependency(previous, next, WW, key)
+			}
+		}
+	}
+
+	groups := make(map[version][]int)
+	groupOrder := make([]version, 0)
+	for i, t := range g.history {
+		if t.Status != Committed {
+			continue
+		}
+		appended := make(map[string]bool)
+		for _, op := range t.Ops {
+			if op.Type == Append {
+				appended[op.Key] = true
+			}
+		}
+		grouped := make(map[string]bool)
+		for _, op := range externalReads(t) {
+			list := asList(op.Value)
+			for _, value := range list {
+				if w, ok := writers[version{op.Key, value}]; ok && g.history[w].Status == Aborted {
+					g.addAnomaly(G1a, op.Key, w, i)
+					break
+				}
+			}
+			if len(list) > 0 {
+				last := version{op.Key, list[len(list)-1]}
+				if w, ok := writers[last]; ok {
+					if intermediate[last] && w != i {
+						g.addAnomaly(G1b, op.Key, w, i)
+					}
+					g.addDependency(w, i, WR, op.Key)
+				}
+			}
+			if order := orders[op.Key]; len(list) < len(order) {
+				if w, ok := writers[version{op.Key, order[len(list)]}]; ok {
+					g.addDependency(i, w, RW, op.Key)
+				}
+			}
+			if appended[op.Key] && !grouped[op.Key] {
+				grouped[op.Key] = true
+				v := version{op.Key, len(list)}
+				if _, ok := groups[v]; !ok {
+					groupOrder = append(groupOrder, v)
+				}
+				groups[v] = append(groups[v], i)
+			}
+		}
+	}
+	g.lostUpdates(groups, groupOrder)
+}
+
+// inferRwRegister adds the dependencies of a read-write register history.
+//
+// The version order of every key is inferred from the initial version, which precedes every write,
+// and from transactions that read a version of a key and then write to it, which must follow the version read.
+func (g *graph) inferRwRegister() {
+	writers, intermediate := g.writes(Write)
+
+	readers := make(map[version][]int)
+	for i, t := range g.history {
+		if t.Status != Committed {
+			continue
+		}
+		for _, op := range externalReads(t) {
+			v := version{op.Key, op.Value}
+			readers[v] = append(readers[v], i)
+			if op.Value == nil {
+				continue
+			}
+			w, ok := writers[v]
+			if !ok {
+				continue
+			}
+			if g.history[w].Status == Aborted {
+				g.addAnomaly(G1a, op.Key, w, i)
+				continue
+			}
+			if intermediate[v] && w != i {
+				g.addAnomaly(G1b, op.Key, w, i)
+			}
+			g.addDependency(w, i, WR, op.Key)
+		}
+	}
+
+	groups := make(map[version][]int)
+	groupOrder := make([]version, 0)
+	for i, t := range g.history {
+		if t.Status == Aborted {
+			continue
+		}
+		read := make(map[string]any)
+		hasRead := make(map[string]bool)
+		if t.Status == Committed {
+			for _, op := range externalReads(t) {
+				if !hasRead[op.Key] {
+					read[op.Key], hasRead[op.Key] = op.Value, true
+				}
+			}
+		}
+		written := make(map[string]bool)
+		for _, op := range t.Ops {
+			if op.Type != Write || written[op.Key] {
+				continue
+			}
+			written[op.Key] = true
+			for _, r := range readers[version{op.Key, nil}] {
+				g.addDependency(r, i, RW, op.Key)
+			}
+			if !hasRead[op.Key] {
+				continue
+			}
+			v := version{op.Key, read[op.Key]}
+			if w, ok := writers[v]; ok && v.value != nil {
+				g.addDependency(w, i, WW, op.Key)
+			}
+			for _, r := range readers[v] {
+				g.addDependency(r, i, RW, op.Key)
+			}
+			if _, ok := groups[v]; !ok {
+				groupOrder = append(groupOrder, v)
+			}
+			groups[v] = append(groups[v], i)
+		}
+	}
+	g.lostUpdates(groups, groupOrder)
+}
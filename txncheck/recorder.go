@@ -0,0 +1,68 @@
+package txncheck
+
+import (
+	"sync"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// Recorder records the transactions invoked by client nodes during a simulation.
+//
+// The invocation and outcome of every transaction are timestamped with the time of the simulation.
+type Recorder struct {
+	mu           sync.Mutex
+	clock        ds.Clock
+	transactions []Transaction
+}
+
+// NewRecorder creates a new Recorder that timestamps transactions with the given clock, usually the simulation.
+func NewRecorder(clock ds.Clock) *Recorder {
+	return &Recorder{
+		clock:        clock,
+		transactions: make([]Transaction, 0),
+	}
+}
+
+// Begin records the invocation of a transaction with the given micro-operations by a client and returns its id.
+//
+// The values of the reads are unknown until the transaction commits.
+func (r *Recorder) Begin(client ds.Address, ops []Mop) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transactions = append(r.transactions, Transaction{
+		Client: client,
+		Ops:    ops,
+		Status: Unknown,
+		Call:   r.clock.Now(),
+	})
+	return len(r.transactions) - 1
+}
+
+// Commit records that the transaction with the given id committed, with the values of its reads filled in.
+func (r *Recorder) Commit(id int, ops []Mop) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transactions[id].Ops = ops
+	r.transactions[id].Status = Committed
+	r.transactions[id].Return = r.clock.Now()
+}
+
+// Abort records that the transaction with the given id aborted.
+func (r *Recorder) Abort(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transactions[id].Status = Aborted
+	r.transactions[id].Return = r.clock.Now()
+}
+
+// History returns the transactions recorded so far, in the order they were invoked.
+func (r *Recorder) History() []Transaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Transaction(nil), r.transactions...)
+}
+
+// Check checks the recorded history, as done by Check.
+func (r *Recorder) Check(model Model) *Result {
+	return Check(model, r.History())
+}
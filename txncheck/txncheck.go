@@ -0,0 +1,299 @@
+// Package txncheck checks the transactions recorded from client nodes during a simulation for
+// the anomalies that distinguish isolation levels, in the style of the Elle checker.
+//
+// Transactions are lists of micro-operations over keys. In a list-append history, every key is a list
+// and transactions append unique values to it and read the whole list. In a read-write register history,
+// every key is a register and transactions write unique values to it and read its value.
+//
+// The order in which transactions observed each other is inferred from the values they read, and is
+// used to build a dependency graph between transactions with write-write, write-read and read-write
+// edges. Cycles in this graph are the anomalies G0, G1c, G-single and G2 of Adya's formalism.
+// The non-cyclic anomalies G1a, G1b and lost update are found directly from the reads.
+package txncheck
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+// DefaultReportPath is the default path of the report written for a history that violates an isolation level.
+const DefaultReportPath = "anomalies.log"
+
+// Model is a string that identifies the kind of keys in a history.
+type Model string
+
+const (
+	// ListAppend is the model of histories in which every key is a list that values are appended to.
+	ListAppend Model = "ListAppend"
+	// RwRegister is the model of histories in which every key is a register that values are written to.
+	RwRegister Model = "RwRegister"
+)
+
+// MopType is a string that identifies the type of a micro-operation.
+type MopType string
+
+const (
+	// Append appends a value to the list of a key.
+	Append MopType = "Append"
+	// Write writes a value to the register of a key.
+	Write MopType = "Write"
+	// Read reads the list or register of a key.
+	Read MopType = "Read"
+)
+
+// Mop is a micro-operation of a transaction.
+//
+// The value of a Read is the value read, which is a []any for a list and nil for a list or register that was never written.
+// Values must be comparable, and every value must be appended or written to a key at most once.
+type Mop struct {
+	Type  MopType
+	Key   string
+	Value any
+}
+
+// String returns a string representation of the micro-operation.
+func (m Mop) String() string {
+	switch m.Type {
+	case Append:
+		return fmt.Sprintf("append(%v, %v)", m.Key, m.Value)
+	case Write:
+		return fmt.Sprintf("write(%v, %v)", m.Key, m.Value)
+	default:
+		return fmt.Sprintf("read(%v) -> %v", m.Key, m.Value)
+	}
+}
+
+// Status is a string that represents the outcome of a transaction.
+//
+// It can be either Committed, Aborted or Unknown.
+type Status string
+
+const (
+	// Committed is the status of a transaction that committed.
+	Committed Status = "Committed"
+	// Aborted is the status of a transaction that aborted.
+	Aborted Status = "Aborted"
+	// Unknown is the status of a transaction whose outcome was not observed.
+	Unknown Status = "Unknown"
+)
+
+// Transaction is a transaction invoked by a client, with its micro-operations and its outcome.
+//
+// The values of reads are only known if the transaction committed.
+// The times are measured from the start of the simulation.
+type Transaction struct {
+	Client ds.Address
+	Ops    []Mop
+	Status Status
+	Call   time.Duration
+	Return time.Duration
+}
+
+// String returns a string representation of the transaction.
+func (t Transaction) String() string {
+	ops := make([]string, 0, len(t.Ops))
+	for _, op := range t.Ops {
+		ops = append(ops, op.String())
+	}
+	return fmt.Sprintf("%v %v [%v] (%v)", t.Client, t.Status, strings.Join(ops, ", "), t.Call)
+}
+
+// AnomalyType is a string that identifies the type of an anomaly.
+type AnomalyType string
+
+const (
+	// G0 is a cycle of write-write dependencies, also known as dirty write.
+	G0 AnomalyType = "G0"
+	// G1a is a read of a value written by an aborted transaction, also known as aborted read.
+	G1a AnomalyType = "G1a"
+	// G1b is a read of a value that was not the final value written by a transaction, also known as intermediate read.
+	G1b AnomalyType = "G1b"
+	// G1c is a cycle of write-write and write-read dependencies, also known as circular information flow.
+	G1c AnomalyType = "G1c"
+	// GSingle is a cycle with exactly one read-write dependency, also known as read skew.
+	GSingle AnomalyType = "G-single"
+	// G2 is a cycle with more than one read-write dependency, also known as write skew.
+	G2 AnomalyType = "G2"
+	// LostUpdate is two committed transactions that read the same version of a key and both write to it.
+	LostUpdate AnomalyType = "LostUpdate"
+	// IncompatibleOrder is two reads of a list where neither is a prefix of the other.
+	IncompatibleOrder AnomalyType = "IncompatibleOrder"
+)
+
+// DependencyType is a string that identifies the type of a dependency between two transactions.
+type DependencyType string
+
+const (
+	// WW is a write-write dependency, where a transaction overwrites a version written by another.
+	WW DependencyType = "ww"
+	// WR is a write-read dependency, where a transaction reads a version written by another.
+	WR DependencyType = "wr"
+	// RW is a read-write dependency, where a transaction overwrites a version read by another.
+	RW DependencyType = "rw"
+)
+
+// Dependency is an edge of the dependency graph between the transactions at index From and To of a history.
+type Dependency struct {
+	From int
+	To   int
+	Type DependencyType
+	Key  string
+}
+
+// String returns a string representation of the dependency.
+func (d Dependency) String() string {
+	return fmt.Sprintf("T%d -%v(%v)-> T%d", d.From, d.Type, d.Key, d.To)
+}
+
+// Anomaly is an anomaly found in a history.
+//
+// Transactions are the indices in the history of the transactions involved in the anomaly,
+// and Cycle is the cycle of dependencies for anomalies that are cycles.
+type Anomaly struct {
+	Type         AnomalyType
+	Key          string
+	Transactions []int
+	Cycle        []Dependency
+}
+
+// String returns a string representation of the anomaly.
+func (a Anomaly) String() string {
+	if len(a.Cycle) > 0 {
+		cycle := make([]string, 0, len(a.Cycle))
+		for _, dependency := range a.Cycle {
+			cycle = append(cycle, dependency.String())
+		}
+		return fmt.Sprintf("%v: %v", a.Type, strings.Join(cycle, ", "))
+	}
+	transactions := make([]string, 0, len(a.Transactions))
+	for _, t := range a.Transactions {
+		transactions = append(transactions, fmt.Sprintf("T%d", t))
+	}
+	return fmt.Sprintf("%v on %v: %v", a.Type, a.Key, strings.Join(transactions, ", "))
+}
+
+// IsolationLevel is a string that identifies an isolation level.
+type IsolationLevel string
+
+const (
+	// ReadUncommitted proscribes G0.
+	ReadUncommitted IsolationLevel = "ReadUncommitted"
+	// ReadCommitted proscribes G0, G1a, G1b and G1c.
+	ReadCommitted IsolationLevel = "ReadCommitted"
+	// SnapshotIsolation proscribes the anomalies of ReadCommitted, G-single and lost update.
+	SnapshotIsolation IsolationLevel = "SnapshotIsolation"
+	// RepeatableRead proscribes the anomalies of SnapshotIsolation and G2 over items.
+	RepeatableRead IsolationLevel = "RepeatableRead"
+	// Serializable proscribes every anomaly. Without predicate reads it is checked like RepeatableRead.
+	Serializable IsolationLevel = "Serializable"
+)
+
+// IsolationLevels are the isolation levels that are checked, from weakest to strongest.
+var IsolationLevels = []IsolationLevel{ReadUncommitted, ReadCommitted, SnapshotIsolation, RepeatableRead, Serializable}
+
+// proscribed are the anomalies that each isolation level proscribes.
+var proscribed = map[IsolationLevel][]AnomalyType{
+	ReadUncommitted:   {G0, IncompatibleOrder},
+	ReadCommitted:     {G0, IncompatibleOrder, G1a, G1b, G1c},
+	SnapshotIsolation: {G0, IncompatibleOrder, G1a, G1b, G1c, GSingle, LostUpdate},
+	RepeatableRead:    {G0, IncompatibleOrder, G1a, G1b, G1c, GSingle, LostUpdate, G2},
+	Serializable:      {G0, IncompatibleOrder, G1a, G1b, G1c, GSingle, LostUpdate, G2},
+}
+
+// Result is the result of checking a history.
+type Result struct {
+	History      []Transaction
+	Dependencies []Dependency
+	Anomalies    []Anomaly
+}
+
+// Satisfies returns true if the history has none of the anomalies proscribed by the isolation level.
+func (r *Result) Satisfies(level IsolationLevel) bool {
+	return len(r.violations(level)) == 0
+}
+
+// Satisfied returns the isolation levels that the history satisfies, from weakest to strongest.
+func (r *Result) Satisfied() []IsolationLevel {
+	levels := make([]IsolationLevel, 0)
+	for _, level := range IsolationLevels {
+		if r.Satisfies(level) {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// Require returns a Violation if the history does not satisfy the isolation level, or nil otherwise.
+func (r *Result) Require(level IsolationLevel) error {
+	anomalies := r.violations(level)
+	if len(anomalies) == 0 {
+		return nil
+	}
+	return &Violation{Level: level, History: r.History, Anomalies: anomalies}
+}
+
+// violations returns the anomalies of the history that are proscribed by the isolation level.
+func (r *Result) violations(level IsolationLevel) []Anomaly {
+	anomalies := make([]Anomaly, 0)
+	for _, anomaly := range r.Anomalies {
+		for _, anomalyType := range proscribed[level] {
+			if anomaly.Type == anomalyType {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+	}
+	return anomalies
+}
+
+// Violation is the error returned when a history does not satisfy an isolation level.
+type Violation struct {
+	Level     IsolationLevel
+	History   []Transaction
+	Anomalies []Anomaly
+}
+
+// Error returns a string representation of the violation.
+func (v *Violation) Error() string {
+	return fmt.Sprintf("history does not satisfy %v: %v", v.Level, v.Anomalies[0])
+}
+
+// WriteFile writes the violation, its anomalies and the transactions involved to the file at the given path.
+func (v *Violation) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "Isolation level: %v\n", v.Level)
+	for _, anomaly := range v.Anomalies {
+		fmt.Fprintf(file, "Anomaly: %v\n", anomaly)
+		involved := append([]int(nil), anomaly.Transactions...)
+		for _, dependency := range anomaly.Cycle {
+			involved = append(involved, dependency.From)
+		}
+		for _, t := range involved {
+			fmt.Fprintf(file, "  T%d: %v\n", t, v.History[t])
+		}
+	}
+	return nil
+}
+
+// Check infers the dependencies between the transactions of the history and finds its anomalies.
+func Check(model Model, history []Transaction) *Result {
+	g := newGraph(history)
+	if model == ListAppend {
+		g.inferListAppend()
+	} else {
+		g.inferRwRegister()
+	}
+	g.findCycles()
+	return &Result{
+		History:      history,
+		Dependencies: g.dependencies,
+		Anomalies:    g.anomalies,
+	}
+}
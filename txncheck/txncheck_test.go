@@ -0,0 +1,185 @@
+package txncheck_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/samuel-adekunle/disse/txncheck"
+)
+
+func appendMop(key string, value any) txncheck.Mop {
+	return txncheck.Mop{Type: txncheck.Append, Key: key, Value: value}
+}
+
+func writeMop(key string, value any) txncheck.Mop {
+	return txncheck.Mop{Type: txncheck.Write, Key: key, Value: value}
+}
+
+func readMop(key string, value any) txncheck.Mop {
+	return txncheck.Mop{Type: txncheck.Read, Key: key, Value: value}
+}
+
+func committed(ops ...txncheck.Mop) txncheck.Transaction {
+	return txncheck.Transaction{Client: "client", Ops: ops, Status: txncheck.Committed}
+}
+
+// anomalyTypes returns the types of the anomalies of a result.
+func anomalyTypes(result *txncheck.Result) []txncheck.AnomalyType {
+	types := make([]txncheck.AnomalyType, 0)
+	for _, anomaly := range result.Anomalies {
+		types = append(types, anomaly.Type)
+	}
+	return types
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     txncheck.Model
+		history   []txncheck.Transaction
+		anomalies []txncheck.AnomalyType
+		satisfied []txncheck.IsolationLevel
+	}{
+		{
+			name:  "serializable",
+			model: txncheck.ListAppend,
+			history: []txncheck.Transaction{
+				committed(appendMop("x", 1)),
+				committed(readMop("x", []any{1}), appendMop("y", 2)),
+				committed(readMop("y", []any{2}), readMop("x", []any{1})),
+			},
+			anomalies: []txncheck.AnomalyType{},
+			satisfied: txncheck.IsolationLevels,
+		},
+		{
+			name:  "G0",
+			model: txncheck.ListAppend,
+			history: []txncheck.Transaction{
+				committed(appendMop("x", 1), appendMop("y", 2)),
+				committed(appendMop("x", 3), appendMop("y", 4)),
+				committed(readMop("x", []any{1, 3}), readMop("y", []any{4, 2})),
+			},
+			anomalies: []txncheck.AnomalyType{txncheck.G0},
+			satisfied: []txncheck.IsolationLevel{},
+		},
+		{
+			name:  "G1c",
+			model: txncheck.ListAppend,
+			history: []txncheck.Transaction{
+				committed(appendMop("x", 1), readMop("y", []any{2})),
+				committed(appendMop("y", 2), readMop("x", []any{1})),
+			},
+			anomalies: []txncheck.AnomalyType{txncheck.G1c},
+			satisfied: []txncheck.IsolationLevel{txncheck.ReadUncommitted},
+		},
+		{
+			name:  "G-single",
+			model: txncheck.ListAppend,
+			history: []txncheck.Transaction{
+				committed(appendMop("x", 1), appendMop("y", 1)),
+				committed(readMop("x", []any{}), readMop("y", []any{1})),
+				committed(readMop("x", []any{1})),
+			},
+			anomalies: []txncheck.AnomalyType{txncheck.GSingle},
+			satisfied: []txncheck.IsolationLevel{txncheck.ReadUncommitted, txncheck.ReadCommitted},
+		},
+		{
+			name:  "G2",
+			model: txncheck.RwRegister,
+			history: []txncheck.Transaction{
+				committed(readMop("x", nil), readMop("y", nil), writeMop("x", 1)),
+				committed(readMop("x", nil), readMop("y", nil), writeMop("y", 1)),
+			},
+			anomalies: []txncheck.AnomalyType{txncheck.G2},
+			satisfied: []txncheck.IsolationLevel{txncheck.ReadUncommitted, txncheck.ReadCommitted, txncheck.SnapshotIsolation},
+		},
+		{
+			name:  "lost update",
+			model: txncheck.RwRegister,
+			history: []txncheck.Transaction{
+				committed(readMop("x", nil), writeMop("x", 1)),
+				committed(readMop("x", nil), writeMop("x", 2)),
+			},
+			anomalies: []txncheck.AnomalyType{txncheck.LostUpdate, txncheck.G2},
+			satisfied: []txncheck.IsolationLevel{txncheck.ReadUncommitted, txncheck.ReadCommitted},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := txncheck.Check(test.model, test.history)
+			if got := anomalyTypes(result); !reflect.DeepEqual(got, test.anomalies) {
+				t.Errorf("anomalies = %v, want %v", result.Anomalies, test.anomalies)
+			}
+			if got := result.Satisfied(); !reflect.DeepEqual(got, test.satisfied) {
+				t.Errorf("Satisfied() = %v, want %v", got, test.satisfied)
+			}
+			for _, anomaly := range result.Anomalies {
+				if len(anomaly.Cycle) == 0 {
+					continue
+				}
+				for i, dependency := range anomaly.Cycle {
+					next := anomaly.Cycle[(i+1)%len(anomaly.Cycle)]
+					if dependency.To != next.From {
+						t.Errorf("%v is not a cycle", anomaly)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	result := txncheck.Check(txncheck.ListAppend, []txncheck.Transaction{
+		committed(appendMop("x", 1), readMop("y", []any{2})),
+		committed(appendMop("y", 2), readMop("x", []any{1})),
+	})
+	if err := result.Require(txncheck.ReadUncommitted); err != nil {
+		t.Errorf("Require(ReadUncommitted) = %v, want nil", err)
+	}
+	err := result.Require(txncheck.ReadCommitted)
+	violation, ok := err.(*txncheck.Violation)
+	if !ok {
+		t.Fatalf("Require(ReadCommitted) = %v, want a violation", err)
+	}
+	if len(violation.Anomalies) != 1 || violation.Anomalies[0].Type != txncheck.G1c {
+		t.Errorf("violation anomalies = %v, want G1c", violation.Anomalies)
+	}
+}
+
+func TestViolationWriteFile(t *testing.T) {
+	history := []txncheck.Transaction{
+		committed(appendMop("x", 1)),
+		committed(appendMop("x", 2)),
+		committed(readMop("x", []any{1, 2})),
+	}
+	transactions := []int{0, 2, 1}
+	anomaly := txncheck.Anomaly{
+		Type:         txncheck.G1b,
+		Key:          "x",
+		Transactions: transactions[:1],
+		Cycle:        []txncheck.Dependency{{From: 1, To: 0, Type: txncheck.WW, Key: "x"}},
+	}
+	violation := &txncheck.Violation{Level: txncheck.ReadCommitted, History: history, Anomalies: []txncheck.Anomaly{anomaly}}
+	path := filepath.Join(t.TempDir(), "anomalies.log")
+	if err := violation.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if !reflect.DeepEqual(transactions, []int{0, 2, 1}) {
+		t.Errorf("WriteFile modified the transactions of the anomaly to %v", transactions)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Isolation level: ReadCommitted", "T0: ", "T1: "} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("report does not contain %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "T2: ") {
+		t.Errorf("report contains a transaction that is not involved:\n%s", data)
+	}
+}
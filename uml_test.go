@@ -0,0 +1,74 @@
+package disse_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+var (
+	participantLine = regexp.MustCompile(`^participant "(.*)" as (\S+)$`)
+	arrowLine       = regexp.MustCompile(`^(\S+) --?>(?:x|\(\d+\))? (\S+) : `)
+)
+
+func TestUmlLoggerParticipantIds(t *testing.T) {
+	sim := ds.NewLocalSimulation(testOptions())
+	// The addresses are the same once punctuation is replaced by underscores or dropped.
+	root := newPingNode(sim, "a_b", "a-b", "a_2d_b")
+	sim.AddNode(root)
+	sim.AddNode(newPingNode(sim, "a-b", "a_b"))
+	sim.AddNode(newPingNode(sim, "a_2d_b"))
+	if err := root.AddSubNode(newPingNode(sim, ds.Address("a_b").NewSubAddress("2d"))); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "uml.log")
+	logger, err := ds.NewUmlLogger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.AddLogger(logger)
+	if err := sim.RunScheduled(ds.NewRandomScheduler(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses := make(map[string]string)
+	ids := make(map[string]string)
+	arrows := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := participantLine.FindStringSubmatch(line); m != nil {
+			address, id := m[1], m[2]
+			if _, ok := addresses[address]; ok {
+				t.Errorf("%q declared more than once", address)
+			}
+			if other, ok := ids[id]; ok {
+				t.Errorf("%q and %q are both declared as %v", other, address, id)
+			}
+			addresses[address], ids[id] = id, address
+			continue
+		}
+		if m := arrowLine.FindStringSubmatch(line); m != nil {
+			arrows++
+			for _, id := range m[1:] {
+				if _, ok := ids[id]; !ok {
+					t.Errorf("%q uses undeclared participant %v", line, id)
+				}
+			}
+		}
+	}
+	for _, address := range []ds.Address{"a_b", "a-b", "a_2d_b", "a_b.2d"} {
+		if _, ok := addresses[string(address)]; !ok {
+			t.Errorf("%q not declared", address)
+		}
+	}
+	if want := 6; arrows != want {
+		t.Errorf("%d arrows, want %d", arrows, want)
+	}
+}
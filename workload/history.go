@@ -0,0 +1,125 @@
+package workload
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/lincheck"
+)
+
+// Status is a string that represents the outcome of a request.
+//
+// It can be either Pending, Completed, Failed or TimedOut.
+type Status string
+
+const (
+	// Pending is the status of a request that has not completed or timed out.
+	Pending Status = "Pending"
+	// Completed is the status of a request that completed.
+	Completed Status = "Completed"
+	// Failed is the status of a request that completed with an error.
+	Failed Status = "Failed"
+	// TimedOut is the status of a request that did not complete before its timeout.
+	TimedOut Status = "TimedOut"
+)
+
+// Entry is a request recorded in a history.
+//
+// Invoke is the time the request was sent and Complete is the time it completed, failed or timed out,
+// both measured from the start of the simulation.
+type Entry struct {
+	Client   ds.Address
+	To       ds.Address
+	Request  ds.MessageId
+	Input    any
+	Output   any
+	Err      error
+	Status   Status
+	Invoke   time.Duration
+	Complete time.Duration
+}
+
+// History is the history of the requests sent by one or more clients.
+type History struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewHistory creates a new empty History.
+func NewHistory() *History {
+	return &History{
+		entries: make([]Entry, 0),
+	}
+}
+
+// Entries returns the requests recorded so far, in the order they were sent.
+func (h *History) Entries() []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Entry(nil), h.entries...)
+}
+
+// Operations returns the history as operations that can be checked for linearizability.
+//
+// Failed requests are left out, as they did not take effect. Requests that are pending or
+// timed out may or may not have taken effect, so they have an Unknown output.
+func (h *History) Operations() []lincheck.Operation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	operations := make([]lincheck.Operation, 0, len(h.entries))
+	for _, entry := range h.entries {
+		operation := lincheck.Operation{
+			Client: entry.Client,
+			Input:  entry.Input,
+			Output: entry.Output,
+			Call:   entry.Invoke,
+			Return: entry.Complete,
+		}
+		switch entry.Status {
+		case Failed:
+			continue
+		case Pending, TimedOut:
+			operation.Output, operation.Return = lincheck.Unknown{}, math.MaxInt64
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// invoke records a request sent by a client and returns its index.
+func (h *History) invoke(client ds.Address, request Request, now time.Duration) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, Entry{
+		Client:  client,
+		To:      request.To,
+		Request: request.Message.Id,
+		Input:   request.Input,
+		Status:  Pending,
+		Invoke:  now,
+	})
+	return len(h.entries) - 1
+}
+
+// complete records the completion of the request at the given index.
+func (h *History) complete(i int, now time.Duration, completion Completion) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[i].Output = completion.Output
+	h.entries[i].Err = completion.Err
+	h.entries[i].Status = Completed
+	if completion.Err != nil {
+		h.entries[i].Status = Failed
+	}
+	h.entries[i].Complete = now
+}
+
+// timeout records that the request at the given index timed out.
+func (h *History) timeout(i int, now time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[i].Status = TimedOut
+	h.entries[i].Complete = now
+}
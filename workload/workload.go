@@ -0,0 +1,216 @@
+// Package workload provides a client node that sends requests generated by a pluggable Generator
+// and records a history of their invocations and completions in the time of the simulation.
+//
+// Requests are either sent in an open loop, where requests arrive as a Poisson process regardless of
+// whether earlier requests have completed, or in a closed loop, where a fixed number of requests are
+// outstanding at any time and a new request is only sent once an earlier one completes or times out.
+package workload
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+)
+
+const (
+	// WorkloadNext is the type of timer used to send the next request.
+	WorkloadNext ds.TimerType = "WorkloadNext"
+	// WorkloadTimeout is the type of timer used to time out an outstanding request.
+	WorkloadTimeout ds.TimerType = "WorkloadTimeout"
+)
+
+// Mode is a string that identifies how a client sends requests.
+//
+// It can be either OpenLoop or ClosedLoop.
+type Mode string
+
+const (
+	// OpenLoop sends requests with exponentially distributed inter-arrival times.
+	OpenLoop Mode = "OpenLoop"
+	// ClosedLoop keeps a fixed number of requests outstanding.
+	ClosedLoop Mode = "ClosedLoop"
+)
+
+// Default values for the options of a client.
+const (
+	DefaultRate        = 10.0
+	DefaultConcurrency = 1
+	DefaultTimeout     = 1 * time.Second
+)
+
+// Request is a request generated by a Generator.
+//
+// Message is sent to To, and Input is recorded in the history as the input of the operation.
+type Request struct {
+	To      ds.Address
+	Message ds.Message
+	Input   any
+}
+
+// Completion is the outcome of a request, found by a Generator in a reply.
+//
+// Request is the id of the message of the request that the reply completes.
+// If Err is not nil, the request failed without taking effect.
+type Completion struct {
+	Request ds.MessageId
+	Output  any
+	Err     error
+}
+
+// Generator generates the requests sent by a client and recognises their replies.
+type Generator interface {
+	// Next returns the next request to send.
+	Next(rand *rand.Rand) Request
+	// Complete returns the completion of a request and true if the message is a reply to a request, or false otherwise.
+	Complete(message ds.Message, from ds.Address) (Completion, bool)
+}
+
+// Options is used to set the options of a client.
+type Options struct {
+	// Mode is how the client sends requests.
+	Mode Mode
+	// Rate is the mean number of requests per second sent in an open loop.
+	Rate float64
+	// Concurrency is the number of requests outstanding in a closed loop.
+	Concurrency int
+	// ThinkTime is the time a closed loop waits after a request completes before sending the next one.
+	ThinkTime time.Duration
+	// Timeout is the time after which an outstanding request is given up on.
+	Timeout time.Duration
+	// Requests is the maximum number of requests to send, or 0 for no limit.
+	Requests int
+	// Seed is the seed of the random numbers passed to the generator and used for inter-arrival times.
+	Seed int64
+}
+
+// ClientNode is a node that sends the requests generated by a Generator and records them in a History.
+type ClientNode struct {
+	*ds.LocalNode
+	clock       ds.Clock
+	generator   Generator
+	history     *History
+	options     *Options
+	rand        *rand.Rand
+	sent        int
+	outstanding map[ds.MessageId]int
+}
+
+// NewClientNode creates a new ClientNode with the given address that records its requests in history.
+//
+// If options is nil, requests are sent in an open loop at the default rate with the default timeout.
+func NewClientNode(sim *ds.LocalSimulation, address ds.Address, generator Generator, history *History, options *Options) *ClientNode {
+	if options == nil {
+		options = &Options{
+			Mode:    OpenLoop,
+			Rate:    DefaultRate,
+			Timeout: DefaultTimeout,
+		}
+	}
+	return &ClientNode{
+		LocalNode: ds.NewLocalNode(sim, address),
+		clock:     sim,
+		generator: generator,
+		history:   history,
+		options:   options,
+	}
+}
+
+// Init is called when the node is initialized by the simulation.
+func (n *ClientNode) Init(ctx context.Context) {
+	n.rand = rand.New(rand.NewSource(n.options.Seed))
+	n.sent = 0
+	n.outstanding = make(map[ds.MessageId]int)
+	if n.options.Mode == ClosedLoop {
+		concurrency := n.options.Concurrency
+		if concurrency <= 0 {
+			concurrency = DefaultConcurrency
+		}
+		for i := 0; i < concurrency; i++ {
+			n.send(ctx)
+		}
+		return
+	}
+	n.SetTimer(ctx, ds.NewTimer(WorkloadNext, nil), n.interArrival())
+}
+
+// HandleMessage is called when the node receives a message.
+func (n *ClientNode) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	completion, ok := n.generator.Complete(message, from)
+	if !ok {
+		return false
+	}
+	entry, ok := n.outstanding[completion.Request]
+	if !ok {
+		return true
+	}
+	delete(n.outstanding, completion.Request)
+	n.history.complete(entry, n.clock.Now(), completion)
+	n.next(ctx)
+	return true
+}
+
+// HandleTimer is called when a node receives a timer.
+func (n *ClientNode) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	switch timer.Type {
+	case WorkloadNext:
+		n.send(ctx)
+		if n.options.Mode == OpenLoop && !n.done() {
+			n.SetTimer(ctx, ds.NewTimer(WorkloadNext, nil), n.interArrival())
+		}
+		return true
+	case WorkloadTimeout:
+		request := timer.Data.(ds.MessageId)
+		if entry, ok := n.outstanding[request]; ok {
+			delete(n.outstanding, request)
+			n.history.timeout(entry, n.clock.Now())
+			n.next(ctx)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// done returns true if the maximum number of requests has been sent.
+func (n *ClientNode) done() bool {
+	return n.options.Requests > 0 && n.sent >= n.options.Requests
+}
+
+// send sends the next request of the generator, unless the maximum number of requests has been sent.
+func (n *ClientNode) send(ctx context.Context) {
+	if n.done() {
+		return
+	}
+	n.sent++
+	request := n.generator.Next(n.rand)
+	n.outstanding[request.Message.Id] = n.history.invoke(n.GetAddress(), request, n.clock.Now())
+	n.SendMessage(ctx, request.Message, request.To)
+	timeout := n.options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	n.SetTimer(ctx, ds.NewTimer(WorkloadTimeout, request.Message.Id), timeout)
+}
+
+// next sends the next request of a closed loop after a request completes or times out.
+func (n *ClientNode) next(ctx context.Context) {
+	if n.options.Mode != ClosedLoop {
+		return
+	}
+	if n.options.ThinkTime > 0 {
+		n.SetTimer(ctx, ds.NewTimer(WorkloadNext, nil), n.options.ThinkTime)
+		return
+	}
+	n.send(ctx)
+}
+
+// interArrival returns an exponentially distributed time until the next request of an open loop.
+func (n *ClientNode) interArrival() time.Duration {
+	rate := n.options.Rate
+	if rate <= 0 {
+		rate = DefaultRate
+	}
+	return time.Duration(n.rand.ExpFloat64() / rate * float64(time.Second))
+}
@@ -0,0 +1,173 @@
+package workload_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	ds "github.com/samuel-adekunle/disse"
+	"github.com/samuel-adekunle/disse/disstest"
+	"github.com/samuel-adekunle/disse/lincheck"
+	"github.com/samuel-adekunle/disse/workload"
+)
+
+const (
+	double       ds.MessageType = "Double"
+	doubled      ds.MessageType = "Doubled"
+	doubleFailed ds.MessageType = "DoubleFailed"
+)
+
+// doubleServer replies to every request with twice its input, fails inputs divisible by 3 and ignores the inputs in drop.
+type doubleServer struct {
+	*ds.LocalNode
+	drop map[int]bool
+}
+
+func (n *doubleServer) Init(ctx context.Context) {}
+
+func (n *doubleServer) HandleMessage(ctx context.Context, message ds.Message, from ds.Address) bool {
+	if message.Type != double {
+		return false
+	}
+	input := message.Data.(int)
+	switch {
+	case n.drop[input]:
+	case input%3 == 0:
+		n.SendMessage(ctx, ds.NewReply(message, doubleFailed, nil), from)
+	default:
+		n.SendMessage(ctx, ds.NewReply(message, doubled, 2*input), from)
+	}
+	return true
+}
+
+func (n *doubleServer) HandleTimer(ctx context.Context, timer ds.Timer, duration time.Duration) bool {
+	return false
+}
+
+// doubleGenerator sends the inputs 1, 2, 3 and so on to the server s.
+type doubleGenerator struct {
+	input int
+}
+
+func (g *doubleGenerator) Next(rand *rand.Rand) workload.Request {
+	g.input++
+	return workload.Request{To: "s", Message: ds.NewMessage(double, g.input), Input: g.input}
+}
+
+func (g *doubleGenerator) Complete(message ds.Message, from ds.Address) (workload.Completion, bool) {
+	switch message.Type {
+	case doubled:
+		return workload.Completion{Request: message.ReplyTo, Output: message.Data}, true
+	case doubleFailed:
+		return workload.Completion{Request: message.ReplyTo, Err: errors.New("failed")}, true
+	default:
+		return workload.Completion{}, false
+	}
+}
+
+// runClient runs a client with the given options against a server that ignores the inputs in drop.
+func runClient(t *testing.T, options *workload.Options, drop ...int) *workload.History {
+	sim := disstest.New(t, &ds.LocalSimulationOptions{
+		MinLatency: time.Millisecond,
+		MaxLatency: 5 * time.Millisecond,
+		Duration:   time.Second,
+	})
+	server := &doubleServer{LocalNode: ds.NewLocalNode(sim.LocalSimulation, "s"), drop: make(map[int]bool)}
+	for _, input := range drop {
+		server.drop[input] = true
+	}
+	history := workload.NewHistory()
+	sim.AddNode(server)
+	sim.AddNode(workload.NewClientNode(sim.LocalSimulation, "c", &doubleGenerator{}, history, options))
+	sim.Run()
+	return history
+}
+
+// checkEntry checks the status and output of an entry.
+func checkEntry(t *testing.T, entry workload.Entry, timeout time.Duration) {
+	t.Helper()
+	input := entry.Input.(int)
+	want := workload.Completed
+	switch {
+	case entry.Status == workload.TimedOut:
+		want = workload.TimedOut
+		if entry.Complete-entry.Invoke != timeout {
+			t.Errorf("request %d timed out after %v, want %v", input, entry.Complete-entry.Invoke, timeout)
+		}
+	case input%3 == 0:
+		want = workload.Failed
+	default:
+		if entry.Output != 2*input {
+			t.Errorf("request %d completed with %v, want %d", input, entry.Output, 2*input)
+		}
+	}
+	if entry.Status != want {
+		t.Errorf("request %d is %v, want %v", input, entry.Status, want)
+	}
+	if entry.Client != "c" || entry.To != "s" || entry.Complete <= entry.Invoke {
+		t.Errorf("request %d recorded as %+v", input, entry)
+	}
+}
+
+func TestClosedLoop(t *testing.T) {
+	timeout := 100 * time.Millisecond
+	entries := runClient(t, &workload.Options{Mode: workload.ClosedLoop, Concurrency: 2, Timeout: timeout, Requests: 6}, 4).Entries()
+	if len(entries) != 6 {
+		t.Fatalf("recorded %d requests, want 6", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Input != i+1 {
+			t.Errorf("request %d has input %v", i, entry.Input)
+		}
+		checkEntry(t, entry, timeout)
+		outstanding := 0
+		for _, other := range entries[:i] {
+			if other.Complete > entry.Invoke {
+				outstanding++
+			}
+		}
+		if outstanding >= 2 {
+			t.Errorf("request %d sent with %d requests outstanding", i+1, outstanding)
+		}
+	}
+	if entries[3].Status != workload.TimedOut {
+		t.Errorf("dropped request is %v, want %v", entries[3].Status, workload.TimedOut)
+	}
+}
+
+func TestOpenLoop(t *testing.T) {
+	timeout := 50 * time.Millisecond
+	// Every request is dropped, so requests are only sent in time because the loop is open.
+	drop := []int{1, 2, 3, 4, 5}
+	entries := runClient(t, &workload.Options{Mode: workload.OpenLoop, Rate: 100, Timeout: timeout, Requests: 5, Seed: 1}, drop...).Entries()
+	if len(entries) != 5 {
+		t.Fatalf("recorded %d requests, want 5", len(entries))
+	}
+	overlapping := false
+	for i, entry := range entries {
+		checkEntry(t, entry, timeout)
+		if i > 0 && entry.Invoke < entries[i-1].Complete {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		t.Errorf("no request was sent while another was outstanding")
+	}
+}
+
+func TestOperations(t *testing.T) {
+	// The second request times out and the third fails, so it is left out.
+	history := runClient(t, &workload.Options{Mode: workload.ClosedLoop, Timeout: 100 * time.Millisecond, Requests: 3}, 2)
+	operations := history.Operations()
+	if len(operations) != 2 {
+		t.Fatalf("history has operations %v, want 2", operations)
+	}
+	if operations[0].Output != 2 || operations[0].Pending() {
+		t.Errorf("first operation %+v, want output 2", operations[0])
+	}
+	if !operations[1].Pending() || operations[1].Output != (lincheck.Unknown{}) {
+		t.Errorf("timed out operation %+v, want an unknown output", operations[1])
+	}
+}